@@ -1,16 +1,20 @@
 package main
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
-
-	// "io/ioutil"
+	"strconv"
+	"strings"
+	"time"
 
 	"badcoin/src/node"
+	"badcoin/src/p2p"
+	"badcoin/src/wallet"
 
 	"github.com/urfave/cli"
 )
@@ -26,22 +30,45 @@ func HealthCheck(c *cli.Context) error {
 	return nil
 }
 
-// SendTx <to address> <amount> -from=<from address> -memo=<some data>
+// SendTx [--from address] [--expires-in blocks] <to address> <amount> [data], to, amount and data can be given by flags too
 func SendTx(c *cli.Context) error {
-	if len(c.Args()) < 2 {
+	to := c.String("to")
+	value := c.String("value")
+	data := c.String("data")
+	args := c.Args()
+	if to == "" {
+		to, args = args.First(), args.Tail()
+	}
+	if value == "" {
+		value, args = args.First(), args.Tail()
+	}
+	if data == "" {
+		data = args.First()
+	}
+	if to == "" || value == "" {
 		return fmt.Errorf("To and amount must be specified")
 	}
-	to := c.Args()[0]
-	value := c.Args()[1]
-	data := c.Args()[2]
 
-	fmt.Println("sending", value, "to", to, "...")
-	var res node.SendTxResponse
-	err := Call("tx/send", map[string]string{
+	from := c.String("from")
+	if from != "" {
+		fmt.Println("sending", value, "from", from, "to", to, "...")
+	} else {
+		fmt.Println("sending", value, "to", to, "...")
+	}
+	params := map[string]string{
+		"from":  from,
 		"to":    to,
 		"value": value,
 		"data":  data,
-	}, &res)
+	}
+	if expiresIn := c.Uint64("expires-in"); expiresIn != 0 {
+		params["expiresin"] = strconv.FormatUint(expiresIn, 10)
+	}
+	var res node.SendTxResponse
+	err := Call("tx/send", params, &res)
+	if err != nil {
+		return err
+	}
 
 	out, err := json.MarshalIndent(res, "", "  ")
 	if err != nil {
@@ -51,25 +78,39 @@ func SendTx(c *cli.Context) error {
 	return nil
 }
 
+// SendSignedTx <to address> <amount> <nonce> <timestamp> <from address> <base64 signature> [data]
+// [--chain-id id] [--valid-after height] [--expires-at height], node's chain id is used if it isn't given
 func SendSignedTx(c *cli.Context) error {
-	if len(c.Args()) < 4{
-		return fmt.Errorf("to, amount, pubkey and signature must be specified")
+	if len(c.Args()) < 6 {
+		return fmt.Errorf("to, amount, nonce, timestamp, from and signature must be specified")
 	}
 	to := c.Args()[0]
 	value := c.Args()[1]
-    pubkey64 := c.Args()[2]
-    signature64 := c.Args()[3]
-	data := c.Args()[4]
 
 	fmt.Println("sending signed tx ", value, " BDC to", to, "...")
+	params := map[string]string{
+		"to":        to,
+		"value":     value,
+		"nonce":     c.Args()[2],
+		"timestamp": c.Args()[3],
+		"from":      c.Args()[4],
+		"signature": c.Args()[5],
+		"data":      c.Args().Get(6),
+	}
+	if chainID := c.Uint64("chain-id"); chainID != 0 {
+		params["chainid"] = strconv.FormatUint(chainID, 10)
+	}
+	if validAfter := c.Uint64("valid-after"); validAfter != 0 {
+		params["validafter"] = strconv.FormatUint(validAfter, 10)
+	}
+	if expiresAt := c.Uint64("expires-at"); expiresAt != 0 {
+		params["expiresat"] = strconv.FormatUint(expiresAt, 10)
+	}
 	var res node.SendTxResponse
-	err := Call("tx/send", map[string]string{
-		"to":    to,
-		"value": value,
-        "pubKey": pubkey64,
-        "signature": signature64,
-		"data":  data,
-	}, &res)
+	err := Call("tx/signed/send", params, &res)
+	if err != nil {
+		return err
+	}
 
 	out, err := json.MarshalIndent(res, "", "  ")
 	if err != nil {
@@ -93,6 +134,243 @@ func NewAddress(c *cli.Context) error {
 	return nil
 }
 
+func WalletStatus(c *cli.Context) error {
+	var res node.WalletStatusResponse
+	err := Get("wallet/status", &res)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// readPassphrase returns passphrase of --passphrase or environment, it is read from stdin if it isn't set
+func readPassphrase(c *cli.Context, prompt string) (string, error) {
+	if passphrase := c.String("passphrase"); passphrase != "" {
+		return passphrase, nil
+	}
+	fmt.Print(prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// EncryptWallet encrypts plaintext wallet with a passphrase which is read from --passphrase, environment or stdin
+func EncryptWallet(c *cli.Context) error {
+	passphrase, err := readPassphrase(c, "new wallet passphrase: ")
+	if err != nil {
+		return err
+	}
+	if c.String("passphrase") == "" {
+		repeated, err := readPassphrase(c, "repeat passphrase: ")
+		if err != nil {
+			return err
+		}
+		if repeated != passphrase {
+			return fmt.Errorf("passphrases don't match")
+		}
+	}
+	var res node.WalletStatusResponse
+	if err := Call("wallet/encrypt", map[string]string{"passphrase": passphrase}, &res); err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// UnlockWallet [--timeout seconds], passphrase is read from --passphrase, environment or stdin
+func UnlockWallet(c *cli.Context) error {
+	passphrase, err := readPassphrase(c, "wallet passphrase: ")
+	if err != nil {
+		return err
+	}
+	var res node.WalletStatusResponse
+	err = Call("wallet/unlock", map[string]string{
+		"passphrase": passphrase,
+		"timeout":    strconv.FormatUint(c.Uint64("timeout"), 10),
+	}, &res)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+func LockWallet(c *cli.Context) error {
+	var res node.WalletStatusResponse
+	err := Call("wallet/lock", map[string]string{}, &res)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+func ExportMnemonic(c *cli.Context) error {
+	var res node.MnemonicResponse
+	err := Call("wallet/mnemonic/export", map[string]string{}, &res)
+	if err != nil {
+		return err
+	}
+	fmt.Println(res.Mnemonic)
+	return nil
+}
+
+// ImportMnemonic [mnemonic words...] [--count n], without words a new mnemonic is generated by node
+func ImportMnemonic(c *cli.Context) error {
+	var res node.WalletStatusResponse
+	err := Call("wallet/mnemonic/import", map[string]string{
+		"mnemonic": strings.Join(c.Args(), " "),
+		"count":    strconv.FormatUint(c.Uint64("count"), 10),
+	}, &res)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// ExportKey <address>, prints encoded private key of a wallet address
+func ExportKey(c *cli.Context) error {
+	if len(c.Args()) < 1 {
+		return fmt.Errorf("address must be specified")
+	}
+	var res node.KeyResponse
+	err := Call("wallet/key/export", map[string]string{
+		"address": c.Args()[0],
+	}, &res)
+	if err != nil {
+		return err
+	}
+	fmt.Println(res.Key)
+	return nil
+}
+
+// ImportKey [encoded key], key is read from stdin if it is not given, so it is not kept in shell history
+func ImportKey(c *cli.Context) error {
+	key := c.Args().First()
+	if key == "" {
+		fmt.Print("private key: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return err
+		}
+		key = strings.TrimSpace(line)
+	}
+	var res node.NewAddressResponse
+	err := Call("wallet/key/import", map[string]string{
+		"key": key,
+	}, &res)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// AddWatchOnly <address>
+func AddWatchOnly(c *cli.Context) error {
+	if len(c.Args()) < 1 {
+		return fmt.Errorf("address must be specified")
+	}
+	var res node.NewAddressResponse
+	err := Call("wallet/watch", map[string]string{
+		"address": c.Args()[0],
+	}, &res)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// RescanWallet [--from height] [--wait], with --wait progress is shown until rescan is finished
+func RescanWallet(c *cli.Context) error {
+	var res node.RescanResponse
+	err := Call("wallet/rescan", map[string]string{
+		"from": strconv.FormatUint(c.Uint64("from"), 10),
+	}, &res)
+	if err != nil {
+		return err
+	}
+	for c.Bool("wait") && res.Running {
+		fmt.Printf("rescanning blocks %d-%d: %.1f%%, %d transactions found\n", res.From, res.To, res.Progress, res.Found)
+		time.Sleep(time.Second)
+		if err := Get("wallet/rescan", &res); err != nil {
+			return err
+		}
+	}
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+func RescanStatus(c *cli.Context) error {
+	var res node.RescanResponse
+	err := Get("wallet/rescan", &res)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// WalletHistory [address], without address all wallet addresses are shown
+func WalletHistory(c *cli.Context) error {
+	query := make(url.Values)
+	if len(c.Args()) > 0 {
+		query.Set("address", c.Args()[0])
+	}
+	var res node.WalletHistoryResponse
+	err := Get("wallet/history?"+query.Encode(), &res)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
 func GetInfo(c *cli.Context) error {
 	var res node.GetInfoResponse
 	err := Get("info", &res)
@@ -107,44 +385,290 @@ func GetInfo(c *cli.Context) error {
 	return nil
 }
 
-func Call(cmd string, options map[string]string, out interface{}) error {
-	vals := make(url.Values)
-	for k, v := range options {
-		vals.Set(k, v)
+func Peers(c *cli.Context) error {
+	var res node.PeersResponse
+	err := Get("admin/peers", &res)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
 	}
-	resp, err := http.PostForm("http://127.0.0.1:3000/"+cmd, vals)
+	fmt.Println(string(out))
+	return nil
+}
 
-	//fmt.Println("Response:", resp)
+// GetBlock [height] [--hash hash] [--cid cid] [--header]
+func GetBlock(c *cli.Context) error {
+	query := make(url.Values)
+	switch {
+	case c.String("hash") != "":
+		query.Set("hash", c.String("hash"))
+	case c.String("cid") != "":
+		query.Set("cid", c.String("cid"))
+	case len(c.Args()) > 0:
+		query.Set("height", c.Args()[0])
+	default:
+		return fmt.Errorf("height, hash or cid must be specified")
+	}
+	if c.Bool("header") {
+		query.Set("header", "true")
+	}
+	var res interface{}
+	err := Get("block?"+query.Encode(), &res)
 	if err != nil {
 		return err
 	}
-	// buf, err := ioutil.ReadAll(resp.Body)
-	err = json.NewDecoder(resp.Body).Decode(out)
+	out, err := json.MarshalIndent(res, "", "  ")
 	if err != nil {
 		return err
 	}
-	// fmt.Printl(string(buf))
+	fmt.Println(string(out))
 	return nil
 }
 
-func Get(url string, out interface{}) error {
-	resp, err := http.Get("http://127.0.0.1:3000/" + url)
+// GetHead [--header]
+func GetHead(c *cli.Context) error {
+	query := ""
+	if c.Bool("header") {
+		query = "?header=true"
+	}
+	var res interface{}
+	err := Get("head"+query, &res)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// GetTx <txid>
+func GetTx(c *cli.Context) error {
+	if len(c.Args()) < 1 {
+		return fmt.Errorf("txid must be specified")
+	}
+	var res node.TransactionResponse
+	err := Get("tx/"+url.PathEscape(c.Args()[0]), &res)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// GetAccount <address>
+func GetAccount(c *cli.Context) error {
+	if len(c.Args()) < 1 {
+		return fmt.Errorf("address must be specified")
+	}
+	var res node.AccountResponse
+	err := Get("address/"+url.PathEscape(c.Args()[0]), &res)
 	if err != nil {
 		return err
 	}
-	err = json.NewDecoder(resp.Body).Decode(out)
+	out, err := json.MarshalIndent(res, "", "  ")
 	if err != nil {
 		return err
 	}
+	fmt.Println(string(out))
 	return nil
 }
 
+// GetHistory <address> [limit] [cursor], cursor is Next of previous page
+func GetHistory(c *cli.Context) error {
+	if len(c.Args()) < 1 {
+		return fmt.Errorf("address must be specified")
+	}
+	query := make(url.Values)
+	if len(c.Args()) > 1 {
+		query.Set("limit", c.Args()[1])
+	}
+	if len(c.Args()) > 2 {
+		query.Set("cursor", c.Args()[2])
+	}
+	var res node.AddressHistoryResponse
+	err := Get("address/"+url.PathEscape(c.Args()[0])+"/history?"+query.Encode(), &res)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// BanPeer <peer id> [duration in seconds]
+func BanPeer(c *cli.Context) error {
+	if len(c.Args()) < 1 {
+		return fmt.Errorf("peer id must be specified")
+	}
+	options := map[string]string{
+		"peer": c.Args()[0],
+	}
+	if len(c.Args()) > 1 {
+		options["duration"] = c.Args()[1]
+	}
+	var res node.BanPeerResponse
+	err := Call("admin/peers/ban", options, &res)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// UnbanPeer <peer id>
+func UnbanPeer(c *cli.Context) error {
+	if len(c.Args()) < 1 {
+		return fmt.Errorf("peer id must be specified")
+	}
+	var res node.BanPeerResponse
+	err := Call("admin/peers/unban", map[string]string{
+		"peer": c.Args()[0],
+	}, &res)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// GenPSK --out <file>, generates pre-shared key of a private network offline
+func GenPSK(c *cli.Context) error {
+	path := c.String("out")
+	if path == "" {
+		return fmt.Errorf("key file must be specified with --out")
+	}
+	if err := p2p.GeneratePSK(path); err != nil {
+		return err
+	}
+	fmt.Println("pre-shared key is saved in", path, "(copy it to all nodes of private network)")
+	return nil
+}
+
+// RPCClient is the http client of node rpc
+type RPCClient struct {
+	URL      string
+	Token    string
+	Username string
+	Password string
+	HTTP     *http.Client
+}
+
+// do sends a request with credentials of rpc client
+func (client *RPCClient) do(req *http.Request, out interface{}) error {
+	if client.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+client.Token)
+	} else if client.Username != "" {
+		req.SetBasicAuth(client.Username, client.Password)
+	}
+	resp, err := client.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func Call(cmd string, options map[string]string, out interface{}) error {
+	vals := make(url.Values)
+	for k, v := range options {
+		vals.Set(k, v)
+	}
+	req, err := http.NewRequest("POST", rpcClient.URL+cmd, strings.NewReader(vals.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return rpcClient.do(req, out)
+}
+
+func Get(url string, out interface{}) error {
+	req, err := http.NewRequest("GET", rpcClient.URL+url, nil)
+	if err != nil {
+		return err
+	}
+	return rpcClient.do(req, out)
+}
+
+// responseError returns error of a failed request with its code and message
+func responseError(resp *http.Response) error {
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+	var res node.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("request failed: %s", resp.Status)
+	}
+	return fmt.Errorf("request failed (%s): %s", res.Code, res.Message)
+}
+
 func main() {
 	app := cli.NewApp()
 	app.Name = "bdc-cli"
 	app.Usage = "rpc client for badcoin"
 	app.Version = "0.0.1"
 
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:   "rpc",
+			Usage:  "rpc url of node (default: " + defaultRPC + ")",
+			EnvVar: "BDC_RPC",
+		},
+		cli.StringFlag{
+			Name:   "config",
+			Usage:  "cli config file or node config file (default: ~/" + defaultConfigFile + " if it exists)",
+			EnvVar: "BDC_CLI_CONFIG",
+		},
+		cli.StringFlag{
+			Name:   "token",
+			Usage:  "bearer token of rpc",
+			EnvVar: "BDC_RPC_TOKEN",
+		},
+		cli.StringFlag{
+			Name:   "user",
+			Usage:  "basic auth user of rpc",
+			EnvVar: "BDC_RPC_USER",
+		},
+		cli.StringFlag{
+			Name:   "password",
+			Usage:  "basic auth password of rpc",
+			EnvVar: "BDC_RPC_PASSWORD",
+		},
+		cli.BoolFlag{
+			Name:  "tls",
+			Usage: "connect to rpc over https",
+		},
+		cli.StringFlag{
+			Name:   "cacert",
+			Usage:  "CA certificate file to verify rpc server (e.g. a self-signed certificate)",
+			EnvVar: "BDC_RPC_CACERT",
+		},
+	}
+	app.Before = setupClient
+
 	app.Commands = []cli.Command{
 		{
 			Name:    "status",
@@ -153,10 +677,16 @@ func main() {
 			Action:  HealthCheck,
 		},
 		{
-			Name:    "sendtx",
-			Usage:   "send a transaction",
-			Aliases: []string{"tx"},
+			Name:      "sendtx",
+			Usage:     "send a transaction",
+			Aliases:   []string{"tx"},
+			ArgsUsage: "<to address> <amount> [data]",
 			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "from",
+					Value: "",
+					Usage: "wallet address to send from (default: miner address)",
+				},
 				cli.StringFlag{
 					Name:  "to",
 					Value: "",
@@ -172,41 +702,191 @@ func main() {
 					Value: "",
 					Usage: "add data to transaction",
 				},
+				cli.Uint64Flag{
+					Name:  "expires-in",
+					Usage: "transaction expires if it isn't mined in this number of blocks (default: never)",
+				},
 			},
 			Action: SendTx,
 		},
-        {
-			Name:    "sendsignedtx",
-			Usage:   "send a signed transaction",
-			Aliases: []string{"stx"},
+		{
+			Name:      "sendsignedtx",
+			Usage:     "send a signed transaction",
+			Aliases:   []string{"stx"},
+			ArgsUsage: "<to> <amount> <nonce> <timestamp> <from> <base64 signature> [data]",
+			Flags: []cli.Flag{
+				cli.Uint64Flag{
+					Name:  "chain-id",
+					Usage: "chain id which transaction is signed for (default: chain id of node)",
+				},
+				cli.Uint64Flag{
+					Name:  "valid-after",
+					Usage: "signed height which transaction is valid after",
+				},
+				cli.Uint64Flag{
+					Name:  "expires-at",
+					Usage: "signed height which transaction expires at",
+				},
+			},
+			Action: SendSignedTx,
+		},
+		{
+			Name:  "keygen",
+			Usage: "generates a keypair offline and saves it in a key file",
 			Flags: []cli.Flag{
 				cli.StringFlag{
-					Name:  "to",
-					Value: "",
-					Usage: "to address",
+					Name:  "out",
+					Usage: "key file",
 				},
 				cli.StringFlag{
-					Name:  "value",
-					Value: "",
-					Usage: "BDC amount",
+					Name:  "mnemonic",
+					Usage: "derive key from a mnemonic",
+				},
+				cli.BoolFlag{
+					Name:  "new-mnemonic",
+					Usage: "generate a new mnemonic and derive key from it",
+				},
+				cli.StringFlag{
+					Name:  "path",
+					Value: wallet.HDPath(0, 0),
+					Usage: "derivation path of key",
+				},
+			},
+			Action: KeyGen,
+		},
+		{
+			Name:  "deriveaddress",
+			Usage: "derives address of a key file or public key offline",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "key",
+					Usage: "key file",
 				},
-                cli.StringFlag{
+				cli.StringFlag{
 					Name:  "pubkey",
-					Value: "",
 					Usage: "base64 of pubkey",
 				},
+			},
+			Action: DeriveAddress,
+		},
+		{
+			Name:      "buildtx",
+			Usage:     "builds an unsigned transaction",
+			ArgsUsage: "<to> <amount>",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "key",
+					Usage: "key file of sender (only public key is used)",
+				},
 				cli.StringFlag{
-					Name:  "signature",
-					Value: "",
-					Usage: "base64 of signature",
+					Name:  "pubkey",
+					Usage: "base64 of sender pubkey",
+				},
+				cli.StringFlag{
+					Name:  "multisig",
+					Usage: "multisig file of sender (see multisig command)",
+				},
+				cli.Uint64Flag{
+					Name:  "nonce",
+					Usage: "transaction nonce",
+				},
+				cli.BoolFlag{
+					Name:  "fetch-nonce",
+					Usage: "fetch next nonce of sender from node",
+				},
+				cli.Uint64Flag{
+					Name:  "chain-id",
+					Usage: "chain id of network (default: fetched from node)",
+				},
+				cli.Uint64Flag{
+					Name:  "valid-after",
+					Usage: "transaction is valid only in blocks after this height",
+				},
+				cli.Uint64Flag{
+					Name:  "expires-at",
+					Usage: "transaction isn't valid in blocks of this height and after it",
 				},
 				cli.StringFlag{
 					Name:  "data",
-					Value: "",
 					Usage: "add data to transaction",
 				},
+				cli.StringFlag{
+					Name:  "out",
+					Usage: "transaction file (default: stdout)",
+				},
 			},
-			Action: SendSignedTx,
+			Action: BuildTx,
+		},
+		{
+			Name:      "signtx",
+			Usage:     "signs a transaction offline with a key file (adds a signature to multisig transactions)",
+			ArgsUsage: "<tx file>",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "key",
+					Usage: "key file",
+				},
+				cli.StringFlag{
+					Name:  "out",
+					Usage: "signed transaction file (default: stdout)",
+				},
+				cli.BoolFlag{
+					Name:  "raw",
+					Usage: "print hex of signed transaction to send it with sendrawtx",
+				},
+			},
+			Action: SignTx,
+		},
+		{
+			Name:  "multisig",
+			Usage: "creates a m-of-n multisig address offline from public keys",
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "threshold, m",
+					Usage: "number of required signatures",
+				},
+				cli.StringSliceFlag{
+					Name:  "key",
+					Usage: "key file of a signer (only public key is used), can be repeated",
+				},
+				cli.StringSliceFlag{
+					Name:  "pubkey",
+					Usage: "base64 pubkey of a signer, can be repeated",
+				},
+				cli.StringFlag{
+					Name:  "out",
+					Usage: "multisig file which is needed to build transactions",
+				},
+			},
+			Action: CreateMultisig,
+		},
+		{
+			Name:      "combinetx",
+			Usage:     "combines signatures of partially signed multisig transaction files",
+			ArgsUsage: "<tx file>...",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "out",
+					Usage: "combined transaction file (default: stdout)",
+				},
+				cli.BoolFlag{
+					Name:  "raw",
+					Usage: "print hex of fully signed transaction to send it with sendrawtx",
+				},
+			},
+			Action: CombineTx,
+		},
+		{
+			Name:      "broadcast",
+			Usage:     "sends a signed transaction file",
+			ArgsUsage: "<signed tx file>",
+			Action:    Broadcast,
+		},
+		{
+			Name:      "sendrawtx",
+			Usage:     "sends a hex or base64 serialized signed transaction",
+			ArgsUsage: "<raw tx>",
+			Action:    SendRawTx,
 		},
 		{
 			Name:    "newaddress",
@@ -214,12 +894,189 @@ func main() {
 			Aliases: []string{"addr"},
 			Action:  NewAddress,
 		},
+		{
+			Name:   "walletstatus",
+			Usage:  "shows if wallet is encrypted and locked",
+			Action: WalletStatus,
+		},
+		{
+			Name:  "encryptwallet",
+			Usage: "encrypts private keys and mnemonic of a plaintext wallet with a passphrase",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:   "passphrase",
+					Usage:  "new wallet passphrase (default: read from stdin)",
+					EnvVar: "BDC_WALLET_PASSPHRASE",
+				},
+			},
+			Action: EncryptWallet,
+		},
+		{
+			Name:  "unlock",
+			Usage: "unlocks wallet for a while, a plaintext wallet is encrypted with passphrase",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:   "passphrase",
+					Usage:  "wallet passphrase (default: read from stdin)",
+					EnvVar: "BDC_WALLET_PASSPHRASE",
+				},
+				cli.Uint64Flag{
+					Name:  "timeout",
+					Value: 300,
+					Usage: "seconds until wallet is locked again",
+				},
+			},
+			Action: UnlockWallet,
+		},
+		{
+			Name:   "lock",
+			Usage:  "locks wallet",
+			Action: LockWallet,
+		},
+		{
+			Name:   "mnemonic",
+			Usage:  "shows mnemonic of HD wallet for backup (wallet should be unlocked)",
+			Action: ExportMnemonic,
+		},
+		{
+			Name:      "setmnemonic",
+			Usage:     "restores a mnemonic and its addresses, a new one is generated without words (old keys are kept)",
+			ArgsUsage: "[mnemonic words...]",
+			Flags: []cli.Flag{
+				cli.Uint64Flag{
+					Name:  "count",
+					Usage: "number of mnemonic addresses to restore",
+				},
+			},
+			Action: ImportMnemonic,
+		},
+		{
+			Name:      "exportkey",
+			Usage:     "shows encoded private key of a wallet address (wallet should be unlocked)",
+			ArgsUsage: "<address>",
+			Action:    ExportKey,
+		},
+		{
+			Name:      "importkey",
+			Usage:     "imports an encoded private key, it is read from stdin without argument",
+			ArgsUsage: "[key]",
+			Action:    ImportKey,
+		},
+		{
+			Name:      "watch",
+			Usage:     "adds a watch-only address, its balance and history are tracked by rescan",
+			ArgsUsage: "<address>",
+			Action:    AddWatchOnly,
+		},
+		{
+			Name:  "rescan",
+			Usage: "rebuilds balances, nonces and history of wallet addresses from blocks",
+			Flags: []cli.Flag{
+				cli.Uint64Flag{
+					Name:  "from",
+					Usage: "height of first scanned block",
+				},
+				cli.BoolFlag{
+					Name:  "wait",
+					Usage: "show progress until rescan is finished",
+				},
+			},
+			Action: RescanWallet,
+		},
+		{
+			Name:   "rescanstatus",
+			Usage:  "shows progress of wallet rescan",
+			Action: RescanStatus,
+		},
+		{
+			Name:      "wallethistory",
+			Usage:     "shows balances, nonces and history of wallet addresses found by rescan",
+			ArgsUsage: "[address]",
+			Action:    WalletHistory,
+		},
 		{
 			Name:    "info",
 			Usage:   "shows blockchain information",
 			Aliases: []string{"i"},
 			Action:  GetInfo,
 		},
+		{
+			Name:      "getblock",
+			Usage:     "shows a block by height, hash or cid",
+			ArgsUsage: "[height]",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "hash",
+					Usage: "block header hash",
+				},
+				cli.StringFlag{
+					Name:  "cid",
+					Usage: "block cid",
+				},
+				cli.BoolFlag{
+					Name:  "header",
+					Usage: "show only block header",
+				},
+			},
+			Action: GetBlock,
+		},
+		{
+			Name:  "head",
+			Usage: "shows chain head",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "header",
+					Usage: "show only block header",
+				},
+			},
+			Action: GetHead,
+		},
+		{
+			Name:      "gettx",
+			Usage:     "shows a transaction, its block and confirmations",
+			ArgsUsage: "<txid>",
+			Action:    GetTx,
+		},
+		{
+			Name:      "account",
+			Usage:     "shows balance and nonce of an address",
+			ArgsUsage: "<address>",
+			Action:    GetAccount,
+		},
+		{
+			Name:      "history",
+			Usage:     "shows transactions and rewards of an address (address index should be enabled)",
+			ArgsUsage: "<address> [limit] [cursor]",
+			Action:    GetHistory,
+		},
+		{
+			Name:   "peers",
+			Usage:  "lists connected, known and banned peers",
+			Action: Peers,
+		},
+		{
+			Name:      "banpeer",
+			Usage:     "bans a peer",
+			ArgsUsage: "<peer id> [duration in seconds]",
+			Action:    BanPeer,
+		},
+		{
+			Name:      "unbanpeer",
+			Usage:     "unbans a peer",
+			ArgsUsage: "<peer id>",
+			Action:    UnbanPeer,
+		},
+		{
+			Name:  "genpsk",
+			Usage: "generates a pre-shared key file of a private network offline",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "out",
+					Usage: "key file",
+				},
+			},
+			Action: GenPSK,
+		},
 	}
 
 	err := app.Run(os.Args)
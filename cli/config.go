@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/urfave/cli"
+)
+
+const (
+	defaultRPC        = "http://127.0.0.1:3000/"
+	defaultConfigFile = ".bdc-cli.yaml"
+)
+
+// ClientConfig is rpc settings of cli, flags and environment variables override it
+type ClientConfig struct {
+	RPC      string
+	Token    string
+	User     string
+	Password string
+	CACert   string
+}
+
+// rpcClient sends requests to node rpc, it is set up before running a command
+var rpcClient = &RPCClient{
+	URL:  defaultRPC,
+	HTTP: http.DefaultClient,
+}
+
+// setupClient sets up rpc client from config file, environment variables and global flags
+func setupClient(c *cli.Context) error {
+	cfg := new(ClientConfig)
+	path := c.GlobalString("config")
+	if path == "" {
+		path = defaultConfigPath()
+	} else if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	if path != "" {
+		loaded, err := loadClientConfig(path)
+		if err != nil {
+			return fmt.Errorf("loading config %s failed: %v", path, err)
+		}
+		cfg = loaded
+	}
+
+	// flags and environment variables have priority over config file
+	override := func(val *string, flag string) {
+		if v := c.GlobalString(flag); v != "" {
+			*val = v
+		}
+	}
+	override(&cfg.RPC, "rpc")
+	override(&cfg.Token, "token")
+	override(&cfg.User, "user")
+	override(&cfg.Password, "password")
+	override(&cfg.CACert, "cacert")
+
+	rpcURL, err := normalizeRPC(cfg.RPC, c.GlobalBool("tls"))
+	if err != nil {
+		return err
+	}
+	rpcClient.URL = rpcURL
+	rpcClient.Token = cfg.Token
+	rpcClient.Username = cfg.User
+	rpcClient.Password = cfg.Password
+
+	if cfg.CACert != "" {
+		pem, err := ioutil.ReadFile(cfg.CACert)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate found in %s", cfg.CACert)
+		}
+		rpcClient.HTTP = &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		}
+	}
+	return nil
+}
+
+// defaultConfigPath returns cli config file of home directory if it exists
+func defaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	path := filepath.Join(home, defaultConfigFile)
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// loadClientConfig reads a cli config file, a node config file can be used too,
+// then rpc url and credentials are taken from its RpcSet
+func loadClientConfig(path string) (*ClientConfig, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if filepath.Ext(path) == "" {
+		v.SetConfigType("yaml")
+	}
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	if !v.IsSet("RpcSet") {
+		return &ClientConfig{
+			RPC:      v.GetString("RPC"),
+			Token:    v.GetString("Token"),
+			User:     v.GetString("User"),
+			Password: v.GetString("Password"),
+			CACert:   resolvePath(path, v.GetString("CACert")),
+		}, nil
+	}
+
+	host := v.GetString("RpcSet.Host")
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	port := v.GetString("RpcSet.Port")
+	if port == "" {
+		port = "3000"
+	}
+	scheme := "http"
+	cfg := &ClientConfig{
+		Token:    v.GetString("RpcSet.Auth.Token"),
+		User:     v.GetString("RpcSet.Auth.Username"),
+		Password: v.GetString("RpcSet.Auth.Password"),
+	}
+	if v.GetBool("RpcSet.TLS.Enabled") {
+		scheme = "https"
+		// certificate of node is trusted, it is usually self-signed
+		cfg.CACert = resolvePath(path, v.GetString("RpcSet.TLS.CertFile"))
+	}
+	cfg.RPC = scheme + "://" + net.JoinHostPort(host, port) + "/"
+	return cfg, nil
+}
+
+// resolvePath resolves a path which is relative to directory of config file
+func resolvePath(configPath string, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(configPath), path)
+}
+
+// normalizeRPC validates rpc url and adds scheme and trailing slash, host:port is accepted too
+func normalizeRPC(rpc string, useTLS bool) (string, error) {
+	if rpc == "" {
+		rpc = defaultRPC
+	}
+	if !strings.Contains(rpc, "://") {
+		rpc = "http://" + rpc
+	}
+	u, err := url.Parse(rpc)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid rpc url %s", rpc)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("rpc url scheme should be http or https")
+	}
+	if useTLS {
+		u.Scheme = "https"
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return u.String(), nil
+}
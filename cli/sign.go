@@ -0,0 +1,349 @@
+package main
+
+import (
+	b64 "encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+
+	address "badcoin/src/helper/address"
+	"badcoin/src/node"
+	"badcoin/src/transaction"
+	"badcoin/src/wallet"
+
+	"github.com/urfave/cli"
+)
+
+// KeyGen --out <key file> [--mnemonic words | --new-mnemonic] [--path path],
+// generates a keypair offline or derives it from a mnemonic and saves it in a key file
+func KeyGen(c *cli.Context) error {
+	path := c.String("out")
+	if path == "" {
+		return fmt.Errorf("key file must be specified with --out")
+	}
+	mnemonic := c.String("mnemonic")
+	if c.Bool("new-mnemonic") {
+		generated, err := wallet.NewMnemonic()
+		if err != nil {
+			return err
+		}
+		mnemonic = generated
+		fmt.Println("mnemonic (write it down, it recovers all keys):")
+		fmt.Println(mnemonic)
+	}
+	w := wallet.NewWallet()
+	if mnemonic != "" {
+		seed, err := wallet.SeedFromMnemonic(mnemonic, "")
+		if err != nil {
+			return err
+		}
+		key, err := wallet.NewMasterKey(seed).Derive(c.String("path"))
+		if err != nil {
+			return err
+		}
+		if w, err = key.Wallet(); err != nil {
+			return err
+		}
+	}
+	if err := wallet.SaveKeyFile(path, w); err != nil {
+		return err
+	}
+	fmt.Println("key is saved in", path)
+	return printJSON(map[string]string{
+		"Address":   w.GetStringAddress(),
+		"PublicKey": b64.StdEncoding.EncodeToString(w.PublicKey),
+	})
+}
+
+// DeriveAddress --key <key file> | --pubkey <base64 pubkey>, derives address offline
+func DeriveAddress(c *cli.Context) error {
+	pubKey, err := publicKeyOf(c)
+	if err != nil {
+		return err
+	}
+	fmt.Println(address.ToString(address.FromPublicKey(pubKey)))
+	return nil
+}
+
+// CreateMultisig --threshold m --key <key file>... | --pubkey <base64 pubkey>... [--out file],
+// creates a m-of-n multisig offline and prints its address, multisig file is needed to build its transactions
+func CreateMultisig(c *cli.Context) error {
+	var pubKeys [][]byte
+	for _, path := range c.StringSlice("key") {
+		w, err := wallet.LoadKeyFile(path)
+		if err != nil {
+			return err
+		}
+		pubKeys = append(pubKeys, w.PublicKey)
+	}
+	for _, encoded := range c.StringSlice("pubkey") {
+		pubKey, err := b64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(pubKey) == 0 {
+			return fmt.Errorf("public key should be base64 encoded")
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+	ms, err := transaction.NewMultisig(c.Int("threshold"), pubKeys)
+	if err != nil {
+		return err
+	}
+	if path := c.String("out"); path != "" {
+		data, err := json.MarshalIndent(ms, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			return err
+		}
+		fmt.Println("multisig is saved in", path)
+	}
+	fmt.Printf("%d of %d multisig address: %s\n", ms.Threshold, len(ms.PublicKeys), ms.Address())
+	return nil
+}
+
+// BuildTx <to> <value> --key <key file> | --pubkey <base64 pubkey> | --multisig <multisig file>
+// [--nonce n | --fetch-nonce] [--chain-id id] [--valid-after height] [--expires-at height] [--data] [--out file]
+// builds an unsigned transaction, key file is only used for its public key.
+// Chain id of node's network is fetched if it isn't given
+func BuildTx(c *cli.Context) error {
+	if len(c.Args()) < 2 {
+		return fmt.Errorf("to and amount must be specified")
+	}
+	to := c.Args()[0]
+	value, err := strconv.ParseFloat(c.Args()[1], 64)
+	if err != nil || value <= 0 {
+		return fmt.Errorf("invalid amount %s", c.Args()[1])
+	}
+	if !address.ValidateAddress(to) {
+		return fmt.Errorf("invalid address %s", to)
+	}
+	var ms *transaction.Multisig
+	var pubKey []byte
+	var from string
+	if c.String("multisig") != "" {
+		if ms, err = readMultisig(c.String("multisig")); err != nil {
+			return err
+		}
+		from = ms.Address()
+	} else {
+		if pubKey, err = publicKeyOf(c); err != nil {
+			return err
+		}
+		from = address.ToString(address.FromPublicKey(pubKey))
+	}
+
+	nonce := c.Uint64("nonce")
+	if c.Bool("fetch-nonce") {
+		var res node.AccountResponse
+		if err := Get("address/"+url.PathEscape(from), &res); err != nil {
+			return err
+		}
+		nonce = res.NextNonce
+	}
+	if nonce == 0 {
+		return fmt.Errorf("nonce must be specified with --nonce or --fetch-nonce")
+	}
+
+	chainID := c.Uint64("chain-id")
+	if chainID == 0 {
+		var res node.GetInfoResponse
+		if err := Get("info", &res); err != nil {
+			return err
+		}
+		chainID = res.ChainID
+	}
+
+	var tx *transaction.Transaction
+	if ms != nil {
+		tx = transaction.NewMultisigTransaction(chainID, ms, nonce, to, value, c.String("data"))
+	} else {
+		tx = transaction.NewTransaction(chainID, pubKey, nonce, to, value, c.String("data"))
+	}
+	tx.SetValidity(c.Uint64("valid-after"), c.Uint64("expires-at"))
+	return writeTx(c.String("out"), tx)
+}
+
+// SignTx <unsigned tx file> --key <key file> [--out file] [--raw], signs a transaction offline.
+// A multisig transaction gets signature of the key, it is partially signed until threshold is reached
+func SignTx(c *cli.Context) error {
+	if len(c.Args()) < 1 {
+		return fmt.Errorf("transaction file must be specified")
+	}
+	tx, err := readTx(c.Args()[0])
+	if err != nil {
+		return err
+	}
+	if c.String("key") == "" {
+		return fmt.Errorf("key file must be specified with --key")
+	}
+	w, err := wallet.LoadKeyFile(c.String("key"))
+	if err != nil {
+		return err
+	}
+	if tx.Multisig != nil {
+		if err := tx.SignMultisig(w.PrivateKey); err != nil {
+			return err
+		}
+		return writeMultisigTx(c, tx)
+	}
+	if tx.From != w.GetStringAddress() {
+		return fmt.Errorf("transaction is sent from %s but key belongs to %s", tx.From, w.GetStringAddress())
+	}
+	tx.Sign(w.PrivateKey)
+	tx.UpdateHash()
+	if !tx.VerifySignature() {
+		return fmt.Errorf("signing transaction failed")
+	}
+	if c.Bool("raw") {
+		fmt.Println(tx.EncodeRaw())
+		return nil
+	}
+	return writeTx(c.String("out"), tx)
+}
+
+// CombineTx <tx file>... [--out file] [--raw], combines signatures of partially signed copies of a multisig transaction
+func CombineTx(c *cli.Context) error {
+	if len(c.Args()) < 1 {
+		return fmt.Errorf("transaction files must be specified")
+	}
+	tx, err := readTx(c.Args()[0])
+	if err != nil {
+		return err
+	}
+	if tx.Multisig == nil {
+		return fmt.Errorf("%s is not a multisig transaction", c.Args()[0])
+	}
+	for _, path := range c.Args()[1:] {
+		other, err := readTx(path)
+		if err != nil {
+			return err
+		}
+		if err := tx.CombineSignatures(other); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return writeMultisigTx(c, tx)
+}
+
+// writeMultisigTx writes a partially or fully signed multisig transaction, raw form is only printed when it is fully signed
+func writeMultisigTx(c *cli.Context, tx *transaction.Transaction) error {
+	signed := tx.VerifySignature()
+	fmt.Printf("%d of %d required signatures\n", tx.SignatureCount(), tx.Multisig.Threshold)
+	if c.Bool("raw") {
+		if !signed {
+			return fmt.Errorf("transaction needs more signatures")
+		}
+		fmt.Println(tx.EncodeRaw())
+		return nil
+	}
+	return writeTx(c.String("out"), tx)
+}
+
+// Broadcast <signed tx file>, sends a transaction which is signed offline as raw transaction
+func Broadcast(c *cli.Context) error {
+	if len(c.Args()) < 1 {
+		return fmt.Errorf("transaction file must be specified")
+	}
+	tx, err := readTx(c.Args()[0])
+	if err != nil {
+		return err
+	}
+	if !tx.VerifySignature() {
+		return fmt.Errorf("transaction is not signed or its signature is invalid")
+	}
+	return sendRaw(tx.EncodeRaw())
+}
+
+// SendRawTx <hex or base64 tx>, sends a serialized signed transaction as-is
+func SendRawTx(c *cli.Context) error {
+	if len(c.Args()) < 1 {
+		return fmt.Errorf("raw transaction must be specified")
+	}
+	return sendRaw(c.Args()[0])
+}
+
+func sendRaw(raw string) error {
+	var res node.SendTxResponse
+	err := Call("tx/raw", map[string]string{
+		"tx": raw,
+	}, &res)
+	if err != nil {
+		return err
+	}
+	return printJSON(res)
+}
+
+// publicKeyOf returns public key of --key file or --pubkey flag
+func publicKeyOf(c *cli.Context) ([]byte, error) {
+	if c.String("key") != "" {
+		w, err := wallet.LoadKeyFile(c.String("key"))
+		if err != nil {
+			return nil, err
+		}
+		return w.PublicKey, nil
+	}
+	if c.String("pubkey") == "" {
+		return nil, fmt.Errorf("key file or public key must be specified")
+	}
+	pubKey, err := b64.StdEncoding.DecodeString(c.String("pubkey"))
+	if err != nil || len(pubKey) == 0 {
+		return nil, fmt.Errorf("public key should be base64 encoded")
+	}
+	return pubKey, nil
+}
+
+func readMultisig(path string) (*transaction.Multisig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var ms transaction.Multisig
+	if err := json.Unmarshal(data, &ms); err != nil {
+		return nil, err
+	}
+	if !ms.Valid() {
+		return nil, fmt.Errorf("multisig of %s is not valid", path)
+	}
+	return &ms, nil
+}
+
+// readTx reads a transaction file, files are json so they can be reviewed before signing
+func readTx(path string) (*transaction.Transaction, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tx transaction.Transaction
+	if err := json.Unmarshal(data, &tx); err != nil {
+		return nil, err
+	}
+	tx.UpdateHash()
+	return &tx, nil
+}
+
+// writeTx writes transaction to a file or stdout
+func writeTx(path string, tx *transaction.Transaction) error {
+	if path == "" {
+		return printJSON(tx)
+	}
+	data, err := json.MarshalIndent(tx, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	fmt.Println("transaction is saved in", path)
+	return nil
+}
+
+func printJSON(v interface{}) error {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
@@ -7,9 +7,41 @@ import (
 	server "badcoin/src/server"
 	storage "badcoin/src/storage"
 	"context"
+	"flag"
+	"os"
 	"path/filepath"
 )
 
+// initChain handles `badcoin init --genesis <genesis.json>`
+// it writes genesis block and initial accounts into a fresh data directory
+func initChain(args []string) {
+	flags := flag.NewFlagSet("init", flag.ExitOnError)
+	genesisFile := flags.String("genesis", "", "genesis json file (default: genesis section of config file)")
+	configDir := flags.String("config", "./config/", "configurations directory")
+	flags.Parse(args)
+
+	configFilePath, _ := filepath.Abs(*configDir)
+	Configs, errConfig := config.Init(configFilePath)
+	if errConfig != nil {
+		panic("load configuration failed")
+	}
+	if *genesisFile != "" {
+		genesis, errGenesis := config.LoadGenesis(*genesisFile)
+		if errGenesis != nil {
+			logger.Error("loading genesis file failed: ", errGenesis)
+			os.Exit(1)
+		}
+		Configs.Genesis = *genesis
+	}
+
+	genesis, errInit := node.InitChain(Configs)
+	if errInit != nil {
+		logger.Error("init chain failed: ", errInit)
+		os.Exit(1)
+	}
+	logger.Info("chain initiated, genesis hash: ", genesis.GetHash().String())
+}
+
 func main() {
 	// cli := new(cli.CLI)
 	// cli.Run()
@@ -18,6 +50,11 @@ func main() {
 	logger.Init(false)
 	logger.Info("logger initiated")
 
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		initChain(os.Args[2:])
+		return
+	}
+
 	//init configs
 	logger.Info("loading configurations...")
 	configFilePath, _ := filepath.Abs("./config/")
@@ -46,7 +83,9 @@ func main() {
 		node.StartMiner(Configs)
 	}
 	//Start server
-	srv := server.CreateNewServer(ctx, node, Configs.RpcSet.Port)
-	srv.ListenAndServe()
+	srv := server.CreateNewServer(ctx, node, Configs.RpcSet)
+	if err := server.ListenAndServe(srv, Configs); err != nil {
+		logger.Error("rpc server stopped: ", err)
+	}
 
 }
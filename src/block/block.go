@@ -1,6 +1,7 @@
 package block
 
 import (
+	codec "badcoin/src/helper/codec"
 	hash "badcoin/src/helper/hash"
 	"badcoin/src/transaction"
 	"encoding/json"
@@ -66,29 +67,31 @@ func (b *Block) String() string {
 	return strings.Join(lines, "\n")
 }
 
+// Serialize returns canonical encoding of block, it is used for gossip and storage
 func (b *Block) Serialize() []byte {
-	data, err := json.Marshal(b)
-	if err != nil {
-		panic(err)
-	}
-	return data
+	e := codec.NewEncoder()
+	b.Encode(e)
+	return e.Data()
 }
 
+// Serialize returns canonical encoding of header, block hash is its hash
 func (bh *BlockHeader) Serialize() []byte {
-	data, err := json.Marshal(bh)
-	if err != nil {
-		panic(err)
-	}
-	return data
+	e := codec.NewEncoder()
+	bh.Encode(e)
+	return e.Data()
 }
 
+// DeserializeBlock decodes a canonically encoded block, other encodings of it are rejected
 func DeserializeBlock(buf []byte) (*Block, error) {
-	var blk Block
-	err := json.Unmarshal(buf, &blk)
+	d := codec.NewDecoder(buf)
+	blk, err := DecodeBlock(d)
 	if err != nil {
 		return nil, err
 	}
-	return &blk, nil
+	if err := d.Done(); err != nil {
+		return nil, err
+	}
+	return blk, nil
 }
 
 func (b *Block) CalcHash() hash.Hash {
@@ -1,10 +1,17 @@
 package block
 
 import (
+	"bytes"
 	"fmt"
+	"math/big"
 	"os"
 	"path/filepath"
 	"testing"
+
+	"badcoin/src/transaction"
+
+	"github.com/ipfs/go-cid"
+	multihash "github.com/multiformats/go-multihash"
 )
 
 func TestHashBlock(t *testing.T) {
@@ -37,3 +44,43 @@ func TestMessage(t *testing.T) {
 	}
 	fmt.Println("found msg: ", msg)
 }
+
+func TestEncoding(t *testing.T) {
+	prevCid, _ := cid.Prefix{Version: 1, Codec: cid.DagCBOR, MhType: multihash.SHA2_256, MhLength: -1}.Sum([]byte("prev"))
+	tx := &transaction.Transaction{Nonce: 1, Timestamp: 42, From: "1From", To: "1To", Value: 2.5}
+	tx.UpdateHash()
+	blk := &Block{
+		Height:       2,
+		PrevCid:      &prevCid,
+		Header:       BlockHeader{Version: "0.0.1", Timestamp: 42, Nonce: -1, Miner: "1Miner", Difficulty: 1, Memo: "memo"},
+		Reward:       big.NewFloat(50),
+		TxsCount:     1,
+		Transactions: []*transaction.Transaction{tx},
+	}
+	blk.UpdateHash()
+
+	for _, b := range []*Block{blk, {Header: BlockHeader{Timestamp: 1}}} {
+		data := b.Serialize()
+		decoded, err := DeserializeBlock(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Hash != b.GetHash() || !bytes.Equal(decoded.Serialize(), data) {
+			t.Error("block round trip failed")
+		}
+		if _, err := DeserializeBlock(append(data, 0)); err == nil {
+			t.Error("trailing data should be rejected")
+		}
+	}
+	decoded, _ := DeserializeBlock(blk.Serialize())
+	if !decoded.PrevCid.Equals(prevCid) || decoded.Reward.Cmp(blk.Reward) != 0 || decoded.Transactions[0].GetTxid() != tx.GetTxid() {
+		t.Error("decoded block doesn't match")
+	}
+
+	// header hash covers every field
+	other := *blk
+	other.Header.Memo = "other"
+	if other.CalcHash() == blk.CalcHash() {
+		t.Error("header hash should cover memo")
+	}
+}
@@ -0,0 +1,167 @@
+package block
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+
+	codec "badcoin/src/helper/codec"
+	"badcoin/src/transaction"
+
+	"github.com/ipfs/go-cid"
+)
+
+// EncodingVersion is version of canonical encoding of blocks and headers
+const EncodingVersion = 1
+
+// number of encoded fields of a header and a block, including version
+const (
+	headerFields = 9
+	blockFields  = 7
+)
+
+// Encode writes canonical encoding of header, its hash is the hash of this encoding
+func (bh *BlockHeader) Encode(e *codec.Encoder) {
+	e.Array(headerFields)
+	e.Uint(EncodingVersion)
+	e.Text(bh.Version)
+	e.ByteString(bh.PrevHash[:])
+	e.ByteString(bh.MerkleRoot[:])
+	e.Int(bh.Timestamp)
+	e.Int(bh.Nonce)
+	e.Text(bh.Miner)
+	e.Uint(uint64(bh.Difficulty))
+	e.Text(bh.Memo)
+}
+
+// DecodeHeader reads a canonically encoded header
+func DecodeHeader(d *codec.Decoder) (*BlockHeader, error) {
+	if err := decodeVersion(d, headerFields); err != nil {
+		return nil, err
+	}
+	var bh BlockHeader
+	var err error
+	if bh.Version, err = d.Text(); err != nil {
+		return nil, err
+	}
+	if err = d.FixedBytes(bh.PrevHash[:]); err != nil {
+		return nil, err
+	}
+	if err = d.FixedBytes(bh.MerkleRoot[:]); err != nil {
+		return nil, err
+	}
+	if bh.Timestamp, err = d.Int(); err != nil {
+		return nil, err
+	}
+	if bh.Nonce, err = d.Int(); err != nil {
+		return nil, err
+	}
+	if bh.Miner, err = d.Text(); err != nil {
+		return nil, err
+	}
+	if bh.Difficulty, err = d.Uint32(); err != nil {
+		return nil, err
+	}
+	if bh.Memo, err = d.Text(); err != nil {
+		return nil, err
+	}
+	return &bh, nil
+}
+
+// Encode writes canonical encoding of block. Hash isn't encoded, it is hash of header.
+// Reward is encoded as a 64 bits float
+func (b *Block) Encode(e *codec.Encoder) {
+	e.Array(blockFields)
+	e.Uint(EncodingVersion)
+	e.Uint(b.Height)
+	if b.PrevCid == nil {
+		e.Null()
+	} else {
+		e.ByteString(b.PrevCid.Bytes())
+	}
+	b.Header.Encode(e)
+	if b.Reward == nil {
+		e.Null()
+	} else {
+		reward, _ := b.Reward.Float64()
+		e.Float(reward)
+	}
+	e.Uint(b.TxsCount)
+	e.Array(len(b.Transactions))
+	for _, tx := range b.Transactions {
+		tx.Encode(e)
+	}
+}
+
+// DecodeBlock reads a canonically encoded block and sets its hash
+func DecodeBlock(d *codec.Decoder) (*Block, error) {
+	if err := decodeVersion(d, blockFields); err != nil {
+		return nil, err
+	}
+	var b Block
+	var err error
+	if b.Height, err = d.Uint(); err != nil {
+		return nil, err
+	}
+	if !d.Null() {
+		data, err := d.ByteString()
+		if err != nil {
+			return nil, err
+		}
+		prevCid, err := cid.Cast(data)
+		if err != nil {
+			return nil, err
+		}
+		// a cid can be parsed from bytes which it doesn't encode to
+		if !bytes.Equal(prevCid.Bytes(), data) {
+			return nil, codec.ErrNonCanonical
+		}
+		b.PrevCid = &prevCid
+	}
+	header, err := DecodeHeader(d)
+	if err != nil {
+		return nil, err
+	}
+	b.Header = *header
+	if !d.Null() {
+		reward, err := d.Float()
+		if err != nil {
+			return nil, err
+		}
+		if math.IsNaN(reward) {
+			return nil, codec.ErrUnexpected
+		}
+		b.Reward = new(big.Float).SetFloat64(reward)
+	}
+	if b.TxsCount, err = d.Uint(); err != nil {
+		return nil, err
+	}
+	n, err := d.ArrayLen()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < n; i++ {
+		tx, err := transaction.DecodeTransaction(d)
+		if err != nil {
+			return nil, err
+		}
+		b.Transactions = append(b.Transactions, tx)
+	}
+
+	b.UpdateHash()
+	return &b, nil
+}
+
+func decodeVersion(d *codec.Decoder, fields int) error {
+	if err := d.Array(fields); err != nil {
+		return err
+	}
+	version, err := d.Uint()
+	if err != nil {
+		return err
+	}
+	if version != EncodingVersion {
+		return codec.ErrVersion
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+//go:build go1.18
+// +build go1.18
+
+package block
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"badcoin/src/transaction"
+)
+
+// FuzzDeserializeBlock checks that decoding doesn't panic and only canonical encodings are accepted
+func FuzzDeserializeBlock(f *testing.F) {
+	tx := &transaction.Transaction{Nonce: 1, Timestamp: 42, From: "1From", To: "1To", Value: 1.5}
+	blk := &Block{
+		Height:       1,
+		Header:       BlockHeader{Version: "0.0.1", Timestamp: 42, Miner: "1Miner"},
+		Reward:       big.NewFloat(100),
+		TxsCount:     1,
+		Transactions: []*transaction.Transaction{tx},
+	}
+	f.Add(blk.Serialize())
+	f.Add((&Block{}).Serialize())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		decoded, err := DeserializeBlock(data)
+		if err != nil {
+			return
+		}
+		if !bytes.Equal(decoded.Serialize(), data) {
+			t.Fatalf("decoded block encodes differently: %x", data)
+		}
+	})
+}
@@ -1,11 +1,13 @@
 package blockchain
 
 import (
+	block "badcoin/src/block"
 	errors "badcoin/src/helper/error"
 	"badcoin/src/transaction"
 	"encoding/json"
 	"math/big"
 
+	cid "github.com/ipfs/go-cid"
 	"github.com/syndtr/goleveldb/leveldb"
 )
 
@@ -160,3 +162,108 @@ func (chain *Blockchain) UpdateAccounts(values map[string]*big.Float) error {
 
 	return nil
 }
+
+// accountsUndo is the change of accounts by a block. It is stored with account updates,
+// so a block updates accounts only once and its updates can be reverted when it is disconnected
+type accountsUndo struct {
+	Values map[string]float64
+	Nonces []string
+}
+
+// keys of undo records are prefixed, so they don't collide with account addresses
+var accountsUndoPrefix = []byte("undo:")
+
+func accountsUndoKey(blkcid *cid.Cid) []byte {
+	return append(append([]byte{}, accountsUndoPrefix...), blkcid.Bytes()...)
+}
+
+// connectAccounts applies transactions and reward of a block to accounts in one batch
+func (chain *Blockchain) connectAccounts(blk *block.Block, blkcid *cid.Cid) error {
+	key := accountsUndoKey(blkcid)
+	if applied, err := chain.Accounts.Has(key, nil); err != nil || applied {
+		return err
+	}
+	values, err := chain.CalcAccountsUpdates(blk.Transactions)
+	if err != nil {
+		return err
+	}
+	undo := accountsUndo{Values: make(map[string]float64)}
+	for addr, val := range values {
+		value, _ := val.Float64()
+		undo.Values[addr] += value
+		undo.Nonces = append(undo.Nonces, addr)
+	}
+	if reward, _ := blk.Reward.Float64(); reward != 0 {
+		undo.Values[blk.Header.Miner] += reward
+	}
+	data, err := json.Marshal(&undo)
+	if err != nil {
+		return err
+	}
+
+	batch := new(leveldb.Batch)
+	if err := chain.changeAccounts(batch, &undo, false); err != nil {
+		return err
+	}
+	batch.Put(key, data)
+	return chain.Accounts.Write(batch, nil)
+}
+
+// disconnectAccounts reverts account updates of a block which is not in main chain anymore
+func (chain *Blockchain) disconnectAccounts(blkcid *cid.Cid) error {
+	key := accountsUndoKey(blkcid)
+	data, err := chain.Accounts.Get(key, nil)
+	if err != nil {
+		if err == leveldb.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+	var undo accountsUndo
+	if err := json.Unmarshal(data, &undo); err != nil {
+		return err
+	}
+
+	batch := new(leveldb.Batch)
+	if err := chain.changeAccounts(batch, &undo, true); err != nil {
+		return err
+	}
+	batch.Delete(key)
+	return chain.Accounts.Write(batch, nil)
+}
+
+// changeAccounts puts accounts which are changed by undo record into batch,
+// reverted balances are not checked, as accounts may be spent in new chain
+func (chain *Blockchain) changeAccounts(batch *leveldb.Batch, undo *accountsUndo, revert bool) error {
+	accounts := make(map[string]*Account)
+	for addr, value := range undo.Values {
+		acc, err := chain.FetchAccountDetails(addr)
+		if err != nil {
+			if err != leveldb.ErrNotFound {
+				return err
+			}
+			acc = &Account{Address: addr}
+		}
+		if revert {
+			value = -value
+		}
+		res := new(big.Float).Add(&acc.Balance, big.NewFloat(value))
+		if !revert && res.Sign() < 0 {
+			return errors.NotEnoughAccountBalance
+		}
+		acc.Balance.Set(res)
+		accounts[addr] = acc
+	}
+	for _, addr := range undo.Nonces {
+		acc := accounts[addr]
+		if !revert {
+			acc.Nonce++
+		} else if acc.Nonce > 0 {
+			acc.Nonce--
+		}
+	}
+	for _, acc := range accounts {
+		batch.Put([]byte(acc.Address), acc.Serialize())
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package blockchain
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"sync/atomic"
+
+	block "badcoin/src/block"
+	errors "badcoin/src/helper/error"
+	logger "badcoin/src/helper/logger"
+
+	cid "github.com/ipfs/go-cid"
+	leveldb "github.com/syndtr/goleveldb/leveldb"
+	util "github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// directions of address history entries
+const (
+	DirectionSent     = "sent"
+	DirectionReceived = "received"
+	DirectionReward   = "reward"
+)
+
+// AddressTx is an entry of address history, Txid is empty for mining rewards
+type AddressTx struct {
+	Txid      string
+	Direction string
+	Value     float64
+	From      string
+	To        string
+	Height    uint64
+	BlockCid  string
+	Index     int
+	Timestamp int64
+}
+
+var addrIndexPrefix = []byte("addr:")
+
+// addrIndexBuiltKey marks that all blocks of main chain are indexed,
+// it is removed when node runs with disabled index, as its blocks are not indexed
+var addrIndexBuiltKey = []byte("addrindex:built")
+
+// addrIndexPosLen is the length of position of an entry after address in its key
+const addrIndexPosLen = 13
+
+// addrIndexPrefixOf is the key prefix of an address history, address is terminated by zero byte
+func addrIndexPrefixOf(addr string) []byte {
+	key := append(append([]byte{}, addrIndexPrefix...), addr...)
+	return append(key, 0)
+}
+
+// addrIndexKey orders history entries of an address by height and position in block
+func addrIndexKey(addr string, height uint64, index int, direction string) []byte {
+	key := addrIndexPrefixOf(addr)
+	var pos [addrIndexPosLen]byte
+	binary.BigEndian.PutUint64(pos[:8], height)
+	binary.BigEndian.PutUint32(pos[8:12], uint32(index))
+	pos[12] = direction[0]
+	return append(key, pos[:]...)
+}
+
+// AddressIndexEnabled checks whether address history is indexed
+func (chain *Blockchain) AddressIndexEnabled() bool {
+	return chain.Configs != nil && chain.Configs.Storage.AddressIndex
+}
+
+// initAddressIndex rebuilds address index in background if it is enabled after blocks are stored,
+// history is not served until rebuild is finished
+func (chain *Blockchain) initAddressIndex() error {
+	if !chain.AddressIndexEnabled() {
+		return chain.BlockIndex.Delete(addrIndexBuiltKey, nil)
+	}
+	if built, err := chain.BlockIndex.Has(addrIndexBuiltKey, nil); err != nil || built {
+		return err
+	}
+
+	// entries of blocks which are disconnected while index was disabled are removed
+	iter := chain.BlockIndex.NewIterator(util.BytesPrefix(addrIndexPrefix), nil)
+	batch := new(leveldb.Batch)
+	for iter.Next() {
+		batch.Delete(append([]byte{}, iter.Key()...))
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	if err := chain.BlockIndex.Write(batch, nil); err != nil {
+		return err
+	}
+
+	atomic.StoreInt32(&chain.addrIndexBuilding, 1)
+	go func() {
+		if err := chain.rebuildAddressIndex(chain.GetChainTip().Height); err != nil {
+			logger.Error("rebuilding address index failed: ", err)
+			return
+		}
+		atomic.StoreInt32(&chain.addrIndexBuilding, 0)
+	}()
+	return nil
+}
+
+// rebuildAddressIndex indexes main chain blocks up to height, newer blocks are indexed when they are connected
+func (chain *Blockchain) rebuildAddressIndex(to uint64) error {
+	logger.Info("rebuilding address index up to height ", to)
+	for height := uint64(1); height <= to; height++ {
+		blk, err := chain.GetBlock(height)
+		if err != nil {
+			return err
+		}
+		if blk == nil {
+			return errors.BlockNotFount
+		}
+		if err := chain.indexAddresses(blk, chain.GetBlockCid(blk)); err != nil {
+			return err
+		}
+	}
+	logger.Info("address index is rebuilt")
+	return chain.BlockIndex.Put(addrIndexBuiltKey, []byte{1}, nil)
+}
+
+// addressEntries returns history entries of block transactions and mining reward
+func addressEntries(blk *block.Block, blkcid *cid.Cid) []*AddressTx {
+	var entries []*AddressTx
+	for i, tx := range blk.Transactions {
+		entry := AddressTx{
+			Txid:      tx.GetTxidString(),
+			Value:     tx.Value,
+			From:      tx.From,
+			To:        tx.To,
+			Height:    blk.Height,
+			BlockCid:  blkcid.String(),
+			Index:     i,
+			Timestamp: tx.Timestamp,
+		}
+		sent := entry
+		sent.Direction = DirectionSent
+		received := entry
+		received.Direction = DirectionReceived
+		entries = append(entries, &sent, &received)
+	}
+	if blk.Reward != nil && blk.Header.Miner != "" {
+		reward, _ := blk.Reward.Float64()
+		if reward > 0 {
+			entries = append(entries, &AddressTx{
+				Direction: DirectionReward,
+				Value:     reward,
+				To:        blk.Header.Miner,
+				Height:    blk.Height,
+				BlockCid:  blkcid.String(),
+				Index:     len(blk.Transactions),
+				Timestamp: blk.Header.Timestamp,
+			})
+		}
+	}
+	return entries
+}
+
+func (entry *AddressTx) address() string {
+	if entry.Direction == DirectionSent {
+		return entry.From
+	}
+	return entry.To
+}
+
+// indexAddresses stores history entries of a connected block
+func (chain *Blockchain) indexAddresses(blk *block.Block, blkcid *cid.Cid) error {
+	if !chain.AddressIndexEnabled() {
+		return nil
+	}
+	batch := new(leveldb.Batch)
+	for _, entry := range addressEntries(blk, blkcid) {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		batch.Put(addrIndexKey(entry.address(), entry.Height, entry.Index, entry.Direction), data)
+	}
+	return chain.BlockIndex.Write(batch, nil)
+}
+
+// unindexAddresses removes history entries of a disconnected block
+func (chain *Blockchain) unindexAddresses(blk *block.Block, blkcid *cid.Cid) error {
+	if !chain.AddressIndexEnabled() {
+		return nil
+	}
+	batch := new(leveldb.Batch)
+	for _, entry := range addressEntries(blk, blkcid) {
+		key := addrIndexKey(entry.address(), entry.Height, entry.Index, entry.Direction)
+		data, err := chain.BlockIndex.Get(key, nil)
+		if err != nil {
+			continue
+		}
+		var stored AddressTx
+		// a block of new chain may have the same height
+		if json.Unmarshal(data, &stored) == nil && stored.BlockCid == entry.BlockCid {
+			batch.Delete(key)
+		}
+	}
+	return chain.BlockIndex.Write(batch, nil)
+}
+
+// GetAddressHistory returns a page of history of an address, newest first. Page starts after cursor,
+// or at newest entry if it is empty, and cursor of next page is returned, it is empty on the last page
+func (chain *Blockchain) GetAddressHistory(addr string, cursor string, limit int) ([]*AddressTx, string, error) {
+	if !chain.AddressIndexEnabled() {
+		return nil, "", errors.AddressIndexDisabled
+	}
+	if atomic.LoadInt32(&chain.addrIndexBuilding) != 0 {
+		return nil, "", errors.AddressIndexBuilding
+	}
+	prefix := addrIndexPrefixOf(addr)
+	rng := util.BytesPrefix(prefix)
+	if cursor != "" {
+		pos, err := hex.DecodeString(cursor)
+		if err != nil || len(pos) != addrIndexPosLen {
+			return nil, "", errors.InvalidHistoryCursor
+		}
+		// iterator seeks to cursor key, entries before it are not read
+		rng.Limit = append(append([]byte{}, prefix...), pos...)
+	}
+	iter := chain.BlockIndex.NewIterator(rng, nil)
+	defer iter.Release()
+
+	history := make([]*AddressTx, 0)
+	next := ""
+	for ok := iter.Last(); ok; ok = iter.Prev() {
+		if len(history) == limit {
+			next = hex.EncodeToString(history[limit-1].position())
+			break
+		}
+		var entry AddressTx
+		if err := json.Unmarshal(iter.Value(), &entry); err != nil {
+			return nil, "", err
+		}
+		history = append(history, &entry)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, "", err
+	}
+	return history, next, nil
+}
+
+// position returns position of entry in history of its address, it is the cursor of entries before it
+func (entry *AddressTx) position() []byte {
+	key := addrIndexKey(entry.address(), entry.Height, entry.Index, entry.Direction)
+	return key[len(key)-addrIndexPosLen:]
+}
@@ -2,10 +2,11 @@ package blockchain
 
 import (
 	"context"
+	"encoding/binary"
 	"math"
 	"math/big"
 	"path/filepath"
-	"time"
+	"sync"
 
 	config "badcoin/src/config"
 	number "badcoin/src/helper/number"
@@ -17,11 +18,12 @@ import (
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 
 	//nonerouting "github.com/ipfs/go-ipfs-routing/none"
-	cbor "github.com/ipfs/go-ipld-cbor"
+	blocks "github.com/ipfs/go-block-format"
 	multihash "github.com/multiformats/go-multihash"
 	leveldb "github.com/syndtr/goleveldb/leveldb"
 
 	block "badcoin/src/block"
+	event "badcoin/src/event"
 	errors "badcoin/src/helper/error"
 	hash "badcoin/src/helper/hash"
 	logger "badcoin/src/helper/logger"
@@ -31,22 +33,30 @@ import (
 )
 
 type Blockchain struct {
-	Head         *block.Block
+	Head         *block.Block //read it by GetChainTip, it is changed while blocks are added
 	GenesisBlock *block.Block
 	BlockService blockservice.BlockService //block store to fetch blocks from nodes
 	Blockstore   blockstore.Blockstore     //block store to fetch data locally
 	BlockIndex   *leveldb.DB
 	Accounts     *leveldb.DB
 	Configs      *config.Configurations
+	Events       *event.Bus //chain events are published on it if it is set
+	headMutex    sync.RWMutex
+
+	addrIndexBuilding int32 //history is not served while address index is rebuilt
 }
 
-func Init() {
-	// We need to Register our types with the cbor.
-	// So, it pregenerates serializers for these types.
-	cbor.RegisterCborType(big.Float{})
-	cbor.RegisterCborType(block.BlockHeader{})
-	cbor.RegisterCborType(block.Block{})
-	cbor.RegisterCborType(transaction.Transaction{})
+// blockPrefix is cid prefix of stored blocks, blocks are stored in their canonical encoding
+var blockPrefix = cid.Prefix{Version: 1, Codec: cid.DagCBOR, MhType: multihash.BLAKE2B_MIN + 31, MhLength: 32}
+
+// encodeBlock returns block of block store with canonical encoding of blk and its cid
+func encodeBlock(blk *block.Block) (blocks.Block, error) {
+	data := blk.Serialize()
+	blkcid, err := blockPrefix.Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	return blocks.NewBlockWithCid(data, blkcid)
 }
 
 //Find latest Height
@@ -73,17 +83,17 @@ func LoadBlockchain(chainblockstore blockstore.Blockstore) (*block.Block, *block
 				if err != nil {
 					return nil, nil, err
 				}
-				var blk block.Block
-				if err := cbor.DecodeInto(data.RawData(), &blk); err != nil {
+				blk, err := block.DeserializeBlock(data.RawData())
+				if err != nil {
 					return nil, nil, err
 				}
 				loadedblocks++
-				if blk.Height > lastHeight {
+				if head == nil || blk.Height > lastHeight {
 					lastHeight = blk.Height
-					head = &blk
+					head = blk
 				}
 				if blk.Height == 0 {
-					genesis = &blk
+					genesis = blk
 				}
 			}
 		case <-ctx.Done():
@@ -94,14 +104,13 @@ func LoadBlockchain(chainblockstore blockstore.Blockstore) (*block.Block, *block
 
 }
 
-func NewBlockchain(h host.Host, chainblockstore blockstore.Blockstore, bswap exchange.Interface, configs *config.Configurations) *Blockchain {
+//openChainDBs opens block index and accounts dbs
+func openChainDBs(configs *config.Configurations) (*leveldb.DB, *leveldb.DB, error) {
 	//create block index db
 	blockindexDBPath := "data/" + configs.Storage.DBName + "_" + configs.ID + "_bi"
-	var errIndexDB error
 	blockindex, errIndexDB := leveldb.OpenFile(blockindexDBPath, nil)
 	if errIndexDB != nil {
-		logger.Error(errIndexDB)
-		panic(errIndexDB)
+		return nil, nil, errIndexDB
 	}
 
 	//Accounts db
@@ -109,8 +118,17 @@ func NewBlockchain(h host.Host, chainblockstore blockstore.Blockstore, bswap exc
 	accFullpath, _ := filepath.Abs(accPath)
 	accDB, errAccDB := leveldb.OpenFile(accFullpath, nil)
 	if errAccDB != nil {
-		logger.Error(errAccDB)
-		panic(errAccDB)
+		blockindex.Close()
+		return nil, nil, errAccDB
+	}
+	return blockindex, accDB, nil
+}
+
+func NewBlockchain(h host.Host, chainblockstore blockstore.Blockstore, bswap exchange.Interface, configs *config.Configurations) *Blockchain {
+	blockindex, accDB, errDB := openChainDBs(configs)
+	if errDB != nil {
+		logger.Error(errDB)
+		panic(errDB)
 	}
 
 	// Bitswap only fetches blocks from other nodes, to fetch blocks from
@@ -129,7 +147,11 @@ func NewBlockchain(h host.Host, chainblockstore blockstore.Blockstore, bswap exc
 	var head *block.Block
 	if curhead == nil {
 		logger.Info("creating genesis block ...")
-		genesis = CreateGenesisBlock(configs.Genesis.Nonce, configs.Genesis.Message)
+		if errGenesis := ValidateGenesis(&configs.Genesis); errGenesis != nil {
+			logger.Error(errGenesis)
+			panic(errGenesis)
+		}
+		genesis = CreateGenesisBlock(&configs.Genesis)
 		head = genesis
 	} else {
 		logger.Info("recovered current stored chain. Head is on the height: ", curhead.Height)
@@ -149,6 +171,17 @@ func NewBlockchain(h host.Host, chainblockstore blockstore.Blockstore, bswap exc
 
 	// make sure the genesis block is in our local blockstore
 	chain.PutBlock(genesis)
+	if curhead == nil {
+		if errAlloc := chain.applyGenesisAlloc(&configs.Genesis); errAlloc != nil {
+			logger.Error(errAlloc)
+			panic(errAlloc)
+		}
+	}
+
+	if errIndex := chain.initAddressIndex(); errIndex != nil {
+		logger.Error("initializing address index: ", errIndex)
+		panic(errIndex)
+	}
 
 	isonline := bswap.IsOnline()
 	logger.Info("exchange online is ", isonline)
@@ -157,13 +190,29 @@ func NewBlockchain(h host.Host, chainblockstore blockstore.Blockstore, bswap exc
 
 //LoadBlock loads block from local db or other nodes using block service
 func (chain *Blockchain) LoadBlock(blkcid *cid.Cid) (*block.Block, error) {
+	out, err := chain.fetchBlock(blkcid)
+	if err != nil || out == nil {
+		return out, err
+	}
+
+	//if block index is passed, store index in block index db
+	if chain.BlockIndex != nil {
+		if err := chain.SaveBlockIndex(out); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+// fetchBlock loads block like LoadBlock without storing its index
+func (chain *Blockchain) fetchBlock(blkcid *cid.Cid) (*block.Block, error) {
 
 	if blkcid == nil {
 		return nil, nil
 	}
 
 	bsrv := chain.BlockService
-	bi := chain.BlockIndex
 
 	ctx, cancel := context.WithCancel(context.Background()) //, time.Second*10)
 	defer cancel()
@@ -184,26 +233,28 @@ func (chain *Blockchain) LoadBlock(blkcid *cid.Cid) (*block.Block, error) {
 		return nil, err
 	}
 
-	var out block.Block
-	if err := cbor.DecodeInto(data.RawData(), &out); err != nil {
+	return block.DeserializeBlock(data.RawData())
+}
+
+//PutBlock stores and broadcast block using block service and store it's index in block index db
+func (chain *Blockchain) PutBlock(blk *block.Block) (*cid.Cid, error) {
+	cid, err := chain.storeBlock(blk)
+	if err != nil {
 		return nil, err
 	}
 
-	//if block index is passed, store index in block index db
-	if bi != nil {
-		if err := chain.SaveBlockIndex(&out); err != nil {
-			return nil, err
-		}
+	err = chain.SaveBlockIndex(blk)
+	if err != nil {
+		return nil, err
 	}
-
-	return &out, nil
+	return cid, nil
 }
 
-//PutBlock stores and broadcast block using block service and store it's index in block index db
-func (chain *Blockchain) PutBlock(blk *block.Block) (*cid.Cid, error) {
+// storeBlock stores and broadcast block like PutBlock without storing its index
+func (chain *Blockchain) storeBlock(blk *block.Block) (*cid.Cid, error) {
 	bsrv := chain.BlockService
 
-	nd, err := cbor.WrapObject(blk, multihash.BLAKE2B_MIN+31, 32)
+	nd, err := encodeBlock(blk)
 	if err != nil {
 		return nil, err
 	}
@@ -217,20 +268,19 @@ func (chain *Blockchain) PutBlock(blk *block.Block) (*cid.Cid, error) {
 		return nil, err
 	}
 
-	err = chain.SaveBlockIndex(blk)
-	if err != nil {
-		return nil, err
-	}
-
 	cid := nd.Cid()
 	return &cid, nil
 }
 
 // Height ---- Map to ----> Block Cid
+// Header Hash ---- Map to ----> Height
+// Block Cid ---- Map to ----> Height (block is indexed only once)
+// Txid ---- Map to ----> Tx Location
 func (chain *Blockchain) SaveBlockIndex(blk *block.Block) error {
 
 	heightbytes := number.Int64ToByteArray(int64(blk.Height))
-	hashbytes := chain.GetBlockCid(blk).Bytes() //blk.GetHashBytes()
+	blkcid := chain.GetBlockCid(blk)
+	hashbytes := blkcid.Bytes() //blk.GetHashBytes()
 
 	blkbytes, errGet := chain.BlockIndex.Get(hashbytes, nil)
 	if errGet == nil {
@@ -243,63 +293,35 @@ func (chain *Blockchain) SaveBlockIndex(blk *block.Block) error {
 		}
 	}
 
-	//update accounts
-	if values, err := chain.CalcAccountsUpdates(blk.Transactions); err != nil {
-		//TODO: Remove block from chain and roll back head
+	// accounts are updated in one batch with undo record of block, so retrying doesn't apply them twice
+	if err := chain.connectAccounts(blk, blkcid); err != nil {
 		logger.Error("update block accounts: ", err)
 		return err
-	} else {
-		err = chain.UpdateAccounts(values)
-		if err != nil {
-			return err
-		}
-	}
-	reward, _ := blk.Reward.Float64()
-	if err := chain.AddToAccountBalance(blk.Header.Miner, reward, false); err != nil {
-		//TODO: Rollback accounts balances and Remove block from chain and roll back head
-		logger.Error("add block block reward to miner account: ", err)
-		return nil
 	}
-	logger.Info("Miner ", blk.Header.Miner, "received", reward, "as block reward!")
 
 	//store index
 	err := chain.BlockIndex.Put(heightbytes, hashbytes, nil)
 	if err != nil {
 		return err
 	}
-	return nil
-}
-
-func CreateGenesisBlock(nonce int64, message string) *block.Block {
-	//convert nonce to byte array
-	//noncebytes := number.IntToHex(nonce)
-	now := time.Now()
-	tm := now.UnixMilli()
-
-	genesisBlock := &block.Block{
-		Height: 0,
-		//Hash:	*hash.ZeroHash(),
-		Header: block.BlockHeader{
-			Version:    "0.0.1",
-			PrevHash:   *hash.ZeroHash(),
-			MerkleRoot: *hash.ZeroHash(),
-			Timestamp:  tm,
-			Nonce:      nonce,
-			Miner:      "0x0",
-			Difficulty: 1,
-			Memo:       message,
-		},
-		PrevCid:      nil,
-		TxsCount:     0,
-		Reward:       new(big.Float).SetInt64(0),
-		Transactions: nil,
-	}
-	genesisBlock.UpdateHash()
-
-	return genesisBlock
+	if err := chain.indexTransactions(blk, blkcid); err != nil {
+		return err
+	}
+	if err := chain.indexAddresses(blk, blkcid); err != nil {
+		return err
+	}
+	blkhash := blk.GetHash()
+	if err := chain.BlockIndex.Put(hashIndexKey(&blkhash), number.IntToHex(int64(blk.Height)), nil); err != nil {
+		return err
+	}
+	// cid key is stored last, so block is indexed again if storing index fails
+	return chain.BlockIndex.Put(hashbytes, number.IntToHex(int64(blk.Height)), nil)
 }
 
+// GetChainTip returns head of chain, it is safe to call while blocks are added
 func (chain *Blockchain) GetChainTip() *block.Block {
+	chain.headMutex.RLock()
+	defer chain.headMutex.RUnlock()
 	return chain.Head
 }
 
@@ -307,8 +329,8 @@ func (chain *Blockchain) GetBlock(height uint64) (*block.Block, error) {
 	if height == 0 {
 		return chain.GenesisBlock, nil
 	}
-	if height < 0 || height > chain.Head.Height {
-		logger.Error("height (which is ", height, ") should be between 0 and ", chain.Head.Height, ".")
+	if tip := chain.GetChainTip(); height < 0 || height > tip.Height {
+		logger.Error("height (which is ", height, ") should be between 0 and ", tip.Height, ".")
 		return nil, errors.InvalidHeight
 	}
 	blkCidbytes, err := chain.BlockIndex.Get(number.Int64ToByteArray(int64(height)), nil) //chain.BlockIndex[height]
@@ -329,16 +351,133 @@ func (chain *Blockchain) GetBlock(height uint64) (*block.Block, error) {
 	return chain.LoadBlock(&blkcid)
 }
 
-func validateTransactions(txs []*transaction.Transaction) bool {
+// MaxBlocksRange is the maximum number of blocks which are returned by GetBlocks
+const MaxBlocksRange = 100
+
+// keys of header hash index are prefixed, so they don't collide with other keys of block index
+var hashIndexPrefix = []byte("hash:")
+
+func hashIndexKey(h *hash.Hash) []byte {
+	return append(append([]byte{}, hashIndexPrefix...), h.CloneBytes()...)
+}
+
+// GetBlockByHash returns a block of main chain by its header hash
+func (chain *Blockchain) GetBlockByHash(h *hash.Hash) (*block.Block, error) {
+	heightbytes, err := chain.BlockIndex.Get(hashIndexKey(h), nil)
+	if err != nil {
+		if err == leveldb.ErrNotFound {
+			return nil, errors.BlockNotFount
+		}
+		return nil, err
+	}
+	blk, err := chain.GetBlock(binary.BigEndian.Uint64(heightbytes))
+	if err != nil {
+		return nil, err
+	}
+	// block may be disconnected from main chain
+	if blk == nil {
+		return nil, errors.BlockNotFount
+	}
+	blkhash := blk.GetHash()
+	if !blkhash.IsEqual(h) {
+		return nil, errors.BlockNotFount
+	}
+	return blk, nil
+}
+
+// GetBlockByCid returns an indexed block by its cid, block is not fetched from other nodes
+func (chain *Blockchain) GetBlockByCid(blkcid *cid.Cid) (*block.Block, error) {
+	if _, err := chain.BlockIndex.Get(blkcid.Bytes(), nil); err != nil {
+		if err == leveldb.ErrNotFound {
+			return nil, errors.BlockNotFount
+		}
+		return nil, err
+	}
+	return chain.LoadBlock(blkcid)
+}
+
+// GetBlocks returns blocks of main chain from height to height (both included)
+func (chain *Blockchain) GetBlocks(from uint64, to uint64) ([]*block.Block, error) {
+	if tip := chain.GetChainTip(); to > tip.Height {
+		to = tip.Height
+	}
+	if from > to {
+		return nil, errors.InvalidHeight
+	}
+	if to-from+1 > MaxBlocksRange {
+		return nil, errors.BlocksRangeTooBig
+	}
+	blocks := make([]*block.Block, 0, to-from+1)
+	for height := from; height <= to; height++ {
+		blk, err := chain.GetBlock(height)
+		if err != nil {
+			return nil, err
+		}
+		if blk == nil {
+			return nil, errors.BlockNotFount
+		}
+		blocks = append(blocks, blk)
+	}
+	return blocks, nil
+}
+
+// ChainID returns id of the configured network, transactions must be signed for it
+func (chain *Blockchain) ChainID() uint64 {
+	if chain.Configs == nil {
+		return 0
+	}
+	return chain.Configs.Genesis.ChainID
+}
+
+// validateTransactions checks chain id, value, validity window and signatures of block transactions,
+// multisig transactions need threshold signatures
+func (chain *Blockchain) validateTransactions(height uint64, txs []*transaction.Transaction) bool {
 	// TODO:Validate tx format and logic
+	spent := make(map[string]map[uint64]bool)
+	for _, tx := range txs {
+		if spent[tx.From][tx.Nonce] {
+			logger.Info("tx ", tx.GetTxidString(), " of block spends nonce ", tx.Nonce, " of ", tx.From, " again")
+			return false
+		}
+		if spent[tx.From] == nil {
+			spent[tx.From] = make(map[uint64]bool)
+		}
+		spent[tx.From][tx.Nonce] = true
+		if tx.ChainID != chain.ChainID() {
+			logger.Info("tx ", tx.GetTxidString(), " of block has chain id ", tx.ChainID)
+			return false
+		}
+		if !tx.HasValidValue() {
+			logger.Info("tx ", tx.GetTxidString(), " of block has invalid value")
+			return false
+		}
+		if !tx.ValidAt(height) {
+			logger.Info("tx ", tx.GetTxidString(), " of block is not valid at height ", height)
+			return false
+		}
+		if !tx.VerifySignature() {
+			logger.Info("tx ", tx.GetTxidString(), " of block has invalid signature")
+			return false
+		}
+	}
+	return true
+}
+
+// CheckBlock validates block without local chain state, i.e. chain id, values, validity windows and signatures of
+// its transactions, so a block which fails it is invalid on every node
+func (chain *Blockchain) CheckBlock(blk *block.Block) bool {
+	if !chain.validateTransactions(blk.Height, blk.Transactions) {
+		logger.Info("Block validation failed: Block Contains invalid tx")
+		return false
+	}
 	return true
 }
 
 // 1- Check that prevHash of new block (it should be equal to hash of chainTip)
-// 2- Validate Transactions
+// 2- Validate Transactions (CheckBlock)
 // 3- Time is greater than time of chainTip
 func (chain *Blockchain) ValidateBlock(blk *block.Block) bool {
-	chainTip := chain.Head
+	chainTip := chain.GetChainTip()
 	if blk.Height <= chainTip.Height {
 		logger.Info("Block validation failed: Height is less than chaintip")
 		return false
@@ -348,8 +487,7 @@ func (chain *Blockchain) ValidateBlock(blk *block.Block) bool {
 	// 	logger.Info("Block validation failed: Invalid PrevHash")
 	// 	return false
 	// }
-	if !validateTransactions(blk.Transactions) {
-		logger.Info("Block validation failed: Block Contains invalid tx")
+	if !chain.CheckBlock(blk) {
 		return false
 	}
 	if blk.Header.Timestamp < chainTip.Header.Timestamp {
@@ -359,56 +497,120 @@ func (chain *Blockchain) ValidateBlock(blk *block.Block) bool {
 	return true
 }
 
-func (chain *Blockchain) reload(oldBlock *block.Block, newBlock *block.Block) ([]*block.Block, error) {
+// reload loads parents of new block which are not in main chain, it returns the common block
+// of old and new chain and loaded blocks in height order
+func (chain *Blockchain) reload(newBlock *block.Block) (*block.Block, []*block.Block, error) {
 	logger.Info("Reloading chain from block: ", newBlock.Height)
 	var newChain []*block.Block
 
-	if oldBlock.GetHash().String() == newBlock.GetHash().String() {
-		commonBlock := oldBlock
-		logger.Info("Blockchain reloaded back from block", commonBlock)
-		return newChain, nil
-	} else {
-		newChain = append(newChain, newBlock)
-		logger.Info("new block added to chain: ", string(newBlock.Serialize()))
-		// Get the missing parent blocks by prevHash of newBlock
-		if newBlock.Height == 0 {
-			return newChain, nil
-		}
-		logger.Info("fetching block height ", newBlock.Height-1, " for cid: ", newBlock.PrevCid.String())
-		prevBlock, err := chain.LoadBlock(newBlock.PrevCid)
+	cur := newBlock
+	for cur.Height > 0 {
+		logger.Info("fetching block height ", cur.Height-1, " for cid: ", cur.PrevCid.String())
+		prevBlock, err := chain.fetchBlock(cur.PrevCid)
 		if err != nil {
 			logger.Error("Fetching parent hashes of block failed -- aborting reload:", err)
-			return nil, err
+			return nil, nil, err
+		}
+		if prevBlock == nil {
+			return nil, nil, errors.BlockNotFount
+		}
+		main, err := chain.isMainChain(prevBlock)
+		if err != nil {
+			return nil, nil, err
 		}
-		logger.Info("block height ", newBlock.Height-1, " loaded!")
-		return chain.reload(oldBlock, prevBlock)
+		if main {
+			logger.Info("Blockchain reloaded back from block ", prevBlock.Height)
+			for i, j := 0, len(newChain)-1; i < j; i, j = i+1, j-1 {
+				newChain[i], newChain[j] = newChain[j], newChain[i]
+			}
+			return prevBlock, newChain, nil
+		}
+		newChain = append(newChain, prevBlock)
+		cur = prevBlock
 	}
+	return nil, nil, errors.BlockNotFount
 }
 
 //AddBlock adds block to blockchain
 func (chain *Blockchain) AddBlock(blk *block.Block) *cid.Cid {
-	if chain.ValidateBlock(blk) {
-		prevhash := blk.Header.PrevHash.String()
-		headhash := chain.Head.GetHash().String()
-		if blk.Height > chain.Head.Height+1 && prevhash != headhash {
-			// reload chain if prevhash is not chaintip hash
-			logger.Info("Reloading blocks from height: ", blk.Height)
-			_, errReload := chain.reload(chain.Head, blk)
-			if errReload != nil {
+	if !chain.ValidateBlock(blk) {
+		return nil
+	}
+	oldHead := chain.GetChainTip()
+	blkCopy := *blk
+	cid, err := chain.storeBlock(&blkCopy)
+	if err != nil {
+		logger.Error("add new block to chain: ", err)
+		return nil
+	}
+	newBlocks := []*block.Block{&blkCopy}
+	var disconnected []*block.Block
+	oldHash := oldHead.GetHash()
+	if !blkCopy.Header.PrevHash.IsEqual(&oldHash) {
+		// reload chain if prevhash is not chaintip hash
+		logger.Info("Reloading blocks from height: ", blk.Height)
+		common, loaded, errReload := chain.reload(&blkCopy)
+		if errReload != nil {
+			return nil
+		}
+		for _, b := range loaded {
+			if !chain.validateTransactions(b.Height, b.Transactions) {
+				logger.Info("Reloaded block ", b.Height, " contains invalid tx")
 				return nil
 			}
 		}
-		blkCopy := *blk
-		chain.Head = &blkCopy
-		logger.Info("Block accepted, chain head set to block height:", blkCopy.Height) //string(blkCopy.Serialize()))
-		cid, err := chain.PutBlock(&blkCopy)
-		if err != nil {
-			logger.Error("add new block to chain: ", err)
+		var errDisconnect error
+		disconnected, errDisconnect = chain.disconnectBlocks(oldHead, common.Height)
+		if errDisconnect != nil {
+			logger.Error("disconnect old chain blocks: ", errDisconnect)
+			chain.reconnectBlocks(disconnected)
 			return nil
 		}
-		return cid
+		newBlocks = append(loaded, newBlocks...)
+	}
+	if err := chain.connectBlocks(newBlocks, disconnected); err != nil {
+		logger.Error("add new block to chain: ", err)
+		return nil
+	}
+	chain.headMutex.Lock()
+	chain.Head = &blkCopy
+	chain.headMutex.Unlock()
+	logger.Info("Block accepted, chain head set to block height:", blkCopy.Height) //string(blkCopy.Serialize()))
+	chain.publishBlockEvents(oldHead, newBlocks, disconnected)
+	return cid
+}
+
+// publishBlockEvents publishes reorg, new head and transactions of disconnected and connected blocks,
+// connected blocks are in height order and new head is the last one
+func (chain *Blockchain) publishBlockEvents(oldHead *block.Block, connected []*block.Block, disconnected []*block.Block) {
+	if chain.Events == nil {
+		return
+	}
+	newHead := connected[len(connected)-1]
+	oldHash := oldHead.GetHash()
+	if !newHead.Header.PrevHash.IsEqual(&oldHash) {
+		logger.Info("chain reorganized, old head: ", oldHead.Height, " new head: ", newHead.Height)
+		chain.Events.Publish(event.TopicReorg, &event.ReorgEvent{OldHead: oldHead, NewHead: newHead})
+	}
+	chain.Events.Publish(event.TopicNewHead, &event.NewHeadEvent{Block: newHead})
+	for _, blk := range disconnected {
+		chain.publishTxEvents(blk, true)
+	}
+	for _, blk := range connected {
+		chain.publishTxEvents(blk, false)
+	}
+}
+
+func (chain *Blockchain) publishTxEvents(blk *block.Block, removed bool) {
+	blkhash := blk.GetHash()
+	for _, tx := range blk.Transactions {
+		chain.Events.Publish(event.TopicConfirmedTx, &event.TxEvent{
+			Tx:          tx,
+			BlockHeight: blk.Height,
+			BlockHash:   blkhash.String(),
+			Removed:     removed,
+		})
 	}
-	return nil
 }
 
 //SyncChain syncs chain from specific block (to genesis) using block service
@@ -437,7 +639,7 @@ func (chain *Blockchain) SyncChain(from *block.Block) error {
 
 func (bc *Blockchain) GetIterator() *Iterator {
 	return &Iterator{
-		bc.GetBlockCid(bc.Head),
+		bc.GetBlockCid(bc.GetChainTip()),
 		bc,
 	}
 }
@@ -463,7 +665,7 @@ func (bc *Blockchain) GetBlockCids(beginCid *cid.Cid, stopCid cid.Cid, maxNum in
 		blocks = append(blocks, h)
 		getCount += 1
 
-		if len(block.Header.PrevHash) == 0 {
+		if block.Height == 0 {
 			break
 		}
 
@@ -493,7 +695,7 @@ func (bc *Blockchain) CalcReward(height uint64) *big.Float {
 }
 
 func (bc *Blockchain) GetBlockCid(b *block.Block) *cid.Cid {
-	nd, err := cbor.WrapObject(*b, multihash.BLAKE2B_MIN+31, 32)
+	nd, err := encodeBlock(b)
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,149 @@
+package blockchain
+
+import (
+	"context"
+	"math/big"
+
+	block "badcoin/src/block"
+	config "badcoin/src/config"
+	address "badcoin/src/helper/address"
+	codec "badcoin/src/helper/codec"
+	errors "badcoin/src/helper/error"
+	hash "badcoin/src/helper/hash"
+	logger "badcoin/src/helper/logger"
+
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+// GenesisNonce is the nonce of genesis block based on project requirements
+const GenesisNonce = 1337
+
+// ValidateGenesis checks genesis specification before creating genesis block
+func ValidateGenesis(genesis *config.Genesis) error {
+	if genesis.ChainID == 0 {
+		logger.Error("genesis chain id is not set")
+		return errors.InvalidGenesis
+	}
+	if genesis.Nonce != GenesisNonce {
+		logger.Error("genesis nonce should be ", GenesisNonce, " but it is ", genesis.Nonce)
+		return errors.InvalidGenesis
+	}
+	if genesis.Timestamp <= 0 {
+		logger.Error("genesis timestamp is not set")
+		return errors.InvalidGenesis
+	}
+	for _, alloc := range genesis.Alloc {
+		if !address.ValidateAddress(alloc.Address) {
+			logger.Error("genesis alloc address ", alloc.Address, " is not valid")
+			return errors.InvalidGenesis
+		}
+		if alloc.Balance <= 0 {
+			logger.Error("genesis alloc balance of ", alloc.Address, " should be positive")
+			return errors.InvalidGenesis
+		}
+	}
+	return nil
+}
+
+// genesisAllocRoot returns the hash of canonical encoding of premined allocations,
+// so genesis hash changes if allocations change
+func genesisAllocRoot(alloc []config.GenesisAlloc) hash.Hash {
+	if len(alloc) == 0 {
+		return *hash.ZeroHash()
+	}
+	e := codec.NewEncoder()
+	e.Array(len(alloc))
+	for _, a := range alloc {
+		e.Array(2)
+		e.Text(a.Address)
+		e.Float(a.Balance)
+	}
+	return hash.HashH(e.Data())
+}
+
+// CreateGenesisBlock creates genesis block from genesis specification.
+// All fields come from specification, so same specification always gives same genesis hash
+func CreateGenesisBlock(genesis *config.Genesis) *block.Block {
+	difficulty := genesis.Difficulty
+	if difficulty == 0 {
+		difficulty = 1
+	}
+
+	genesisBlock := &block.Block{
+		Height: 0,
+		Header: block.BlockHeader{
+			Version:    "0.0.1",
+			PrevHash:   *hash.ZeroHash(),
+			MerkleRoot: genesisAllocRoot(genesis.Alloc),
+			Timestamp:  genesis.Timestamp,
+			Nonce:      genesis.Nonce,
+			Miner:      "0x0",
+			Difficulty: difficulty,
+			Memo:       genesis.Message,
+		},
+		PrevCid:      nil,
+		TxsCount:     0,
+		Reward:       new(big.Float).SetInt64(0),
+		Transactions: nil,
+	}
+	genesisBlock.UpdateHash()
+
+	return genesisBlock
+}
+
+// applyGenesisAlloc adds premined balances to accounts
+func (chain *Blockchain) applyGenesisAlloc(genesis *config.Genesis) error {
+	for _, alloc := range genesis.Alloc {
+		if err := chain.AddToAccountBalance(alloc.Address, alloc.Balance, false); err != nil {
+			return err
+		}
+		logger.Info("genesis alloc: ", alloc.Address, " received ", alloc.Balance)
+	}
+	return nil
+}
+
+// InitGenesis writes genesis block and initial accounts into a fresh data directory
+func InitGenesis(chainblockstore blockstore.Blockstore, configs *config.Configurations) (*block.Block, error) {
+	if err := ValidateGenesis(&configs.Genesis); err != nil {
+		return nil, err
+	}
+
+	curhead, _, err := LoadBlockchain(chainblockstore)
+	if err != nil {
+		return nil, err
+	}
+	if curhead != nil {
+		return nil, errors.ChainAlreadyInitialized
+	}
+
+	blockindex, accDB, err := openChainDBs(configs)
+	if err != nil {
+		return nil, err
+	}
+	defer blockindex.Close()
+	defer accDB.Close()
+
+	chain := &Blockchain{
+		Blockstore: chainblockstore,
+		BlockIndex: blockindex,
+		Accounts:   accDB,
+		Configs:    configs,
+	}
+
+	genesis := CreateGenesisBlock(&configs.Genesis)
+	nd, err := encodeBlock(genesis)
+	if err != nil {
+		return nil, err
+	}
+	if err := chainblockstore.Put(context.Background(), nd); err != nil {
+		return nil, err
+	}
+	if err := chain.SaveBlockIndex(genesis); err != nil {
+		return nil, err
+	}
+	if err := chain.applyGenesisAlloc(&configs.Genesis); err != nil {
+		return nil, err
+	}
+
+	return genesis, nil
+}
@@ -0,0 +1,52 @@
+package blockchain
+
+import (
+	"testing"
+
+	config "badcoin/src/config"
+	"badcoin/src/wallet"
+)
+
+func TestGenesisBlock(t *testing.T) {
+	genesis := config.Genesis{
+		ChainID:    1001,
+		Nonce:      GenesisNonce,
+		Message:    "genesis",
+		Timestamp:  1640995200000,
+		Difficulty: 1,
+	}
+	if err := ValidateGenesis(&genesis); err != nil {
+		t.Fatal(err)
+	}
+	h1 := CreateGenesisBlock(&genesis).GetHash()
+	h2 := CreateGenesisBlock(&genesis).GetHash()
+	if !h1.IsEqual(&h2) {
+		t.Error("genesis block hash is not deterministic")
+	}
+
+	wal := wallet.NewWallet()
+	genesis.Alloc = []config.GenesisAlloc{{Address: wal.GetStringAddress(), Balance: 1000}}
+	if err := ValidateGenesis(&genesis); err != nil {
+		t.Fatal(err)
+	}
+	h3 := CreateGenesisBlock(&genesis).GetHash()
+	if h1.IsEqual(&h3) {
+		t.Error("genesis hash should commit to allocations")
+	}
+
+	// allocations are hashed in canonical encoding: [[address, float64 balance]]
+	root := genesisAllocRoot([]config.GenesisAlloc{{Address: "1BoatSLRHtKNngkdXEeobR76b53LETtpyT", Balance: 1000}})
+	if root.String() != "31f26b5941f99eeee3d53dc12bef380050e2a5cb7553cd4125328e6a939ebe9e" {
+		t.Error("wrong genesis alloc root: ", root.String())
+	}
+
+	genesis.Nonce = 1
+	if err := ValidateGenesis(&genesis); err == nil {
+		t.Error("genesis with invalid nonce should be rejected")
+	}
+	genesis.Nonce = GenesisNonce
+	genesis.ChainID = 0
+	if err := ValidateGenesis(&genesis); err == nil {
+		t.Error("genesis without chain id should be rejected")
+	}
+}
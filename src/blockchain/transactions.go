@@ -1,30 +1,196 @@
 package blockchain
 
 import (
+	"encoding/json"
+
+	block "badcoin/src/block"
 	errors "badcoin/src/helper/error"
 	hash "badcoin/src/helper/hash"
+	logger "badcoin/src/helper/logger"
+	number "badcoin/src/helper/number"
 	transaction "badcoin/src/transaction"
+
+	cid "github.com/ipfs/go-cid"
+	leveldb "github.com/syndtr/goleveldb/leveldb"
 )
 
-// FindTransaction finds a transaction by its ID
-func (bc *Blockchain) FindTransaction(ID *hash.Hash) (*transaction.Transaction, error) {
-	bci := bc.GetIterator()
+// TxLocation is the position of a confirmed transaction in chain
+type TxLocation struct {
+	BlockCid string
+	Height   uint64
+	Index    int
+}
+
+// keys of transaction index are prefixed, so they don't collide with height and cid keys of block index
+var txIndexPrefix = []byte("tx:")
+
+func txIndexKey(txid hash.Hash) []byte {
+	return append(append([]byte{}, txIndexPrefix...), txid.CloneBytes()...)
+}
+
+// indexTransactions stores txid ---> (block cid, height, index) of block transactions
+func (chain *Blockchain) indexTransactions(blk *block.Block, blkcid *cid.Cid) error {
+	if len(blk.Transactions) == 0 {
+		return nil
+	}
+	batch := new(leveldb.Batch)
+	for i, tx := range blk.Transactions {
+		loc := TxLocation{
+			BlockCid: blkcid.String(),
+			Height:   blk.Height,
+			Index:    i,
+		}
+		data, err := json.Marshal(&loc)
+		if err != nil {
+			return err
+		}
+		batch.Put(txIndexKey(tx.GetTxid()), data)
+	}
+	return chain.BlockIndex.Write(batch, nil)
+}
+
+// unindexTransactions removes transactions of a disconnected block from transaction index
+func (chain *Blockchain) unindexTransactions(blk *block.Block, blkcid *cid.Cid) error {
+	batch := new(leveldb.Batch)
+	for _, tx := range blk.Transactions {
+		txid := tx.GetTxid()
+		loc, err := chain.GetTxLocation(&txid)
+		if err != nil {
+			continue
+		}
+		// transaction may be included again in new chain
+		if loc.BlockCid == blkcid.String() {
+			batch.Delete(txIndexKey(txid))
+		}
+	}
+	return chain.BlockIndex.Write(batch, nil)
+}
 
-	for {
-		block := bci.Next()
-		if block == nil {
-			break
+// disconnectBlocks disconnects old chain blocks from main chain, it walks back from old head
+// until it reaches the common block of old and new chain, disconnected blocks are returned newest first
+func (chain *Blockchain) disconnectBlocks(oldHead *block.Block, commonHeight uint64) ([]*block.Block, error) {
+	var disconnected []*block.Block
+	cur := oldHead
+	for cur != nil && cur.Height > commonHeight {
+		if err := chain.disconnectBlock(cur); err != nil {
+			return disconnected, err
+		}
+		disconnected = append(disconnected, cur)
+		prev, err := chain.fetchBlock(cur.PrevCid)
+		if err != nil {
+			return disconnected, err
 		}
-		for _, tx := range block.Transactions {
-			if tx.ID.IsEqual(ID) {
-				return tx, nil
+		cur = prev
+	}
+	return disconnected, nil
+}
+
+// disconnectBlock removes index of block and reverts its account updates, cid and hash keys
+// are removed first, so block is connected again if it is added back to main chain
+func (chain *Blockchain) disconnectBlock(blk *block.Block) error {
+	logger.Info("block ", blk.Height, " is disconnected from main chain")
+	blkcid := chain.GetBlockCid(blk)
+	blkhash := blk.GetHash()
+	batch := new(leveldb.Batch)
+	batch.Delete(blkcid.Bytes())
+	batch.Delete(hashIndexKey(&blkhash))
+	main, err := chain.isMainChain(blk)
+	if err != nil {
+		return err
+	}
+	if main {
+		batch.Delete(number.Int64ToByteArray(int64(blk.Height)))
+	}
+	if err := chain.BlockIndex.Write(batch, nil); err != nil {
+		return err
+	}
+	if err := chain.unindexTransactions(blk, blkcid); err != nil {
+		return err
+	}
+	if err := chain.unindexAddresses(blk, blkcid); err != nil {
+		return err
+	}
+	return chain.disconnectAccounts(blkcid)
+}
+
+// connectBlocks indexes blocks of new chain in height order, if a block can't be connected
+// new chain blocks are disconnected again and disconnected old chain blocks are connected back
+func (chain *Blockchain) connectBlocks(blks []*block.Block, disconnected []*block.Block) error {
+	for i, blk := range blks {
+		err := chain.SaveBlockIndex(blk)
+		if err == nil {
+			continue
+		}
+		logger.Error("connect block ", blk.Height, ": ", err)
+		for j := i; j >= 0; j-- {
+			if errUndo := chain.disconnectBlock(blks[j]); errUndo != nil {
+				logger.Error("disconnect new chain block: ", errUndo)
 			}
 		}
+		chain.reconnectBlocks(disconnected)
+		return err
+	}
+	return nil
+}
 
-		if len(block.Header.PrevHash) == 0 {
-			break
+// reconnectBlocks connects disconnected old chain blocks back in height order
+func (chain *Blockchain) reconnectBlocks(disconnected []*block.Block) {
+	for i := len(disconnected) - 1; i >= 0; i-- {
+		if err := chain.SaveBlockIndex(disconnected[i]); err != nil {
+			logger.Error("connect old chain block back: ", err)
 		}
 	}
+}
+
+// isMainChain reports whether block is indexed at its height
+func (chain *Blockchain) isMainChain(blk *block.Block) (bool, error) {
+	maincid, err := chain.BlockIndex.Get(number.Int64ToByteArray(int64(blk.Height)), nil)
+	if err == leveldb.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return chain.GetBlockCid(blk).Equals(cidFromBytes(maincid)), nil
+}
 
-	return nil, errors.NotFoundTransaction
+func cidFromBytes(data []byte) cid.Cid {
+	_, c, _ := cid.CidFromBytes(data)
+	return c
+}
+
+// GetTxLocation returns position of a confirmed transaction
+func (chain *Blockchain) GetTxLocation(txid *hash.Hash) (*TxLocation, error) {
+	data, err := chain.BlockIndex.Get(txIndexKey(*txid), nil)
+	if err != nil {
+		if err == leveldb.ErrNotFound {
+			return nil, errors.NotFoundTransaction
+		}
+		return nil, err
+	}
+	var loc TxLocation
+	if err := json.Unmarshal(data, &loc); err != nil {
+		return nil, err
+	}
+	return &loc, nil
+}
+
+// FindTransaction finds a confirmed transaction by its txid using transaction index
+func (bc *Blockchain) FindTransaction(txid *hash.Hash) (*transaction.Transaction, *block.Block, error) {
+	loc, err := bc.GetTxLocation(txid)
+	if err != nil {
+		return nil, nil, err
+	}
+	blkcid, err := cid.Decode(loc.BlockCid)
+	if err != nil {
+		return nil, nil, err
+	}
+	blk, err := bc.LoadBlock(&blkcid)
+	if err != nil {
+		return nil, nil, err
+	}
+	if blk == nil || loc.Index >= len(blk.Transactions) {
+		return nil, nil, errors.NotFoundTransaction
+	}
+	return blk.Transactions[loc.Index], blk, nil
 }
@@ -0,0 +1,466 @@
+package blockchain
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	block "badcoin/src/block"
+	config "badcoin/src/config"
+	event "badcoin/src/event"
+	errors "badcoin/src/helper/error"
+	transaction "badcoin/src/transaction"
+	"badcoin/src/wallet"
+
+	blocks "github.com/ipfs/go-block-format"
+	blockservice "github.com/ipfs/go-blockservice"
+	cid "github.com/ipfs/go-cid"
+	datastore "github.com/ipfs/go-datastore"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	leveldb "github.com/syndtr/goleveldb/leveldb"
+)
+
+// offlineExchange serves blocks only from local blockstore
+type offlineExchange struct{}
+
+func (offlineExchange) GetBlock(context.Context, cid.Cid) (blocks.Block, error) {
+	return nil, errors.BlockNotFount
+}
+
+func (offlineExchange) GetBlocks(context.Context, []cid.Cid) (<-chan blocks.Block, error) {
+	out := make(chan blocks.Block)
+	close(out)
+	return out, nil
+}
+
+func (offlineExchange) HasBlock(context.Context, blocks.Block) error { return nil }
+
+func (offlineExchange) IsOnline() bool { return false }
+
+func (offlineExchange) Close() error { return nil }
+
+func newTestChain(t *testing.T) *Blockchain {
+	dir := t.TempDir()
+	blockindex, err := leveldb.OpenFile(dir+"/index", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accDB, err := leveldb.OpenFile(dir+"/accounts", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		blockindex.Close()
+		accDB.Close()
+	})
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	configs := &config.Configurations{}
+	configs.Storage.AddressIndex = true
+	configs.Genesis.ChainID = 1001
+	return &Blockchain{
+		Configs:      configs,
+		Head:         &block.Block{},
+		BlockService: blockservice.New(bs, offlineExchange{}),
+		Blockstore:   bs,
+		BlockIndex:   blockindex,
+		Accounts:     accDB,
+	}
+}
+
+func TestTransactionIndex(t *testing.T) {
+	chain := newTestChain(t)
+	wal := wallet.NewWallet()
+	miner := wallet.NewWallet().GetStringAddress()
+
+	tx := transaction.NewTransaction(chain.ChainID(), wal.PublicKey, 1, miner, 1, "")
+	blk := &block.Block{
+		Height:       1,
+		Header:       block.BlockHeader{Miner: miner},
+		Reward:       big.NewFloat(10),
+		TxsCount:     1,
+		Transactions: []*transaction.Transaction{tx},
+	}
+	nd, err := encodeBlock(blk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.Blockstore.Put(context.Background(), nd); err != nil {
+		t.Fatal(err)
+	}
+	chain.Head = blk
+
+	if err := chain.AddToAccountBalance(wal.GetStringAddress(), 5, false); err != nil {
+		t.Fatal(err)
+	}
+	// block is indexed only once, so accounts are updated only once
+	for i := 0; i < 2; i++ {
+		if err := chain.SaveBlockIndex(blk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if bal, _ := chain.GetAccountBalance(wal.GetStringAddress()); bal.Cmp(big.NewFloat(4)) != 0 {
+		t.Error("sender balance should be 4 but it is ", bal)
+	}
+	// saving index is retried after a failure, accounts are already updated
+	if err := chain.BlockIndex.Delete(chain.GetBlockCid(blk).Bytes(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.SaveBlockIndex(blk); err != nil {
+		t.Fatal(err)
+	}
+	if bal, _ := chain.GetAccountBalance(wal.GetStringAddress()); bal.Cmp(big.NewFloat(4)) != 0 {
+		t.Error("retrying index should not update accounts again, sender balance is ", bal)
+	}
+
+	txid := tx.GetTxid()
+	found, foundBlk, err := chain.FindTransaction(&txid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.GetTxidString() != tx.GetTxidString() || foundBlk.Height != 1 {
+		t.Error("wrong transaction found")
+	}
+
+	history, next, err := chain.GetAddressHistory(miner, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || next != "" || history[0].Direction != DirectionReward || history[1].Direction != DirectionReceived {
+		t.Error("miner history should have reward and received tx")
+	}
+	history, next, _ = chain.GetAddressHistory(miner, "", 1)
+	if len(history) != 1 || history[0].Direction != DirectionReward || next == "" {
+		t.Fatal("first page of history should have reward and next cursor")
+	}
+	history, next, _ = chain.GetAddressHistory(miner, next, 1)
+	if len(history) != 1 || history[0].Txid != tx.GetTxidString() || next != "" {
+		t.Error("history pagination failed")
+	}
+	if _, _, err := chain.GetAddressHistory(miner, "invalid", 1); err != errors.InvalidHistoryCursor {
+		t.Error("invalid cursor should fail")
+	}
+	if history, _, _ := chain.GetAddressHistory(wal.GetStringAddress(), "", 10); len(history) != 1 || history[0].Direction != DirectionSent {
+		t.Error("sender history should have sent tx")
+	}
+
+	blkcid := chain.GetBlockCid(blk)
+	if err := chain.unindexTransactions(blk, blkcid); err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.unindexAddresses(blk, blkcid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chain.GetTxLocation(&txid); err != errors.NotFoundTransaction {
+		t.Error("transaction of disconnected block should be removed from index")
+	}
+	if history, _, _ := chain.GetAddressHistory(miner, "", 10); len(history) != 0 {
+		t.Error("history of disconnected block should be removed from index")
+	}
+
+	if err := chain.disconnectAccounts(blkcid); err != nil {
+		t.Fatal(err)
+	}
+	sender, _ := chain.FetchAccountDetails(wal.GetStringAddress())
+	if sender.Balance.Cmp(big.NewFloat(5)) != 0 || sender.Nonce != 0 {
+		t.Error("sender account is not reverted, balance: ", sender.Balance.String(), ", nonce: ", sender.Nonce)
+	}
+	if bal, _ := chain.GetAccountBalance(miner); bal.Sign() != 0 {
+		t.Error("miner reward and received value are not reverted, balance: ", bal)
+	}
+	// undo record is removed, so block is reverted only once
+	if err := chain.disconnectAccounts(blkcid); err != nil {
+		t.Fatal(err)
+	}
+	if bal, _ := chain.GetAccountBalance(wal.GetStringAddress()); bal.Cmp(big.NewFloat(5)) != 0 {
+		t.Error("block accounts are reverted twice, sender balance is ", bal)
+	}
+}
+
+func newChildBlock(chain *Blockchain, parent *block.Block, miner string, txs ...*transaction.Transaction) *block.Block {
+	blk := &block.Block{
+		Height:  parent.Height + 1,
+		PrevCid: chain.GetBlockCid(parent),
+		Header: block.BlockHeader{
+			PrevHash:  parent.GetHash(),
+			Timestamp: parent.Header.Timestamp + 1,
+			Miner:     miner,
+		},
+		Reward:       big.NewFloat(10),
+		TxsCount:     uint64(len(txs)),
+		Transactions: txs,
+	}
+	blk.UpdateHash()
+	return blk
+}
+
+func TestReorg(t *testing.T) {
+	chain := newTestChain(t)
+	genesis := &block.Block{Reward: big.NewFloat(0)}
+	genesis.UpdateHash()
+	if _, err := chain.PutBlock(genesis); err != nil {
+		t.Fatal(err)
+	}
+	chain.Head = genesis
+
+	wal := wallet.NewWallet()
+	minerA := wallet.NewWallet().GetStringAddress()
+	minerB := wallet.NewWallet().GetStringAddress()
+	if err := chain.AddToAccountBalance(wal.GetStringAddress(), 5, false); err != nil {
+		t.Fatal(err)
+	}
+	tx := transaction.NewTransaction(chain.ChainID(), wal.PublicKey, 1, minerA, 1, "")
+	tx.Sign(wal.PrivateKey)
+	txid := tx.GetTxid()
+
+	a1 := newChildBlock(chain, genesis, minerA, tx)
+	a2 := newChildBlock(chain, a1, minerA)
+	b1 := newChildBlock(chain, genesis, minerB)
+	b1.Header.Nonce = 1
+	b1.UpdateHash()
+	b2 := newChildBlock(chain, b1, minerB)
+	b3 := newChildBlock(chain, b2, minerB)
+	a3 := newChildBlock(chain, a2, minerA)
+	a3.Header.Timestamp = b3.Header.Timestamp
+	a3.UpdateHash()
+	a4 := newChildBlock(chain, a3, minerA)
+
+	checkBalance := func(address string, expected float64) {
+		t.Helper()
+		if bal, _ := chain.GetAccountBalance(address); bal.Cmp(big.NewFloat(expected)) != 0 {
+			t.Error("balance of ", address, " should be ", expected, " but it is ", bal)
+		}
+	}
+	checkMain := func(blks ...*block.Block) {
+		t.Helper()
+		for _, blk := range blks {
+			main, err := chain.GetBlock(blk.Height)
+			if err != nil || main.GetHash() != blk.GetHash() {
+				t.Error("block ", blk.Height, " is not in main chain")
+			}
+		}
+	}
+
+	for _, blk := range []*block.Block{a1, a2} {
+		if chain.AddBlock(blk) == nil {
+			t.Fatal("block ", blk.Height, " of chain A is not added")
+		}
+	}
+	checkBalance(wal.GetStringAddress(), 4)
+	checkBalance(minerA, 21)
+
+	// chain B is stored by sync, its last block reorganizes chain
+	for _, blk := range []*block.Block{b1, b2} {
+		if _, err := chain.storeBlock(blk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chain.Events = event.NewBus()
+	txEvents := chain.Events.Subscribe(event.TopicConfirmedTx, 10)
+	if chain.AddBlock(b3) == nil {
+		t.Fatal("block 3 of chain B is not added")
+	}
+	if e := (<-txEvents.C).(*event.TxEvent); !e.Removed || e.BlockHeight != 1 || e.Tx.GetTxid() != txid {
+		t.Error("transaction of disconnected block should be published as removed")
+	}
+	checkMain(b1, b2, b3)
+	checkBalance(wal.GetStringAddress(), 5)
+	checkBalance(minerA, 0)
+	checkBalance(minerB, 30)
+	if _, err := chain.GetTxLocation(&txid); err != errors.NotFoundTransaction {
+		t.Error("transaction of disconnected block should be removed from index")
+	}
+	a1hash := a1.GetHash()
+	if _, err := chain.GetBlockByHash(&a1hash); err != errors.BlockNotFount {
+		t.Error("disconnected block should not be found by hash")
+	}
+
+	// chain A becomes longer again, its blocks are connected again
+	if _, err := chain.storeBlock(a3); err != nil {
+		t.Fatal(err)
+	}
+	if chain.AddBlock(a4) == nil {
+		t.Fatal("block 4 of chain A is not added")
+	}
+	// transaction of reloaded block is confirmed again
+	if e := (<-txEvents.C).(*event.TxEvent); e.Removed || e.BlockHash != a1.GetHash().String() {
+		t.Error("transaction of reloaded block should be published as confirmed")
+	}
+	checkMain(a1, a2, a3, a4)
+	checkBalance(wal.GetStringAddress(), 4)
+	checkBalance(minerA, 41)
+	checkBalance(minerB, 0)
+	if nonce, _ := chain.GetAccountNonce(wal.GetStringAddress()); nonce != 1 {
+		t.Error("sender nonce should be 1 but it is ", nonce)
+	}
+	_, found, err := chain.FindTransaction(&txid)
+	if err != nil || found.GetHash() != a1.GetHash() {
+		t.Error("transaction should be found in block 1 of chain A")
+	}
+	if history, _, _ := chain.GetAddressHistory(minerB, "", 10); len(history) != 0 {
+		t.Error("history of chain B should be removed from index")
+	}
+	if chain.GetChainTip().GetHash() != a4.GetHash() {
+		t.Error("chain head should be block 4 of chain A")
+	}
+}
+
+func TestAddressIndexRebuild(t *testing.T) {
+	chain := newTestChain(t)
+	chain.Configs.Storage.AddressIndex = false
+	miner := wallet.NewWallet().GetStringAddress()
+	for height := uint64(1); height <= 3; height++ {
+		blk := &block.Block{
+			Height: height,
+			Header: block.BlockHeader{Miner: miner, Nonce: int64(height)},
+			Reward: big.NewFloat(10),
+		}
+		nd, err := encodeBlock(blk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := chain.Blockstore.Put(context.Background(), nd); err != nil {
+			t.Fatal(err)
+		}
+		if err := chain.SaveBlockIndex(blk); err != nil {
+			t.Fatal(err)
+		}
+		chain.Head = blk
+	}
+	if err := chain.initAddressIndex(); err != nil {
+		t.Fatal(err)
+	}
+
+	// blocks which are stored while index is disabled are indexed when it is enabled
+	chain.Configs.Storage.AddressIndex = true
+	if err := chain.initAddressIndex(); err != nil {
+		t.Fatal(err)
+	}
+	var history []*AddressTx
+	var err error
+	for i := 0; i < 100; i++ {
+		if history, _, err = chain.GetAddressHistory(miner, "", 10); err != errors.AddressIndexBuilding {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 || history[0].Height != 3 || history[2].Height != 1 {
+		t.Error("address index is not rebuilt, entries: ", len(history))
+	}
+	if built, _ := chain.BlockIndex.Has(addrIndexBuiltKey, nil); !built {
+		t.Error("rebuilt index is not marked")
+	}
+
+	chain.Configs.Storage.AddressIndex = false
+	if err := chain.initAddressIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if built, _ := chain.BlockIndex.Has(addrIndexBuiltKey, nil); built {
+		t.Error("index of node with disabled index should not be marked as built")
+	}
+}
+
+func TestBlockLookup(t *testing.T) {
+	chain := newTestChain(t)
+	var blocks []*block.Block
+	for height := uint64(1); height <= 3; height++ {
+		blk := &block.Block{
+			Height: height,
+			Header: block.BlockHeader{Nonce: int64(height)},
+			Reward: big.NewFloat(0),
+		}
+		blk.UpdateHash()
+		nd, err := encodeBlock(blk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := chain.Blockstore.Put(context.Background(), nd); err != nil {
+			t.Fatal(err)
+		}
+		if err := chain.SaveBlockIndex(blk); err != nil {
+			t.Fatal(err)
+		}
+		chain.Head = blk
+		blocks = append(blocks, blk)
+	}
+
+	h := blocks[1].GetHash()
+	blk, err := chain.GetBlockByHash(&h)
+	if err != nil || blk.Height != 2 {
+		t.Error("block lookup by hash failed")
+	}
+	blk, err = chain.GetBlockByCid(chain.GetBlockCid(blocks[2]))
+	if err != nil || blk.Height != 3 {
+		t.Error("block lookup by cid failed")
+	}
+	unknown := blocks[0].Header.MerkleRoot
+	if _, err := chain.GetBlockByHash(&unknown); err != errors.BlockNotFount {
+		t.Error("unknown hash should not be found")
+	}
+
+	rng, err := chain.GetBlocks(2, 10)
+	if err != nil || len(rng) != 2 || rng[0].Height != 2 || rng[1].Height != 3 {
+		t.Error("blocks range query failed")
+	}
+	if _, err := chain.GetBlocks(3, 2); err != errors.InvalidHeight {
+		t.Error("invalid range should fail")
+	}
+}
+
+func TestValidateTransactions(t *testing.T) {
+	chain := newTestChain(t)
+	wal := wallet.NewWallet()
+	to := wallet.NewWallet().GetStringAddress()
+
+	tx := transaction.NewTransaction(chain.ChainID(), wal.PublicKey, 1, to, 1, "")
+	tx.Sign(wal.PrivateKey)
+	if !chain.validateTransactions(1, []*transaction.Transaction{tx}) {
+		t.Error("transaction of chain should be valid")
+	}
+	// transaction is validly signed, but for another network
+	replayed := transaction.NewTransaction(chain.ChainID()+1, wal.PublicKey, 1, to, 1, "")
+	replayed.Sign(wal.PrivateKey)
+	if chain.validateTransactions(1, []*transaction.Transaction{tx, replayed}) {
+		t.Error("transaction of other chain should be rejected")
+	}
+
+	// second transaction of sender with the same nonce is a double spend
+	doubleSpend := transaction.NewTransaction(chain.ChainID(), wal.PublicKey, 1, to, 2, "")
+	doubleSpend.Sign(wal.PrivateKey)
+	if chain.validateTransactions(1, []*transaction.Transaction{tx, doubleSpend}) {
+		t.Error("transactions with the same sender and nonce should be rejected")
+	}
+
+	for _, value := range []float64{math.NaN(), math.Inf(1)} {
+		invalid := transaction.NewTransaction(chain.ChainID(), wal.PublicKey, 1, to, value, "")
+		invalid.Sign(wal.PrivateKey)
+		if chain.validateTransactions(1, []*transaction.Transaction{invalid}) {
+			t.Error("transaction with value ", value, " should be rejected")
+		}
+	}
+
+	expiring := transaction.NewTransaction(chain.ChainID(), wal.PublicKey, 2, to, 1, "")
+	expiring.SetValidity(1, 3)
+	expiring.Sign(wal.PrivateKey)
+	if !chain.validateTransactions(2, []*transaction.Transaction{expiring}) {
+		t.Error("transaction should be valid in its validity window")
+	}
+	if chain.validateTransactions(1, []*transaction.Transaction{expiring}) || chain.validateTransactions(3, []*transaction.Transaction{expiring}) {
+		t.Error("transaction should be rejected out of its validity window")
+	}
+
+	// block older than local head is not accepted, but it is not invalid
+	chain.Head = &block.Block{Height: 1, Header: block.BlockHeader{Timestamp: 100}}
+	old := &block.Block{Height: 2, Header: block.BlockHeader{Timestamp: 50}, Transactions: []*transaction.Transaction{expiring}}
+	if chain.ValidateBlock(old) || !chain.CheckBlock(old) {
+		t.Error("block time should only be checked against local head")
+	}
+	old.Height = 3
+	if chain.CheckBlock(old) {
+		t.Error("block with transaction out of its validity window should be invalid")
+	}
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	logger "badcoin/src/helper/logger"
+	"encoding/json"
+	"io/ioutil"
 	"path/filepath"
 
 	viper "github.com/spf13/viper"
@@ -31,6 +33,21 @@ func Init(configFile string) (Configs *Configurations, err error){
 
 	viper.SetConfigType("yml")
 
+	// rpc server defaults
+	viper.SetDefault("RpcSet.Host", "127.0.0.1")
+	viper.SetDefault("RpcSet.Port", "3000")
+
+	viper.SetDefault("Storage.AddressIndex", false)
+
+	// peer management defaults
+	viper.SetDefault("P2P.Peers.LowWater", 32)
+	viper.SetDefault("P2P.Peers.HighWater", 64)
+	viper.SetDefault("P2P.Peers.GracePeriodInSeconds", 20)
+	viper.SetDefault("P2P.Peers.BanThreshold", 100)
+	viper.SetDefault("P2P.Peers.BanTimeInSeconds", 86400)
+	viper.SetDefault("P2P.Peers.MaxKnownPeers", 256)
+	viper.SetDefault("P2P.Peers.AddrBookSize", 1000)
+
 	if err = viper.ReadInConfig(); err != nil {
 		logger.Error("Error reading config file, ", err)
 		return nil,err
@@ -45,5 +62,41 @@ func Init(configFile string) (Configs *Configurations, err error){
 		return nil,err
 	}
 
+	//genesis file overrides genesis section of config file
+	if Configs.Genesis.File != "" {
+		genesisFile := Configs.Genesis.File
+		if !filepath.IsAbs(genesisFile) {
+			genesisFile = filepath.Join(configFilePath, genesisFile)
+		}
+		genesis, errGenesis := LoadGenesis(genesisFile)
+		if errGenesis != nil {
+			logger.Error("Unable to load genesis file, ", errGenesis)
+			return nil, errGenesis
+		}
+		Configs.Genesis = *genesis
+	}
+
 	return Configs,nil
 }
+
+// ResolvePath resolves a path which is relative to configurations directory
+func (configs *Configurations) ResolvePath(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(configs.ConfigFile, path)
+}
+
+// LoadGenesis loads genesis specification from a json file
+func LoadGenesis(genesisFile string) (*Genesis, error) {
+	data, err := ioutil.ReadFile(genesisFile)
+	if err != nil {
+		return nil, err
+	}
+	genesis := new(Genesis)
+	if err := json.Unmarshal(data, genesis); err != nil {
+		return nil, err
+	}
+	genesis.File = genesisFile
+	return genesis, nil
+}
@@ -9,3 +9,19 @@ func TestConfig(t *testing.T) {
 	configs, _ := Init("")
 	fmt.Println(configs.ID)
 }
+
+func TestLoadGenesis(t *testing.T) {
+	genesis, err := LoadGenesis("../../config/genesis.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if genesis.Nonce != 1337 {
+		t.Error("genesis nonce should be 1337")
+	}
+	if genesis.Timestamp == 0 {
+		t.Error("genesis timestamp is not loaded")
+	}
+	if genesis.ChainID == 0 {
+		t.Error("genesis chain id is not loaded")
+	}
+}
@@ -13,16 +13,29 @@ type Configurations struct {
 	ID         string
 	Genesis    Genesis
 	Mining     Mining
+	P2P        P2P
 	RpcSet     RpcSet
 	Storage    Storage
 }
 
 //Genesis for genesis block options
+//it can be set in config.yaml or loaded from a json genesis file
 type Genesis struct {
-	Height  uint64
-	Nonce   int64
-	Reward  uint64
-	Message string
+	File       string
+	ChainID    uint64 //id of the network, transactions are signed for it
+	Height     uint64
+	Nonce      int64
+	Reward     uint64
+	Message    string
+	Timestamp  int64
+	Difficulty uint32
+	Alloc      []GenesisAlloc
+}
+
+//GenesisAlloc premined balance of an address
+type GenesisAlloc struct {
+	Address string
+	Balance float64
 }
 
 // Mining mining config
@@ -31,10 +44,54 @@ type Mining struct {
 	ExpectedMiningTimeInSeconds uint64
 }
 
+// P2P libp2p network config
+type P2P struct {
+	NetworkID     string
+	ListenAddress string
+	Bootstrap     []string
+	Private       PrivateNetwork
+	Allowlist     []string
+	Peers         Peers
+}
+
+// Peers peer management config
+type Peers struct {
+	LowWater             int
+	HighWater            int
+	GracePeriodInSeconds uint64
+	BanThreshold         int
+	BanTimeInSeconds     uint64
+	MaxKnownPeers        int
+	AddrBookSize         int
+}
+
+// PrivateNetwork private network config, nodes need same pre-shared key to connect
+type PrivateNetwork struct {
+	Enabled bool
+	PSKFile string
+}
+
 // RpcSet rpc server config
 type RpcSet struct {
 	Enabled bool
+	Host    string //bind address, loopback by default
 	Port    string
+	Auth    RpcAuth
+	TLS     RpcTLS
+}
+
+// RpcAuth credentials of sensitive rpc endpoints, bearer token or basic auth user/password
+type RpcAuth struct {
+	Token    string
+	Username string
+	Password string
+}
+
+// RpcTLS serves rpc over https when it is enabled
+type RpcTLS struct {
+	Enabled  bool
+	CertFile string
+	KeyFile  string
 }
 
 // data storage config
@@ -46,7 +103,8 @@ type Collections struct {
 	Stats      string
 }
 type Storage struct {
-	Type        uint8
-	DBName      string
-	Collections Collections
+	Type         uint8
+	DBName       string
+	AddressIndex bool //index history of addresses
+	Collections  Collections
 }
@@ -0,0 +1,107 @@
+package event
+
+import (
+	"sync"
+
+	block "badcoin/src/block"
+	logger "badcoin/src/helper/logger"
+	transaction "badcoin/src/transaction"
+)
+
+// topics of events which are published on bus
+const (
+	TopicNewHead     = "newHead"
+	TopicReorg       = "reorg"
+	TopicPendingTx   = "pendingTx"
+	TopicConfirmedTx = "confirmedTx"
+)
+
+// NewHeadEvent is published when a block becomes chain head
+type NewHeadEvent struct {
+	Block *block.Block
+}
+
+// ReorgEvent is published when new head is not a child of old head
+type ReorgEvent struct {
+	OldHead *block.Block
+	NewHead *block.Block
+}
+
+// TxEvent is published when a transaction is added to mempool or confirmed in a block,
+// it is published again with Removed when its block is disconnected from main chain
+type TxEvent struct {
+	Tx          *transaction.Transaction
+	BlockHeight uint64
+	BlockHash   string
+	Removed     bool
+}
+
+// Touches checks whether transaction is sent from or to address
+func (e *TxEvent) Touches(addr string) bool {
+	return e.Tx.From == addr || e.Tx.To == addr
+}
+
+// Subscription receives events of a topic until it is unsubscribed
+type Subscription struct {
+	Topic string
+	C     chan interface{}
+	bus   *Bus
+	once  sync.Once
+}
+
+// Unsubscribe removes subscription from bus and closes its channel
+func (sub *Subscription) Unsubscribe() {
+	sub.once.Do(func() {
+		sub.bus.mutex.Lock()
+		delete(sub.bus.subs[sub.Topic], sub)
+		sub.bus.mutex.Unlock()
+		close(sub.C)
+	})
+}
+
+// Bus is an in-process publish/subscribe event bus,
+// publishers are never blocked and events of slow subscribers are dropped
+type Bus struct {
+	mutex *sync.RWMutex
+	subs  map[string]map[*Subscription]struct{}
+}
+
+// NewBus creates an event bus
+func NewBus() *Bus {
+	return &Bus{
+		mutex: new(sync.RWMutex),
+		subs:  make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe subscribes to a topic, buffer is the number of events which are kept for subscriber
+func (bus *Bus) Subscribe(topic string, buffer int) *Subscription {
+	sub := &Subscription{
+		Topic: topic,
+		C:     make(chan interface{}, buffer),
+		bus:   bus,
+	}
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	if bus.subs[topic] == nil {
+		bus.subs[topic] = make(map[*Subscription]struct{})
+	}
+	bus.subs[topic][sub] = struct{}{}
+	return sub
+}
+
+// Publish sends event to all subscribers of topic, nil bus ignores events
+func (bus *Bus) Publish(topic string, data interface{}) {
+	if bus == nil {
+		return
+	}
+	bus.mutex.RLock()
+	defer bus.mutex.RUnlock()
+	for sub := range bus.subs[topic] {
+		select {
+		case sub.C <- data:
+		default:
+			logger.Debug("event subscriber of ", topic, " is slow, event is dropped")
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package event
+
+import (
+	"testing"
+
+	block "badcoin/src/block"
+	transaction "badcoin/src/transaction"
+)
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe(TopicNewHead, 1)
+
+	bus.Publish(TopicNewHead, &NewHeadEvent{Block: &block.Block{Height: 1}})
+	bus.Publish(TopicNewHead, &NewHeadEvent{Block: &block.Block{Height: 2}})
+	bus.Publish(TopicPendingTx, &TxEvent{})
+
+	e := (<-sub.C).(*NewHeadEvent)
+	if e.Block.Height != 1 {
+		t.Error("wrong event received")
+	}
+	select {
+	case <-sub.C:
+		t.Error("event of slow subscriber should be dropped")
+	default:
+	}
+
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+	if _, ok := <-sub.C; ok {
+		t.Error("channel should be closed after unsubscribe")
+	}
+	bus.Publish(TopicNewHead, &NewHeadEvent{})
+
+	var nilbus *Bus
+	nilbus.Publish(TopicNewHead, nil)
+
+	txe := &TxEvent{Tx: &transaction.Transaction{From: "a", To: "b"}}
+	if !txe.Touches("b") || txe.Touches("c") {
+		t.Error("touches check failed")
+	}
+}
@@ -13,6 +13,9 @@ import (
 
 // version pubkey for bitcoin, version = 0
 const version = byte(0x00)
+
+// version of multisig addresses, like bitcoin script hash addresses
+const scriptVersion = byte(0x05)
 const addressChecksumLen = 4
 
 // ToString returns address string format
@@ -37,6 +40,19 @@ func FromPublicKey(PublicKey []byte) []byte {
 	return []byte(address)
 }
 
+// FromScript returns address of a multisig script, it is hashed like a public key with script version
+func FromScript(script []byte) []byte {
+	versionedPayload := append([]byte{scriptVersion}, HashPublicKey(script)...)
+	fullPayload := append(versionedPayload, checksum(versionedPayload)...)
+	return []byte(base58.Encode(fullPayload))
+}
+
+// IsScriptAddress checks if address is a multisig address
+func IsScriptAddress(address string) bool {
+	payload := base58.Decode(address)
+	return len(payload) > addressChecksumLen && payload[0] == scriptVersion && ValidateAddress(address)
+}
+
 // HashPublicKey hashes public key
 // 1.sha256 publick key
 // 2.ripemd160(sha256(public key))
@@ -61,6 +77,9 @@ func HashPublicKey(pubKey []byte) []byte {
 // 5.get checksum，use first 4 bytes
 func ValidateAddress(address string) bool {
 	pubKeyHash := base58.Decode(address)
+	if len(pubKeyHash) <= addressChecksumLen {
+		return false
+	}
 	actualChecksum := pubKeyHash[len(pubKeyHash)-addressChecksumLen:]
 	version := pubKeyHash[0]
 	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-addressChecksumLen]
@@ -0,0 +1,329 @@
+// Package codec implements the canonical binary encoding of chain objects.
+// It is a deterministic subset of CBOR (RFC 8949): integers and lengths use their shortest form,
+// lengths are always definite, floats are always 64 bits and structs are arrays of their fields,
+// so every value has exactly one encoding. Decoder rejects any other form.
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// CBOR major types
+const (
+	majorUint   = 0
+	majorNegInt = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorSimple = 7
+)
+
+// additional info of simple values
+const (
+	simpleNull    = 22
+	simpleFloat64 = 27
+)
+
+var (
+	ErrUnexpectedEnd = errors.New("unexpected end of data")
+	ErrNonCanonical  = errors.New("value is not canonically encoded")
+	ErrUnexpected    = errors.New("unexpected type")
+	ErrTrailingData  = errors.New("trailing data after value")
+	ErrOverflow      = errors.New("integer overflows its type")
+	ErrVersion       = errors.New("unsupported encoding version")
+)
+
+// Encoder appends canonical encoding of values to a buffer
+type Encoder struct {
+	buf []byte
+}
+
+// NewEncoder creates an empty encoder
+func NewEncoder() *Encoder {
+	return &Encoder{}
+}
+
+// Data returns encoded values
+func (e *Encoder) Data() []byte {
+	return e.buf
+}
+
+// writeHead writes major type and argument in its shortest form
+func (e *Encoder) writeHead(major byte, arg uint64) {
+	m := major << 5
+	switch {
+	case arg < 24:
+		e.buf = append(e.buf, m|byte(arg))
+	case arg <= math.MaxUint8:
+		e.buf = append(e.buf, m|24, byte(arg))
+	case arg <= math.MaxUint16:
+		e.buf = append(e.buf, m|25)
+		e.appendUint(arg, 2)
+	case arg <= math.MaxUint32:
+		e.buf = append(e.buf, m|26)
+		e.appendUint(arg, 4)
+	default:
+		e.buf = append(e.buf, m|27)
+		e.appendUint(arg, 8)
+	}
+}
+
+// appendUint appends size low bytes of v in big endian
+func (e *Encoder) appendUint(v uint64, size int) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	e.buf = append(e.buf, b[8-size:]...)
+}
+
+// Array writes header of an array with n items, items are written after it
+func (e *Encoder) Array(n int) {
+	e.writeHead(majorArray, uint64(n))
+}
+
+// Uint writes an unsigned integer
+func (e *Encoder) Uint(v uint64) {
+	e.writeHead(majorUint, v)
+}
+
+// Int writes a signed integer
+func (e *Encoder) Int(v int64) {
+	if v >= 0 {
+		e.writeHead(majorUint, uint64(v))
+		return
+	}
+	e.writeHead(majorNegInt, uint64(-(v + 1)))
+}
+
+// Float writes a float with all its 64 bits
+func (e *Encoder) Float(v float64) {
+	e.buf = append(e.buf, majorSimple<<5|simpleFloat64)
+	e.appendUint(math.Float64bits(v), 8)
+}
+
+// ByteString writes a byte string, nil and empty slices are the same
+func (e *Encoder) ByteString(b []byte) {
+	e.writeHead(majorBytes, uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// Text writes a text string, it is not checked for utf-8 so every string round trips
+func (e *Encoder) Text(s string) {
+	e.writeHead(majorText, uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// Null writes null, e.g. for a nil pointer
+func (e *Encoder) Null() {
+	e.buf = append(e.buf, majorSimple<<5|simpleNull)
+}
+
+// Decoder reads canonically encoded values
+type Decoder struct {
+	data []byte
+	pos  int
+}
+
+// NewDecoder creates a decoder of data
+func NewDecoder(data []byte) *Decoder {
+	return &Decoder{data: data}
+}
+
+// Done checks that all data is decoded
+func (d *Decoder) Done() error {
+	if d.pos != len(d.data) {
+		return ErrTrailingData
+	}
+	return nil
+}
+
+// readHead reads major type and argument, argument must be in its shortest form
+func (d *Decoder) readHead() (byte, uint64, error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, ErrUnexpectedEnd
+	}
+	major := d.data[d.pos] >> 5
+	info := d.data[d.pos] & 0x1f
+	d.pos++
+	if major == majorSimple {
+		return major, uint64(info), nil
+	}
+
+	var size int
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		// indefinite lengths and reserved values are not canonical
+		return 0, 0, ErrNonCanonical
+	}
+	if len(d.data)-d.pos < size {
+		return 0, 0, ErrUnexpectedEnd
+	}
+	var arg, min uint64
+	b := d.data[d.pos : d.pos+size]
+	switch size {
+	case 1:
+		arg, min = uint64(b[0]), 24
+	case 2:
+		arg, min = uint64(binary.BigEndian.Uint16(b)), math.MaxUint8+1
+	case 4:
+		arg, min = uint64(binary.BigEndian.Uint32(b)), math.MaxUint16+1
+	default:
+		arg, min = binary.BigEndian.Uint64(b), math.MaxUint32+1
+	}
+	d.pos += size
+	if arg < min {
+		return 0, 0, ErrNonCanonical
+	}
+	return major, arg, nil
+}
+
+// expect reads head of a value of major type
+func (d *Decoder) expect(major byte) (uint64, error) {
+	m, arg, err := d.readHead()
+	if err != nil {
+		return 0, err
+	}
+	if m != major {
+		return 0, ErrUnexpected
+	}
+	return arg, nil
+}
+
+// Array reads header of an array which must have n items
+func (d *Decoder) Array(n int) error {
+	count, err := d.expect(majorArray)
+	if err != nil {
+		return err
+	}
+	if count != uint64(n) {
+		return ErrUnexpected
+	}
+	return nil
+}
+
+// ArrayLen reads header of an array and returns number of its items
+func (d *Decoder) ArrayLen() (int, error) {
+	count, err := d.expect(majorArray)
+	if err != nil {
+		return 0, err
+	}
+	// every item takes at least one byte
+	if count > uint64(len(d.data)-d.pos) {
+		return 0, ErrUnexpectedEnd
+	}
+	return int(count), nil
+}
+
+// Uint reads an unsigned integer
+func (d *Decoder) Uint() (uint64, error) {
+	return d.expect(majorUint)
+}
+
+// Uint32 reads an unsigned integer which fits in 32 bits
+func (d *Decoder) Uint32() (uint32, error) {
+	v, err := d.Uint()
+	if err != nil {
+		return 0, err
+	}
+	if v > math.MaxUint32 {
+		return 0, ErrOverflow
+	}
+	return uint32(v), nil
+}
+
+// Int reads a signed integer
+func (d *Decoder) Int() (int64, error) {
+	major, arg, err := d.readHead()
+	if err != nil {
+		return 0, err
+	}
+	if major != majorUint && major != majorNegInt {
+		return 0, ErrUnexpected
+	}
+	if arg > math.MaxInt64 {
+		return 0, ErrOverflow
+	}
+	if major == majorNegInt {
+		return -int64(arg) - 1, nil
+	}
+	return int64(arg), nil
+}
+
+// Float reads a 64 bits float
+func (d *Decoder) Float() (float64, error) {
+	major, info, err := d.readHead()
+	if err != nil {
+		return 0, err
+	}
+	if major != majorSimple || info != simpleFloat64 {
+		return 0, ErrUnexpected
+	}
+	if len(d.data)-d.pos < 8 {
+		return 0, ErrUnexpectedEnd
+	}
+	v := math.Float64frombits(binary.BigEndian.Uint64(d.data[d.pos:]))
+	d.pos += 8
+	return v, nil
+}
+
+// bytes reads content of a byte or text string
+func (d *Decoder) bytes(major byte) ([]byte, error) {
+	n, err := d.expect(major)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, ErrUnexpectedEnd
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+// ByteString reads a byte string, empty strings are returned as nil
+func (d *Decoder) ByteString() ([]byte, error) {
+	b, err := d.bytes(majorBytes)
+	if err != nil || len(b) == 0 {
+		return nil, err
+	}
+	return append([]byte{}, b...), nil
+}
+
+// FixedBytes reads a byte string of exactly len(out) bytes into out
+func (d *Decoder) FixedBytes(out []byte) error {
+	b, err := d.bytes(majorBytes)
+	if err != nil {
+		return err
+	}
+	if len(b) != len(out) {
+		return ErrUnexpected
+	}
+	copy(out, b)
+	return nil
+}
+
+// Text reads a text string
+func (d *Decoder) Text() (string, error) {
+	b, err := d.bytes(majorText)
+	return string(b), err
+}
+
+// Null reads null if next value is null, it reports if null is read
+func (d *Decoder) Null() bool {
+	if d.pos < len(d.data) && d.data[d.pos] == majorSimple<<5|simpleNull {
+		d.pos++
+		return true
+	}
+	return false
+}
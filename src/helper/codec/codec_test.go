@@ -0,0 +1,77 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"testing"
+)
+
+func TestEncoding(t *testing.T) {
+	e := NewEncoder()
+	e.Array(8)
+	e.Uint(23)
+	e.Uint(24)
+	e.Int(-500)
+	e.Int(math.MinInt64)
+	e.Float(1.5)
+	e.ByteString([]byte{1, 2})
+	e.Text("bdc")
+	e.Null()
+
+	// RFC 8949 encodings of same values
+	want := "88" + "17" + "1818" + "3901f3" + "3b7fffffffffffffff" + "fb3ff8000000000000" + "420102" + "63626463" + "f6"
+	if hex.EncodeToString(e.Data()) != want {
+		t.Fatal("unexpected encoding ", hex.EncodeToString(e.Data()))
+	}
+
+	d := NewDecoder(e.Data())
+	if err := d.Array(8); err != nil {
+		t.Fatal(err)
+	}
+	small, _ := d.Uint()
+	big, _ := d.Uint()
+	neg, _ := d.Int()
+	min, _ := d.Int()
+	f, _ := d.Float()
+	b, _ := d.ByteString()
+	s, _ := d.Text()
+	if small != 23 || big != 24 || neg != -500 || min != math.MinInt64 || f != 1.5 || !bytes.Equal(b, []byte{1, 2}) || s != "bdc" {
+		t.Error("decoded values don't match")
+	}
+	if !d.Null() || d.Done() != nil {
+		t.Error("all data should be decoded")
+	}
+}
+
+func TestNonCanonical(t *testing.T) {
+	cases := map[string]string{
+		"1817":               "integer is not in shortest form",
+		"190017":             "integer is not in shortest form",
+		"5f4101ff":           "indefinite length",
+		"f93e00":             "16 bits float",
+		"1b8000000000000000": "int overflows int64",
+		"4201":               "string is longer than data",
+		"0000":               "trailing data",
+	}
+	for data, reason := range cases {
+		raw, _ := hex.DecodeString(data)
+		d := NewDecoder(raw)
+		var err error
+		switch data {
+		case "5f4101ff":
+			_, err = d.ByteString()
+		case "f93e00":
+			_, err = d.Float()
+		case "4201":
+			_, err = d.ByteString()
+		default:
+			if _, err = d.Int(); err == nil {
+				err = d.Done()
+			}
+		}
+		if err == nil {
+			t.Error("decoding should fail: ", reason)
+		}
+	}
+}
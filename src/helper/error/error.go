@@ -10,8 +10,12 @@ var StorageInitFailed = errors.New("storage initialization failed")
 
 var InvalidHash = errors.New("Invalid hash")
 
+var InvalidCid = errors.New("Invalid cid")
+
 var InvalidHeight = errors.New("Invalid block height")
 
+var BlocksRangeTooBig = errors.New("Blocks range is too big")
+
 var NotFoundTransaction = errors.New("not found the transaction")
 
 var BlockNoTransactions = errors.New("block does not contain any transactions")
@@ -33,3 +37,80 @@ var ExchangeISNotOnline = errors.New("Exchange is not online")
 var InvalidNonce = errors.New("Nonce is invalid")
 
 var AlreadyHasPendingTx = errors.New("Account already has pending transaction")
+
+var InvalidGenesis = errors.New("Invalid genesis specification")
+
+var ChainAlreadyInitialized = errors.New("Chain is already initialized in data directory")
+
+var IncompatibleProtocolVersion = errors.New("Incompatible protocol version")
+
+var NetworkMismatch = errors.New("Network ID mismatch")
+
+var GenesisMismatch = errors.New("Genesis hash mismatch")
+
+var HandshakeTimeout = errors.New("Handshake is not done in time")
+
+var ChainIDMismatch = errors.New("Transaction chain ID doesn't match the network")
+
+var TxNotValidYet = errors.New("Transaction is not valid until a later block height")
+
+var TxExpired = errors.New("Transaction has expired")
+
+var InvalidTxSignature = errors.New("Transaction signature is not valid")
+
+var InvalidAddress = errors.New("Address is not valid")
+
+var InvalidTxValue = errors.New("Transaction value is not valid")
+
+var InvalidRawTx = errors.New("Raw transaction is not a valid hex or base64 encoded transaction")
+
+var AddressIndexDisabled = errors.New("Address index is not enabled")
+
+var AddressIndexBuilding = errors.New("Address index is being rebuilt")
+
+var InvalidHistoryCursor = errors.New("History cursor is not valid")
+
+var RescanInProgress = errors.New("Wallet rescan is already running")
+
+var InvalidMultisig = errors.New("Multisig threshold or public keys are not valid")
+
+var NotMultisigKey = errors.New("Key is not a public key of multisig")
+
+var MultisigTxMismatch = errors.New("Transactions of multisig signatures are not the same")
+
+// reason codes of rejected transactions
+const (
+	ReasonInvalidChainID      = "invalid_chain_id"
+	ReasonInvalidSignature    = "invalid_signature"
+	ReasonInvalidAddress      = "invalid_address"
+	ReasonInvalidValue        = "invalid_value"
+	ReasonInsufficientBalance = "insufficient_balance"
+	ReasonInvalidNonce        = "invalid_nonce"
+	ReasonNotValidYet         = "not_valid_yet"
+	ReasonExpired             = "expired"
+	ReasonAlreadyPending      = "already_pending"
+	ReasonInvalidEncoding     = "invalid_encoding"
+	ReasonWalletLocked        = "wallet_locked"
+	ReasonWatchOnly           = "watch_only"
+	ReasonLegacyKey           = "legacy_key"
+	ReasonInternal            = "internal_error"
+)
+
+// TxError is the error of a rejected transaction with its reason code
+type TxError struct {
+	Reason string
+	Err    error
+}
+
+// NewTxError creates a transaction error with a reason code
+func NewTxError(reason string, err error) *TxError {
+	return &TxError{Reason: reason, Err: err}
+}
+
+func (e *TxError) Error() string {
+	return e.Reason + ": " + e.Err.Error()
+}
+
+func (e *TxError) Unwrap() error {
+	return e.Err
+}
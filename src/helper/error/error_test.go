@@ -1,10 +1,20 @@
 package error
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 )
 
 func TestErrors(t *testing.T) {
 	fmt.Println(NotEnoughAccountBalance)
+
+	var err error = NewTxError(ReasonInvalidNonce, InvalidNonce)
+	var txErr *TxError
+	if !errors.As(err, &txErr) || txErr.Reason != ReasonInvalidNonce {
+		t.Error("tx error reason is lost")
+	}
+	if !errors.Is(err, InvalidNonce) {
+		t.Error("tx error should wrap cause")
+	}
 }
@@ -6,41 +6,63 @@ import (
 	logger "badcoin/src/helper/logger"
 	"badcoin/src/transaction"
 	"math/big"
+	"sync"
 )
 
 type getAcc func(addr string) (*big.Float, uint64, error)
 type Mempool struct {
+	mutex        *sync.RWMutex
 	transactions map[hash.Hash]transaction.Transaction
 }
 
 func NewMempool() *Mempool {
 	return &Mempool{
+		mutex:        new(sync.RWMutex),
 		transactions: make(map[hash.Hash]transaction.Transaction),
 	}
 }
 
 func (mempool *Mempool) AddTx(tx *transaction.Transaction) {
 	txid := tx.GetTxid()
+	mempool.mutex.Lock()
+	defer mempool.mutex.Unlock()
 	mempool.transactions[txid] = *tx
 }
 
 func (mempool *Mempool) RemoveTxs(txs []*transaction.Transaction) {
+	mempool.mutex.Lock()
+	defer mempool.mutex.Unlock()
 	for _, tx := range txs {
 		txid := tx.GetTxid()
 		delete(mempool.transactions, txid)
 	}
 }
 
-func (mempool *Mempool) SelectTransactions(f getAcc) []*transaction.Transaction {
+// SelectTransactions returns transactions which can be included in a block of height,
+// transactions out of their validity window are skipped and one transaction is selected per sender
+func (mempool *Mempool) SelectTransactions(height uint64, f getAcc) []*transaction.Transaction {
+	mempool.mutex.RLock()
+	defer mempool.mutex.RUnlock()
 	var txs []*transaction.Transaction
+	selected := make(map[string]bool)
 	for _, tx := range mempool.transactions {
+		if !tx.ValidAt(height) {
+			logger.Info("tx ", tx.GetTxidString(), " is not valid at height ", height, ", it is skipped")
+			continue
+		}
+		if selected[tx.From] {
+			logger.Info("tx ", tx.GetTxidString(), " spends nonce ", tx.Nonce, " of ", tx.From, " again, it is skipped")
+			continue
+		}
 		addr := tx.From
 		if bal, nonce, err := f(addr); err != nil {
 			return make([]*transaction.Transaction, 0)
 		} else {
 			//value should be less than balance and also checking the nonce
 			if bal.Cmp(big.NewFloat(tx.Value)) >= 0 && tx.Nonce == nonce+1 {
-				txs = append(txs, &tx)
+				mtx := tx
+				txs = append(txs, &mtx)
+				selected[tx.From] = true
 			} else {
 				logger.Info("tx with value:", tx.Value, "rejected from mempool. acc balance is: ", bal.String(), " nonce: ", tx.Nonce, " and account nonce is: ", nonce)
 			}
@@ -49,7 +71,24 @@ func (mempool *Mempool) SelectTransactions(f getAcc) []*transaction.Transaction
 	return txs
 }
 
+// RemoveExpired removes transactions which have expired at height and returns them
+func (mempool *Mempool) RemoveExpired(height uint64) []*transaction.Transaction {
+	mempool.mutex.Lock()
+	defer mempool.mutex.Unlock()
+	var expired []*transaction.Transaction
+	for txid, tx := range mempool.transactions {
+		if tx.IsExpired(height) {
+			mtx := tx
+			expired = append(expired, &mtx)
+			delete(mempool.transactions, txid)
+		}
+	}
+	return expired
+}
+
 func (mempool *Mempool) SetTransaction(txid hash.Hash, tx transaction.Transaction) error {
+	mempool.mutex.Lock()
+	defer mempool.mutex.Unlock()
 	for _, mtx := range mempool.transactions {
 		if tx.From == mtx.From {
 			return errors.AlreadyHasPendingTx
@@ -59,10 +98,48 @@ func (mempool *Mempool) SetTransaction(txid hash.Hash, tx transaction.Transactio
 	return nil
 }
 
+// GetTransaction returns a pending transaction by its txid, nil if it is not in mempool
+func (mempool *Mempool) GetTransaction(txid hash.Hash) *transaction.Transaction {
+	mempool.mutex.RLock()
+	defer mempool.mutex.RUnlock()
+	if tx, ok := mempool.transactions[txid]; ok {
+		return &tx
+	}
+	return nil
+}
+
+// Transactions returns all pending transactions
+func (mempool *Mempool) Transactions() []*transaction.Transaction {
+	mempool.mutex.RLock()
+	defer mempool.mutex.RUnlock()
+	txs := make([]*transaction.Transaction, 0, len(mempool.transactions))
+	for _, tx := range mempool.transactions {
+		mtx := tx
+		txs = append(txs, &mtx)
+	}
+	return txs
+}
+
+// HasPendingTx reports if an address has a pending transaction
+func (mempool *Mempool) HasPendingTx(addr string) bool {
+	mempool.mutex.RLock()
+	defer mempool.mutex.RUnlock()
+	for _, tx := range mempool.transactions {
+		if tx.From == addr {
+			return true
+		}
+	}
+	return false
+}
+
 func (mempool *Mempool) TransactionsCount() int {
+	mempool.mutex.RLock()
+	defer mempool.mutex.RUnlock()
 	return len(mempool.transactions)
 }
 
 func (mempool *Mempool) Clear() {
+	mempool.mutex.Lock()
+	defer mempool.mutex.Unlock()
 	mempool.transactions = make(map[hash.Hash]transaction.Transaction)
 }
@@ -3,6 +3,7 @@ package mempool
 import (
 	"badcoin/src/transaction"
 	"fmt"
+	"math/big"
 	"testing"
 	"badcoin/src/wallet"
 )
@@ -10,11 +11,81 @@ import (
 func TestMempool(t *testing.T) {
 	mp := NewMempool()
 	wal := wallet.NewWallet()
-	trans1 := transaction.NewTransaction(wal.PublicKey,0,"receiver1",100,"test data")
+	trans1 := transaction.NewTransaction(1001,wal.PublicKey,0,"receiver1",100,"test data")
 	mp.AddTx(trans1)
 	if mp.TransactionsCount() != 1 {
 		t.Error("adding tx failed")
 	}
+	trans2 := transaction.NewTransaction(1001,wal.PublicKey,3,"receiver2",10,"")
+	mp.AddTx(trans2)
+	if !mp.HasPendingTx(trans1.From) {
+		t.Error("sender should have pending tx")
+	}
+	if mp.HasPendingTx("receiver1") {
+		t.Error("receiver has no pending tx")
+	}
 	mp.Clear()
 	fmt.Println(mp.TransactionsCount())
 }
+
+func TestMempoolValidity(t *testing.T) {
+	mp := NewMempool()
+	wal := wallet.NewWallet()
+	getAcc := func(addr string) (*big.Float, uint64, error) {
+		return big.NewFloat(100), 0, nil
+	}
+	tx := transaction.NewTransaction(1001, wal.PublicKey, 1, "receiver", 10, "")
+	tx.SetValidity(5, 8)
+	mp.AddTx(tx)
+	if len(mp.SelectTransactions(5, getAcc)) != 0 {
+		t.Error("transaction should not be selected before its valid height")
+	}
+	if len(mp.SelectTransactions(6, getAcc)) != 1 {
+		t.Error("transaction should be selected in its validity window")
+	}
+	if len(mp.RemoveExpired(7)) != 0 || mp.TransactionsCount() != 1 {
+		t.Error("transaction is not expired yet")
+	}
+	if len(mp.SelectTransactions(8, getAcc)) != 0 {
+		t.Error("expired transaction should not be selected")
+	}
+	if len(mp.RemoveExpired(8)) != 1 || mp.TransactionsCount() != 0 {
+		t.Error("expired transaction should be removed")
+	}
+}
+
+func TestSelectTransactions(t *testing.T) {
+	mp := NewMempool()
+	getAcc := func(addr string) (*big.Float, uint64, error) {
+		return big.NewFloat(100), 0, nil
+	}
+	added := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		tx := transaction.NewTransaction(1001, wallet.NewWallet().PublicKey, 1, "receiver", float64(i+1), "")
+		mp.AddTx(tx)
+		added[tx.GetTxidString()] = true
+	}
+	selected := mp.SelectTransactions(1, getAcc)
+	if len(selected) != 3 {
+		t.Fatal("all transactions should be selected")
+	}
+	for _, tx := range selected {
+		if !added[tx.GetTxidString()] {
+			t.Error("unknown transaction is selected")
+		}
+		delete(added, tx.GetTxidString())
+	}
+	if len(added) != 0 {
+		t.Error("selected transactions should be different")
+	}
+
+	// transactions of a sender with the same nonce are double spends
+	mp.Clear()
+	wal := wallet.NewWallet()
+	for i := 0; i < 2; i++ {
+		mp.AddTx(transaction.NewTransaction(1001, wal.PublicKey, 1, "receiver", float64(i+1), ""))
+	}
+	if len(mp.SelectTransactions(1, getAcc)) != 1 {
+		t.Error("only one transaction of sender should be selected")
+	}
+}
@@ -26,19 +26,19 @@ func TestNewMerkleNode(t *testing.T) {
 	// Level 3
 	n7 := NewMerkleNode(n5, n6, nil)
 
-	if "1b37eba70eba8f9296ac8d4ac5898d69467e4e31366b6cac79dadf5f81579298" == hex.EncodeToString(n5.Data) {
+	if "21822a7161f322deaab4ebf24f625683fef5ba4a8e0d1ce1a3ef5cac4ca328a4" == hex.EncodeToString(n5.Data) {
 		t.Log(hex.EncodeToString(n5.Data))
 	} else {
 		t.Error("Level 1 hash 1 is correct", hex.EncodeToString(n5.Data))
 	}
 
-	if "10266b901c67ffbf48489d9b88614368ad9c00f5cc65e912cf7bdabe30d5cb06" == hex.EncodeToString(n6.Data) {
+	if "d476acc1d2b1004ac4e4fca279a1016d780c76fe31dd123d30006b793c67bb0c" == hex.EncodeToString(n6.Data) {
 		t.Log(hex.EncodeToString(n6.Data))
 	} else {
 		t.Error("Level 1 hash 2 is correct", hex.EncodeToString(n6.Data))
 	}
 
-	if "38addfe1aa839ab8842dcc11b284e027b0c43332fc3bd8b477413075bde5ea18" == hex.EncodeToString(n7.Data) {
+	if "ad067c68b8c2ca96a3567905f2c17e71255292467c0d8d434936254b1a52fcd7" == hex.EncodeToString(n7.Data) {
 		t.Log(hex.EncodeToString(n7.Data))
 	} else {
 		t.Error("Root hash is correct", hex.EncodeToString(n7.Data))
@@ -13,6 +13,11 @@ import (
 )
 
 func (node *Node) StartMiner(configs *config.Configurations) {
+	// rewards of a legacy key can't be spent, miner address is replaced on start if wallet isn't locked
+	if node.wallet == nil || node.wallet.IsLegacy() {
+		logger.Error("Mining is not started: miner address has a legacy key which can't sign its rewards")
+		return
+	}
 	c := make(chan *block.Block)
 	node.pow = proofofwork.NewProofOfWorkT(1)
 	go node.Mine(c, configs.Mining.ExpectedMiningTimeInSeconds)
@@ -58,8 +63,7 @@ func (node *Node) FindSolsHash(c chan *block.Block, expectedblocktime uint64) {
 			blk.Header.Miner = node.wallet.GetStringAddress()
 			blk.UpdateHash()
 			c <- blk
-			blkstr := string(blk.Serialize())
-			logger.Info("Block #", blk.Height, ": ", blkstr)
+			logger.Info("Block #", blk.Height, ": ", blk.GetHash().String())
 			//blk.Header.Nonce = make([]byte, 32)
 		}
 		time.Sleep(time.Duration(expectedblocktime) * time.Second)
@@ -9,13 +9,17 @@ import (
 
 	block "badcoin/src/block"
 	blockchain "badcoin/src/blockchain"
+	event "badcoin/src/event"
 	address "badcoin/src/helper/address"
+	errors "badcoin/src/helper/error"
+	hash "badcoin/src/helper/hash"
 	logger "badcoin/src/helper/logger"
 	mempool "badcoin/src/mempool"
 	transaction "badcoin/src/transaction"
 	wallet "badcoin/src/wallet"
 
 	config "badcoin/src/config"
+	p2p "badcoin/src/p2p"
 
 	proofofwork "badcoin/src/pow"
 
@@ -25,14 +29,19 @@ import (
 	peer "github.com/libp2p/go-libp2p-core/peer"
 	floodsub "github.com/libp2p/go-libp2p-pubsub"
 	mdns "github.com/libp2p/go-libp2p/p2p/discovery/mdns"
+	tcp "github.com/libp2p/go-tcp-transport"
+
+	connmgr "github.com/libp2p/go-libp2p-connmgr"
 
 	bitswap "github.com/ipfs/go-bitswap"
 	network "github.com/ipfs/go-bitswap/network"
 	"github.com/ipfs/go-datastore"
+	cid "github.com/ipfs/go-cid"
 
 	//graphnet "github.com/ipfs/go-graphsync/network"
 
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	leveldb "github.com/syndtr/goleveldb/leveldb"
 	ldbopts "github.com/syndtr/goleveldb/leveldb/opt"
 
 	//
@@ -47,6 +56,12 @@ import (
 // DiscoveryServiceTag is used in our mDNS advertisements to discover other chat peers.
 const DiscoveryServiceTag = "badcoin-network"
 
+// UserAgent is sent to peers in handshake
+const UserAgent = "badcoin:0.0.1"
+
+// Features are protocols which are supported by this node
+var Features = []string{"blocks", "transactions", p2p.FeatureAddr}
+
 type Node struct {
 	p2pNode    host.Host
 	mempool    *mempool.Mempool
@@ -55,6 +70,11 @@ type Node struct {
 	wallet     *wallet.Wallet
 	walletset  *wallet.WalletSet
 	pow        *proofofwork.ProofOfWork
+	peers      *p2p.PeerManager
+	handshaker *p2p.Handshaker
+	networkID  string
+	events     *event.Bus
+	rescan     rescan
 }
 
 func DHTRoutingFactory() func(host.Host) (routing.PeerRouting, error) {
@@ -102,36 +122,107 @@ func makeDHT(h host.Host) (routing.Routing, error) {
 	return r, nil
 }
 
-func CreateNewNode(ctx context.Context, configs *config.Configurations) *Node {
-	var node Node
-
+// hostOptions makes libp2p host options, in private network mode
+// host only connects to peers with same pre-shared key (and in allowlist if it is set)
+func hostOptions(configs *config.Configurations, peers *p2p.PeerManager) ([]libp2p.Option, error) {
 	var opts []libp2p.Option
-	opts = append(opts, libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+
+	listenAddress := configs.P2P.ListenAddress
+	if listenAddress == "" {
+		listenAddress = "/ip4/127.0.0.1/tcp/0"
+	}
+	opts = append(opts, libp2p.ListenAddrStrings(listenAddress))
 	opts = append(opts, libp2p.Routing(DHTRoutingFactory()))
-	//router, _ := makeDHT(h)
-	//nr, _ := nonerouting.ConstructNilRouting(context.TODO(), nil, nil, nil)
 
-	newNode, err := libp2p.New(opts...)
+	identityFile := "data/" + configs.Storage.DBName + "_" + configs.ID + "_p2p.key"
+	identity, err := p2p.LoadIdentity(identityFile)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	opts = append(opts, libp2p.Identity(identity))
 
-	pubsub, err := floodsub.NewFloodSub(ctx, newNode)
+	if configs.P2P.Private.Enabled {
+		psk, err := p2p.LoadPSK(configs.ResolvePath(configs.P2P.Private.PSKFile))
+		if err != nil {
+			logger.Error("loading private network key failed: ", err)
+			return nil, err
+		}
+		opts = append(opts, libp2p.PrivateNetwork(psk))
+		// QUIC transport doesn't support private networks, so only TCP is used
+		opts = append(opts, libp2p.Transport(tcp.NewTCPTransport))
+		logger.Info("private network mode is enabled")
+	}
+
+	gater, err := p2p.NewGater(configs.P2P.Allowlist)
 	if err != nil {
-		panic(err)
+		logger.Error("invalid peer allowlist: ", err)
+		return nil, err
+	}
+	gater.SetBanList(peers)
+	opts = append(opts, libp2p.ConnectionGater(gater))
+	if len(configs.P2P.Allowlist) > 0 {
+		logger.Info("peer allowlist is enabled with ", len(configs.P2P.Allowlist), " peers")
 	}
 
+	// connection manager trims connections when they are more than high water
+	peersConfig := configs.P2P.Peers
+	grace := time.Duration(peersConfig.GracePeriodInSeconds) * time.Second
+	opts = append(opts, libp2p.ConnectionManager(connmgr.NewConnManager(peersConfig.LowWater, peersConfig.HighWater, grace)))
+
+	return opts, nil
+}
+
+// openDatastore opens leveldb datastore which backs the chain block store
+func openDatastore(configs *config.Configurations) (*dsleveldb.Datastore, error) {
 	// base backing datastore, currently just in memory, but can be swapped out
 	// easily for leveldb or other
 	path := "data/" + configs.Storage.DBName + "_" + configs.ID + "_bs"
 	fullpath, _ := filepath.Abs(path)
 	logger.Info("data store path: ", fullpath)
 	//dstore := datastore.NewMapDatastore()
-	dstore, err := dsleveldb.NewDatastore(fullpath, &dsleveldb.Options{
+	return dsleveldb.NewDatastore(fullpath, &dsleveldb.Options{
 		Compression: ldbopts.NoCompression,
 		NoSync:      false,
 		Strict:      ldbopts.StrictAll,
 	})
+}
+
+// InitChain writes genesis block and initial accounts into a fresh data directory
+func InitChain(configs *config.Configurations) (*block.Block, error) {
+	dstore, err := openDatastore(configs)
+	if err != nil {
+		return nil, err
+	}
+	defer dstore.Close()
+
+	return blockchain.InitGenesis(blockstore.NewBlockstore(dstore), configs)
+}
+
+func CreateNewNode(ctx context.Context, configs *config.Configurations) *Node {
+	var node Node
+
+	peersFile := "data/" + configs.Storage.DBName + "_" + configs.ID + "_peers.json"
+	banTime := time.Duration(configs.P2P.Peers.BanTimeInSeconds) * time.Second
+	peers := p2p.NewPeerManager(peersFile, configs.P2P.Peers.BanThreshold, banTime, configs.P2P.Peers.MaxKnownPeers)
+
+	opts, err := hostOptions(configs, peers)
+	if err != nil {
+		panic(err)
+	}
+	//router, _ := makeDHT(h)
+	//nr, _ := nonerouting.ConstructNilRouting(context.TODO(), nil, nil, nil)
+
+	newNode, err := libp2p.New(opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	pubsub, err := floodsub.NewFloodSub(ctx, newNode)
+	if err != nil {
+		panic(err)
+	}
+
+	dstore, err := openDatastore(configs)
 	if err != nil {
 		panic(err)
 	}
@@ -153,31 +244,9 @@ func CreateNewNode(ctx context.Context, configs *config.Configurations) *Node {
 	//bitswapOptions := []bitswap.Option{bitswap.ProvideEnabled(true)}
 	bswap := bitswap.New(context.Background(), net, chainblockstore) //, bitswapOptions...)
 
-	// setup local mDNS discovery
-	if err := setupDiscovery(newNode); err != nil {
-		panic(err)
-	}
-
-	for i, addr := range newNode.Addrs() {
-		logger.Info(i, ": ", addr.String()+"/ipfs/"+newNode.ID().Pretty())
-	}
-
-	if len(os.Args) > 1 {
-		addrstr := os.Args[1]
-		addr, err := ipfsaddr.ParseString(addrstr)
-		if err == nil {
-			pInfo, _ := peer.AddrInfoFromP2pAddr(addr.Multiaddr())
-
-			if err := newNode.Connect(ctx, *pInfo); err != nil {
-				logger.Info("bootstrapping a peer failed", err)
-			}
-			logger.Info("Parse Address:", addr)
-		}
-		//panic(err)
-	}
-
-	blockchain.Init()
 	chain := blockchain.NewBlockchain(newNode, chainblockstore, bswap, configs)
+	events := event.NewBus()
+	chain.Events = events
 
 	ws, errws := wallet.LoadWallets(configs.ID)
 	if errws != nil {
@@ -186,11 +255,20 @@ func CreateNewNode(ctx context.Context, configs *config.Configurations) *Node {
 	}
 	if len(ws.Wallets) == 0 {
 		ws.NodeID = configs.ID
-		wal := ws.CreateWallet()
+		wal, errCreate := ws.CreateWallet()
+		if errCreate != nil {
+			logger.Error("creating wallet failed")
+			panic(errCreate)
+		}
 		ws.SetMinerAddress(wal.GetStringAddress())
-		ws.SaveToFile()
+		logger.Warn("new wallet is not encrypted, encrypt it with a passphrase (bdc-cli encryptwallet or unlock)")
+	}
+	mainwal, errMiner := ws.MinerWallet()
+	if errMiner != nil {
+		// e.g. a locked wallet whose miner address has a legacy key, mining is refused
+		logger.Error("miner address has a legacy key and a new one can't be created: ", errMiner)
+		mainwal = ws.GetWallet(ws.MinerAddress)
 	}
-	mainwal := ws.GetWallet(ws.MinerAddress)
 
 	node.p2pNode = newNode
 	node.mempool = mempool.NewMempool()
@@ -198,16 +276,71 @@ func CreateNewNode(ctx context.Context, configs *config.Configurations) *Node {
 	node.blockchain = chain
 	node.wallet = mainwal
 	node.walletset = ws
+	node.peers = peers
+	node.networkID = configs.P2P.NetworkID
+	node.events = events
 
 	node.ListenBlocks(ctx)
 	node.ListenTransactions(ctx)
 
+	// peers are connected after node is ready, so handshake can run on every connection
+	node.handshaker = p2p.NewHandshaker(newNode, peers, node.Version)
+	addrExchange := p2p.NewAddrExchange(newNode, peers, p2p.NewAddrBook(configs.P2P.Peers.AddrBookSize), configs.P2P.Peers.LowWater)
+	node.handshaker.OnHandshake(func(p peer.ID, version *p2p.Version) {
+		addrExchange.OnHandshake(ctx, p, version)
+	})
+	node.handshaker.Start(ctx)
+	addrExchange.Start(ctx)
+
+	// setup local mDNS discovery
+	if err := setupDiscovery(newNode, peers); err != nil {
+		panic(err)
+	}
+	peers.Start(ctx, newNode)
+
+	for i, addr := range newNode.Addrs() {
+		logger.Info(i, ": ", addr.String()+"/ipfs/"+newNode.ID().Pretty())
+	}
+
+	bootstrapPeers := configs.P2P.Bootstrap
+	if len(os.Args) > 1 {
+		bootstrapPeers = append(bootstrapPeers, os.Args[1])
+	}
+	for _, addrstr := range bootstrapPeers {
+		addr, err := ipfsaddr.ParseString(addrstr)
+		if err == nil {
+			pInfo, _ := peer.AddrInfoFromP2pAddr(addr.Multiaddr())
+
+			if err := newNode.Connect(ctx, *pInfo); err != nil {
+				logger.Info("bootstrapping a peer failed", err)
+			}
+			logger.Info("Parse Address:", addr)
+		}
+		//panic(err)
+	}
+
 	return &node
 }
 
+// Version returns handshake message of this node
+func (node *Node) Version() *p2p.Version {
+	// handshakes run on connection goroutines while blocks are added
+	head := node.blockchain.GetChainTip()
+	return &p2p.Version{
+		ProtocolVersion: p2p.ProtocolVersion,
+		UserAgent:       UserAgent,
+		NetworkID:       node.networkID,
+		GenesisHash:     node.blockchain.GenesisBlock.GetHash().String(),
+		HeadHeight:      head.Height,
+		HeadHash:        head.GetHash().String(),
+		Features:        Features,
+	}
+}
+
 // discoveryNotifee gets notified when we find a new peer via mDNS discovery
 type discoveryNotifee struct {
-	h host.Host
+	h     host.Host
+	peers *p2p.PeerManager
 }
 
 // HandlePeerFound connects to peers discovered via mDNS. Once they're connected,
@@ -217,22 +350,32 @@ func (n *discoveryNotifee) HandlePeerFound(pi peer.AddrInfo) {
 	if pi.ID.String() == n.h.ID().String() {
 		return
 	}
+	if n.peers.IsBanned(pi.ID) {
+		logger.Debug("discovered peer ", pi.ID.Pretty(), " is banned")
+		return
+	}
 	logger.Info("discovered new peer ", pi.ID.Pretty())
 	err := n.h.Connect(context.Background(), pi)
 	if err != nil {
 		logger.Info("error connecting to peer ", pi.ID.Pretty(), ": ", err)
+		return
 	}
 	logger.Info("connected to peer ", pi.ID.Pretty())
 }
 
 // setupDiscovery creates an mDNS discovery service and attaches it to the libp2p Host.
 // This lets us automatically discover peers on the same LAN and connect to them.
-func setupDiscovery(h host.Host) error {
+func setupDiscovery(h host.Host, peers *p2p.PeerManager) error {
 	// setup mDNS discovery to find local peers
-	s := mdns.NewMdnsService(h, DiscoveryServiceTag, &discoveryNotifee{h: h})
+	s := mdns.NewMdnsService(h, DiscoveryServiceTag, &discoveryNotifee{h: h, peers: peers})
 	return s.Start()
 }
 
+// isHandshaked checks whether gossip of a peer is accepted, own messages are always accepted
+func (node *Node) isHandshaked(p peer.ID) bool {
+	return p == node.p2pNode.ID() || node.peers.PeerVersion(p) != nil
+}
+
 func (node *Node) ListenBlocks(ctx context.Context) {
 	sub, err := node.pubsub.Subscribe("blocks")
 	if err != nil {
@@ -244,16 +387,30 @@ func (node *Node) ListenBlocks(ctx context.Context) {
 			if err != nil {
 				panic(err)
 			}
+			if !node.isHandshaked(msg.ReceivedFrom) {
+				logger.Debug("message of peer ", msg.ReceivedFrom.Pretty(), " is dropped, handshake is not done")
+				continue
+			}
 			blk, err := block.DeserializeBlock(msg.GetData())
 			if err != nil {
-				panic(err)
+				node.peers.AddMisbehaviour(msg.ReceivedFrom, p2p.MisbehaviourMalformedMessage, "malformed block")
+				continue
 			}
 			// logger.Info("Block received over network:", string(blk.Serialize()))
 			logger.Info("Block received over network, blockhash: ", blk.GetHash().String())
+			// only checks which don't depend on local chain are penalized, e.g. block may be older than our head
+			if !node.blockchain.CheckBlock(blk) {
+				node.peers.AddMisbehaviour(msg.ReceivedFrom, p2p.MisbehaviourInvalidBlock, "invalid block")
+				continue
+			}
 			cid := node.blockchain.AddBlock(blk)
 			if cid != nil {
 				logger.Info("Block added, cid:", cid)
 				node.mempool.RemoveTxs(blk.Transactions)
+				// expired transactions can't be included in next blocks
+				for _, tx := range node.mempool.RemoveExpired(node.blockchain.GetChainTip().Height + 1) {
+					logger.Info("Expired tx removed from mempool: ", tx.GetTxidString())
+				}
 			}
 		}
 	}()
@@ -270,33 +427,65 @@ func (node *Node) ListenTransactions(ctx context.Context) {
 			if err != nil {
 				panic(err)
 			}
+			if !node.isHandshaked(msg.ReceivedFrom) {
+				logger.Debug("message of peer ", msg.ReceivedFrom.Pretty(), " is dropped, handshake is not done")
+				continue
+			}
 			tx, err := transaction.DeserializeTx(msg.GetData())
 			if err != nil {
-				panic(err)
+				node.peers.AddMisbehaviour(msg.ReceivedFrom, p2p.MisbehaviourMalformedMessage, "malformed transaction")
+				continue
+			}
+			if tx.ChainID != node.ChainID() {
+				node.peers.AddMisbehaviour(msg.ReceivedFrom, p2p.MisbehaviourInvalidTx, "transaction of other chain")
+				continue
 			}
-			node.mempool.AddTx(tx)
-			logger.Info("Tx received over network, added to mempool:", string(tx.Serialize()))
+			if !tx.HasValidValue() {
+				node.peers.AddMisbehaviour(msg.ReceivedFrom, p2p.MisbehaviourInvalidTx, "invalid transaction value")
+				continue
+			}
+			if !tx.VerifySignature() {
+				node.peers.AddMisbehaviour(msg.ReceivedFrom, p2p.MisbehaviourInvalidTx, "invalid transaction signature")
+				continue
+			}
+			// peer may be at another height, so transactions out of validity window are only dropped
+			if !tx.ValidAt(node.blockchain.GetChainTip().Height + 1) {
+				logger.Info("Tx received over network is not valid at next height: ", tx.GetTxidString())
+				continue
+			}
+			// own transactions are received back, they are already in mempool
+			if node.mempool.GetTransaction(tx.GetTxid()) != nil {
+				continue
+			}
+			// like sent transactions, an address has at most one pending transaction
+			if err := node.mempool.SetTransaction(tx.GetTxid(), *tx); err != nil {
+				logger.Info("Tx received over network is dropped: ", tx.GetTxidString(), ", ", err)
+				continue
+			}
+			node.events.Publish(event.TopicPendingTx, &event.TxEvent{Tx: tx})
+			logger.Info("Tx received over network, added to mempool: ", tx.GetTxidString())
 		}
 	}()
 }
 
 func (node *Node) CreateNewBlock() *block.Block {
 	var blk block.Block
-	height := node.blockchain.Head.Height + 1
+	head := node.blockchain.GetChainTip()
+	height := head.Height + 1
 	blkmsg, errmsg := block.ReadBlockMessage(height, "")
 	if errmsg != nil {
 		logger.Error(errmsg)
 		return nil
 	}
 	//header
-	blk.Header.PrevHash = node.blockchain.Head.GetHash()
+	blk.Header.PrevHash = head.GetHash()
 	blk.Header.Version = "0.0.1"
 	blk.Header.Timestamp = time.Now().Unix()
-	blk.Header.Difficulty = node.blockchain.Head.Header.Difficulty
+	blk.Header.Difficulty = head.Header.Difficulty
 	blk.Header.Memo = blkmsg
 	//body
 	blk.Height = height
-	blk.PrevCid = node.blockchain.GetBlockCid(node.blockchain.Head)
+	blk.PrevCid = node.blockchain.GetBlockCid(head)
 	blk.Reward = node.blockchain.CalcReward(blk.Height)
 	getBalance := func(addr string) (*big.Float, uint64, error) {
 		if acc, err := node.blockchain.FetchAccountDetails(addr); err != nil {
@@ -305,7 +494,7 @@ func (node *Node) CreateNewBlock() *block.Block {
 			return &acc.Balance, acc.Nonce, nil
 		}
 	}
-	blk.Transactions = node.mempool.SelectTransactions(getBalance)
+	blk.Transactions = node.mempool.SelectTransactions(height, getBalance)
 	blk.TxsCount = uint64(len(blk.Transactions))
 	return &blk
 }
@@ -319,6 +508,155 @@ func (node *Node) GetBlock(height uint64) (*block.Block, error) {
 	return node.blockchain.GetBlock(height)
 }
 
+// GetBlockByHash returns a block by its header hash
+func (node *Node) GetBlockByHash(blkhash string) (*block.Block, error) {
+	h, err := hash.NewHashFromStr(blkhash)
+	if err != nil {
+		return nil, errors.InvalidHash
+	}
+	return node.blockchain.GetBlockByHash(h)
+}
+
+// GetBlockByCid returns a block by its cid
+func (node *Node) GetBlockByCid(blkcid string) (*block.Block, error) {
+	c, err := cid.Decode(blkcid)
+	if err != nil {
+		return nil, errors.InvalidCid
+	}
+	return node.blockchain.GetBlockByCid(&c)
+}
+
+// GetBlocks returns blocks from height to height (both included)
+func (node *Node) GetBlocks(from uint64, to uint64) ([]*block.Block, error) {
+	return node.blockchain.GetBlocks(from, to)
+}
+
+// GetHead returns chain head
+func (node *Node) GetHead() *block.Block {
+	return node.blockchain.GetChainTip()
+}
+
+// GetTransaction finds a transaction in mempool or blockchain
+func (node *Node) GetTransaction(txid string) (*TransactionResponse, error) {
+	h, err := hash.NewHashFromStr(txid)
+	if err != nil {
+		return nil, errors.InvalidHash
+	}
+	var res TransactionResponse
+	res.Txid = h.String()
+	if tx := node.mempool.GetTransaction(*h); tx != nil {
+		res.Status = TxStatusPending
+		res.Transaction = tx
+		return &res, nil
+	}
+	loc, errLoc := node.blockchain.GetTxLocation(h)
+	if errLoc != nil {
+		if errLoc != errors.NotFoundTransaction {
+			return nil, errLoc
+		}
+		res.Status = TxStatusUnknown
+		return &res, nil
+	}
+	tx, blk, errFind := node.blockchain.FindTransaction(h)
+	if errFind != nil {
+		return nil, errFind
+	}
+	res.Status = TxStatusConfirmed
+	res.Transaction = tx
+	res.Block = NewBlockHeaderResponse(blk)
+	res.BlockCid = loc.BlockCid
+	res.Index = loc.Index
+	if head := node.blockchain.GetChainTip(); head.Height >= blk.Height {
+		res.Confirmations = head.Height - blk.Height + 1
+	}
+	return &res, nil
+}
+
+// GetAccount returns balance and nonce of an address
+func (node *Node) GetAccount(addr string) (*AccountResponse, error) {
+	if !address.ValidateAddress(addr) {
+		return nil, errors.InvalidAddress
+	}
+	var res AccountResponse
+	res.Address = addr
+	res.Balance = big.NewFloat(0)
+	acc, err := node.blockchain.FetchAccountDetails(addr)
+	if err != nil && err != leveldb.ErrNotFound {
+		return nil, err
+	}
+	if err == nil {
+		res.Balance = &acc.Balance
+		res.Nonce = acc.Nonce
+	}
+	res.NextNonce, err = node.nextNonce(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// nextNonce returns nonce of next transaction of an address from chain account,
+// an address has at most one pending transaction so it is always next to chain nonce
+func (node *Node) nextNonce(addr string) (uint64, error) {
+	nonce, err := node.blockchain.GetAccountNonce(addr)
+	if err != nil && err != leveldb.ErrNotFound {
+		return 0, err
+	}
+	return nonce + 1, nil
+}
+
+// MaxHistoryPageSize is the maximum number of address history entries which are returned in a page
+const MaxHistoryPageSize = 100
+
+// GetAddressHistory returns a page of transactions and rewards of an address after cursor (from newest if it is empty),
+// address index should be enabled
+func (node *Node) GetAddressHistory(addr string, cursor string, limit int) (*AddressHistoryResponse, error) {
+	if !address.ValidateAddress(addr) {
+		return nil, errors.InvalidAddress
+	}
+	if limit <= 0 || limit > MaxHistoryPageSize {
+		limit = MaxHistoryPageSize
+	}
+	history, next, err := node.blockchain.GetAddressHistory(addr, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &AddressHistoryResponse{
+		Address: addr,
+		Cursor:  cursor,
+		Next:    next,
+		Limit:   limit,
+		History: history,
+	}, nil
+}
+
+// GetPendingTransactions returns mempool transactions which are sent from or to an address
+func (node *Node) GetPendingTransactions(addr string) (*PendingTransactionsResponse, error) {
+	if !address.ValidateAddress(addr) {
+		return nil, errors.InvalidAddress
+	}
+	res := PendingTransactionsResponse{
+		Address:      addr,
+		Transactions: make([]*transaction.Transaction, 0),
+	}
+	for _, tx := range node.mempool.Transactions() {
+		if tx.From == addr || tx.To == addr {
+			res.Transactions = append(res.Transactions, tx)
+		}
+	}
+	return &res, nil
+}
+
+// GetMempoolTransactions returns pending transactions
+func (node *Node) GetMempoolTransactions() []*transaction.Transaction {
+	return node.mempool.Transactions()
+}
+
+// Events returns event bus of node
+func (node *Node) Events() *event.Bus {
+	return node.events
+}
+
 func (node *Node) GetWallet() *wallet.Wallet {
 	return node.wallet
 }
@@ -327,69 +665,243 @@ func (node *Node) GetWalletSet() *wallet.WalletSet {
 	return node.walletset
 }
 
-func (node *Node) GetNewAddress() *NewAddressResponse {
+// GetNewAddress adds a new key to wallet, encrypted wallet must be unlocked
+func (node *Node) GetNewAddress() (*NewAddressResponse, error) {
+	wal, err := node.walletset.CreateWallet()
+	if err != nil {
+		return nil, err
+	}
 	var res NewAddressResponse
-	addr := node.wallet.GetNewAddress()
-	res.Address = addr
-	return &res
+	res.Address = wal.GetStringAddress()
+	return &res, nil
+}
+
+// SendFromWallet creates a transaction from a wallet address, signs and sends it.
+// Miner address is used if from is empty. Transaction expires if it isn't included in next expiresIn blocks,
+// it never expires if expiresIn is 0
+func (node *Node) SendFromWallet(from string, to string, value float64, data string, expiresIn uint64) (*SendTxResponse, error) {
+	if from == "" {
+		from = node.walletset.GetMinerAddress()
+	}
+	wal := node.walletset.GetWallet(from)
+	if wal == nil {
+		logger.Info("Sending transaction failed, address doesn't belong to wallet: ", from)
+		return nil, errors.NewTxError(errors.ReasonInvalidAddress, wallet.ErrorUnknownAddress)
+	}
+	nonce, err := node.nextNonce(from)
+	if err != nil {
+		logger.Info("Checking account nonce failed: ", err)
+		return nil, errors.NewTxError(errors.ReasonInternal, err)
+	}
+	tx := transaction.NewTransaction(node.ChainID(), wal.PublicKey, nonce, to, value, data)
+	if expiresIn > 0 {
+		tx.SetValidity(0, node.blockchain.GetChainTip().Height+1+expiresIn)
+	}
+	if err := node.walletset.Sign(from, tx.Sign); err != nil {
+		logger.Info("Sending transaction failed: ", err)
+		if err == wallet.ErrorWalletLocked {
+			return nil, errors.NewTxError(errors.ReasonWalletLocked, err)
+		}
+		if err == wallet.ErrorWatchOnly {
+			return nil, errors.NewTxError(errors.ReasonWatchOnly, err)
+		}
+		if err == wallet.ErrorLegacyKey {
+			return nil, errors.NewTxError(errors.ReasonLegacyKey, err)
+		}
+		return nil, errors.NewTxError(errors.ReasonInternal, err)
+	}
+
+	resp, err := node.SendTransaction(tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := node.walletset.SetNonce(from, nonce); err != nil {
+		logger.Error("saving wallet nonce failed: ", err)
+	}
+	return resp, nil
+}
+
+// ImportKey adds an encoded private key to wallet, encrypted wallet must be unlocked
+func (node *Node) ImportKey(key string) (*NewAddressResponse, error) {
+	wal, err := node.walletset.ImportKey(key)
+	if err != nil {
+		logger.Info("Importing key failed: ", err)
+		return nil, err
+	}
+	var res NewAddressResponse
+	res.Address = wal.GetStringAddress()
+	logger.Info("key of ", res.Address, " is imported")
+	return &res, nil
 }
 
-func (node *Node) SendTransaction(tx *transaction.Transaction) *SendTxResponse {
-	// Check that node has key to send tx from address
-	//if node.wallet.GetStringAddress() == tx.From {
+// ExportKey returns encoded private key of a wallet address, encrypted wallet must be unlocked
+func (node *Node) ExportKey(addr string) (*KeyResponse, error) {
+	key, err := node.walletset.ExportKey(addr)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("key of ", addr, " is exported")
+	return &KeyResponse{Address: addr, Key: key}, nil
+}
+
+// AddWatchOnly adds an address which is tracked by wallet without its keys
+func (node *Node) AddWatchOnly(addr string) (*NewAddressResponse, error) {
+	if err := node.walletset.AddWatchOnly(addr); err != nil {
+		return nil, err
+	}
+	logger.Info("watch-only address ", addr, " is added")
+	return &NewAddressResponse{Address: addr}, nil
+}
+
+// EncryptWallet encrypts keys of a plaintext wallet with passphrase, wallet is locked after it
+func (node *Node) EncryptWallet(passphrase string) (*WalletStatusResponse, error) {
+	if err := node.walletset.Encrypt(passphrase); err != nil {
+		logger.Info("Encrypting wallet failed: ", err)
+		return nil, err
+	}
+	return node.GetWalletStatus(), nil
+}
+
+// UnlockWallet decrypts wallet keys for a number of seconds, plaintext wallet must be encrypted first
+func (node *Node) UnlockWallet(passphrase string, seconds uint64) (*WalletStatusResponse, error) {
+	if err := node.walletset.Unlock(passphrase, time.Duration(seconds)*time.Second); err != nil {
+		logger.Info("Unlocking wallet failed: ", err)
+		return nil, err
+	}
+	logger.Info("wallet is unlocked for ", seconds, " seconds")
+	return node.GetWalletStatus(), nil
+}
+
+// LockWallet removes decrypted wallet keys from memory
+func (node *Node) LockWallet() (*WalletStatusResponse, error) {
+	if err := node.walletset.Lock(); err != nil {
+		return nil, err
+	}
+	logger.Info("wallet is locked")
+	return node.GetWalletStatus(), nil
+}
+
+// GetMnemonic returns mnemonic of HD wallet for backup, encrypted wallet must be unlocked
+func (node *Node) GetMnemonic() (*MnemonicResponse, error) {
+	mnemonic, err := node.walletset.Mnemonic()
+	if err != nil {
+		return nil, err
+	}
+	return &MnemonicResponse{Mnemonic: mnemonic}, nil
+}
+
+// SetMnemonic sets mnemonic of wallet (a new one if it is empty) and restores count addresses of it
+func (node *Node) SetMnemonic(mnemonic string, count uint32) (*WalletStatusResponse, error) {
+	if err := node.walletset.SetMnemonic(mnemonic, count); err != nil {
+		logger.Info("Setting wallet mnemonic failed: ", err)
+		return nil, err
+	}
+	logger.Info("wallet is deterministic now, ", count, " addresses are restored")
+	return node.GetWalletStatus(), nil
+}
+
+// GetWalletStatus returns encryption and lock status of wallet
+func (node *Node) GetWalletStatus() *WalletStatusResponse {
+	status := node.walletset.Status()
+	return &WalletStatusResponse{
+		Encrypted:     status.Encrypted,
+		Locked:        status.Locked,
+		HD:            status.HD,
+		UnlockedUntil: status.UnlockedUntil,
+	}
+}
+
+// SendRawTransaction decodes a hex or base64 serialized signed transaction and sends it as-is
+func (node *Node) SendRawTransaction(raw string) (*SendTxResponse, error) {
+	tx, err := transaction.DecodeRawTx(raw)
+	if err != nil {
+		logger.Info("Sending raw transaction failed: ", err)
+		return nil, errors.NewTxError(errors.ReasonInvalidEncoding, errors.InvalidRawTx)
+	}
+	return node.SendTransaction(tx)
+}
 
+// SendTransaction validates transaction, adds it to mempool and broadcasts it.
+// Rejected transactions return a TxError with the reason code
+func (node *Node) SendTransaction(tx *transaction.Transaction) (*SendTxResponse, error) {
+	//transaction must be signed for this network
+	if tx.ChainID != node.ChainID() {
+		logger.Info("Sending transaction failed, TX chain id ", tx.ChainID, " doesn't match network chain id ", node.ChainID())
+		return nil, errors.NewTxError(errors.ReasonInvalidChainID, errors.ChainIDMismatch)
+	}
+	//transaction must be valid in next block
+	height := node.blockchain.GetChainTip().Height + 1
+	if !tx.IsValidYet(height) {
+		logger.Info("Sending transaction failed, TX is valid after height ", tx.ValidAfterHeight, " but next height is ", height)
+		return nil, errors.NewTxError(errors.ReasonNotValidYet, errors.TxNotValidYet)
+	}
+	if tx.IsExpired(height) {
+		logger.Info("Sending transaction failed, TX expired at height ", tx.ExpiresAtHeight)
+		return nil, errors.NewTxError(errors.ReasonExpired, errors.TxExpired)
+	}
 	//validate transaction signature
-	signisvalid := tx.VerifySignature()
-	if signisvalid == false {
+	if !tx.VerifySignature() {
 		logger.Info("Sending transaction failed, TX signature is not valid")
-		return nil
+		return nil, errors.NewTxError(errors.ReasonInvalidSignature, errors.InvalidTxSignature)
 	}
 	//check address to
-	validateaddr := address.ValidateAddress(tx.To)
-	if validateaddr == false {
+	if !address.ValidateAddress(tx.To) {
 		logger.Info("Sending transaction failed, TX dest address is not valid")
-		return nil
+		return nil, errors.NewTxError(errors.ReasonInvalidAddress, errors.InvalidAddress)
+	}
+	if !tx.HasValidValue() {
+		logger.Info("Sending transaction failed, TX value is not valid")
+		return nil, errors.NewTxError(errors.ReasonInvalidValue, errors.InvalidTxValue)
 	}
 	//check account balance
-	if bal, err := node.blockchain.GetAccountBalance(tx.From); err != nil {
-		logger.Info("Sending transaction failed, check balance failed")
-		return nil
-		//panic(errors.New("Sending tx failed, check balance failed"))
-	} else {
-		if bal.Cmp(big.NewFloat(tx.Value)) == -1 {
-			logger.Info("Sending transaction failed, not enough balance:", bal.String())
-			return nil
-			//panic(errors.New("Sending tx failed, account doesn't have enough balance"))
-		}
+	bal, err := node.blockchain.GetAccountBalance(tx.From)
+	if err != nil && err != leveldb.ErrNotFound {
+		logger.Info("Sending transaction failed, check balance failed: ", err)
+		return nil, errors.NewTxError(errors.ReasonInternal, errors.CheckAccountBalanceFailed)
+	}
+	if err == leveldb.ErrNotFound || bal.Cmp(big.NewFloat(tx.Value)) == -1 {
+		logger.Info("Sending transaction failed, not enough balance")
+		return nil, errors.NewTxError(errors.ReasonInsufficientBalance, errors.NotEnoughAccountBalance)
+	}
+	//only one transaction of an account is pending, its nonce is checked against chain account
+	if node.mempool.HasPendingTx(tx.From) {
+		logger.Info("Sending transaction failed, account already has a pending transaction")
+		return nil, errors.NewTxError(errors.ReasonAlreadyPending, errors.AlreadyHasPendingTx)
 	}
 	//check nonce
-	if nonce, err := node.blockchain.GetAccountNonce(tx.From); err != nil {
-		logger.Info("Checking account nonce failed, check balance failed")
-		return nil
-	} else {
-		if tx.Nonce != nonce+1 {
-			logger.Info("Tx nonce is invalid. Current account nonce is: ", nonce, " but tx nonce is: ", tx.Nonce)
-			return nil
-		}
+	nonce, err := node.blockchain.GetAccountNonce(tx.From)
+	if err != nil {
+		logger.Info("Checking account nonce failed: ", err)
+		return nil, errors.NewTxError(errors.ReasonInternal, err)
 	}
-	//TODO: validate TO address
-	var res SendTxResponse
+	if tx.Nonce != nonce+1 {
+		logger.Info("Tx nonce is invalid. Current account nonce is: ", nonce, " but tx nonce is: ", tx.Nonce)
+		return nil, errors.NewTxError(errors.ReasonInvalidNonce, errors.InvalidNonce)
+	}
+
 	txid := tx.GetTxid()
-	node.mempool.SetTransaction(txid, *tx)
+	if err := node.mempool.SetTransaction(txid, *tx); err != nil {
+		logger.Info("Sending transaction failed: ", err)
+		return nil, errors.NewTxError(errors.ReasonAlreadyPending, err)
+	}
+	node.events.Publish(event.TopicPendingTx, &event.TxEvent{Tx: tx})
 	data := tx.Serialize()
 	node.pubsub.Publish("transactions", data)
+
+	var res SendTxResponse
 	res.Txid = tx.GetTxidString()
-	return &res
+	return &res, nil
+}
 
-	// } else {
-	// 	logger.Info("Sending transaction failed")
-	// 	panic(errors.New("Sending tx failed, no key present in wallet"))
-	// }
+// ChainID returns id of the network which transactions are signed for
+func (node *Node) ChainID() uint64 {
+	return node.blockchain.ChainID()
 }
 
 func (node *Node) GetInfo() *GetInfoResponse {
 	var res GetInfoResponse
-	res.BlockHeight = node.blockchain.Head.Height
+	res.ChainID = node.ChainID()
+	res.BlockHeight = node.blockchain.GetChainTip().Height
 	res.NodeAddress = node.wallet.GetStringAddress()
 	bal, errBalance := node.blockchain.GetAccountBalance(node.wallet.GetStringAddress())
 	if errBalance != nil {
@@ -398,3 +910,39 @@ func (node *Node) GetInfo() *GetInfoResponse {
 	res.NodeBalance = bal
 	return &res
 }
+
+// GetPeers returns peers which are tracked by peer manager
+func (node *Node) GetPeers() *PeersResponse {
+	var res PeersResponse
+	res.Peers = node.peers.Peers()
+	return &res
+}
+
+// BanPeer bans a peer for a duration, zero duration is replaced by configured ban time
+func (node *Node) BanPeer(id string, duration time.Duration) (*BanPeerResponse, error) {
+	p, err := peer.Decode(id)
+	if err != nil {
+		return nil, err
+	}
+	if duration == 0 {
+		duration = node.peers.BanDuration()
+	}
+	node.peers.Ban(p, duration)
+	var res BanPeerResponse
+	res.Peer = p.Pretty()
+	res.Banned = true
+	return &res, nil
+}
+
+// UnbanPeer removes a peer from ban list
+func (node *Node) UnbanPeer(id string) (*BanPeerResponse, error) {
+	p, err := peer.Decode(id)
+	if err != nil {
+		return nil, err
+	}
+	node.peers.Unban(p)
+	var res BanPeerResponse
+	res.Peer = p.Pretty()
+	res.Banned = false
+	return &res, nil
+}
@@ -0,0 +1,99 @@
+package node
+
+import (
+	"sync"
+	"time"
+
+	config "badcoin/src/config"
+	errors "badcoin/src/helper/error"
+	logger "badcoin/src/helper/logger"
+	scan "badcoin/src/wallet/scan"
+)
+
+// rescan keeps progress of wallet rescan, only one rescan runs at a time
+type rescan struct {
+	mutex  sync.Mutex
+	status RescanResponse
+}
+
+// RescanWallet rebuilds balances, nonces and history of wallet addresses from blocks since height from.
+// Rescan runs in background, its progress is returned by GetRescanStatus
+func (node *Node) RescanWallet(from uint64) (*RescanResponse, error) {
+	to := node.blockchain.GetChainTip().Height
+	if from > to {
+		return nil, errors.InvalidHeight
+	}
+
+	node.rescan.mutex.Lock()
+	defer node.rescan.mutex.Unlock()
+	if node.rescan.status.Running {
+		return nil, errors.RescanInProgress
+	}
+	node.rescan.status = RescanResponse{
+		Running:   true,
+		From:      from,
+		To:        to,
+		StartedAt: time.Now().Unix(),
+	}
+	logger.Info("wallet rescan started from height ", from, " to ", to)
+	go node.runRescan(from, to)
+
+	status := node.rescan.status
+	return &status, nil
+}
+
+// GetRescanStatus returns progress of running or last wallet rescan
+func (node *Node) GetRescanStatus() *RescanResponse {
+	node.rescan.mutex.Lock()
+	defer node.rescan.mutex.Unlock()
+	status := node.rescan.status
+	return &status
+}
+
+// GetWalletHistory returns balance, nonce and history of a wallet address, or of all addresses if it is empty
+func (node *Node) GetWalletHistory(addr string) (*WalletHistoryResponse, error) {
+	addresses := []string{addr}
+	if addr == "" {
+		addresses = node.walletset.GetAddresses()
+	}
+	var res WalletHistoryResponse
+	res.ScannedHeight, res.Scanned = node.walletset.ScannedHeight()
+	for _, address := range addresses {
+		account, err := node.walletset.AccountHistory(address)
+		if err != nil {
+			return nil, err
+		}
+		res.Accounts = append(res.Accounts, account)
+	}
+	return &res, nil
+}
+
+func (node *Node) runRescan(from uint64, to uint64) {
+	var alloc []config.GenesisAlloc
+	if node.blockchain.Configs != nil {
+		alloc = node.blockchain.Configs.Genesis.Alloc
+	}
+	err := scan.Rescan(node.walletset, from, to, node.blockchain.GetBlock, alloc, node.rescan.progress)
+	node.rescan.finish(err)
+}
+
+func (r *rescan) progress(found int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.status.Scanned++
+	r.status.Found += found
+	r.status.Progress = float64(r.status.Scanned) * 100 / float64(r.status.To-r.status.From+1)
+}
+
+func (r *rescan) finish(err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.status.Running = false
+	r.status.FinishedAt = time.Now().Unix()
+	if err != nil {
+		r.status.Error = err.Error()
+		logger.Error("wallet rescan failed: ", err)
+		return
+	}
+	logger.Info("wallet rescan finished, ", r.status.Found, " transactions are found")
+}
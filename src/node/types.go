@@ -2,6 +2,12 @@ package node
 
 import (
 	"math/big"
+
+	block "badcoin/src/block"
+	blockchain "badcoin/src/blockchain"
+	p2p "badcoin/src/p2p"
+	transaction "badcoin/src/transaction"
+	wallet "badcoin/src/wallet"
 )
 
 type HealthCheckResponse struct {
@@ -9,15 +15,130 @@ type HealthCheckResponse struct {
 }
 
 type GetInfoResponse struct {
+	ChainID     uint64
 	BlockHeight uint64
 	NodeAddress string
 	NodeBalance *big.Float
 }
 
+// WalletStatusResponse shows if wallet is encrypted, locked and deterministic, unlock expiry is a unix time
+type WalletStatusResponse struct {
+	Encrypted     bool
+	Locked        bool
+	HD            bool
+	UnlockedUntil int64 `json:",omitempty"`
+}
+
+// MnemonicResponse is the backup phrase of HD wallet
+type MnemonicResponse struct {
+	Mnemonic string
+}
+
+// RescanResponse is progress of wallet rescan, blocks from height From to To are scanned
+type RescanResponse struct {
+	Running    bool
+	From       uint64
+	To         uint64
+	Scanned    uint64  // number of scanned blocks
+	Progress   float64 // percent of scanned blocks
+	Found      int     // number of found wallet transactions
+	Error      string  `json:",omitempty"`
+	StartedAt  int64   `json:",omitempty"`
+	FinishedAt int64   `json:",omitempty"`
+}
+
+// WalletHistoryResponse is balances, nonces and history of wallet addresses which are rebuilt by rescan
+type WalletHistoryResponse struct {
+	Scanned       bool
+	ScannedHeight uint64
+	Accounts      []*wallet.AccountHistory
+}
+
 type SendTxResponse struct {
 	Txid string
 }
 
+// transaction statuses
+const (
+	TxStatusPending   = "pending"
+	TxStatusConfirmed = "confirmed"
+	TxStatusUnknown   = "unknown"
+)
+
+// TransactionResponse is a transaction and its block, block is set for confirmed transactions
+type TransactionResponse struct {
+	Txid          string
+	Status        string
+	Transaction   *transaction.Transaction
+	Block         *BlockHeaderResponse
+	BlockCid      string
+	Index         int
+	Confirmations uint64
+}
+
+// AccountResponse is balance and nonce of an address, NextNonce is nonce of its next transaction
+type AccountResponse struct {
+	Address   string
+	Balance   *big.Float
+	Nonce     uint64
+	NextNonce uint64
+}
+
+// AddressHistoryResponse is a page of address history, newest first.
+// Next is the cursor of next page, it is empty on the last page
+type AddressHistoryResponse struct {
+	Address string
+	Cursor  string
+	Next    string
+	Limit   int
+	History []*blockchain.AddressTx
+}
+
+type PendingTransactionsResponse struct {
+	Address      string
+	Transactions []*transaction.Transaction
+}
+
 type NewAddressResponse struct {
 	Address string
 }
+
+// KeyResponse is an encoded private key of an address
+type KeyResponse struct {
+	Address string
+	Key     string
+}
+
+type PeersResponse struct {
+	Peers []p2p.PeerInfo
+}
+
+type BanPeerResponse struct {
+	Peer   string
+	Banned bool
+}
+
+// ErrorResponse is the body of failed requests, Code is the reason code of error
+type ErrorResponse struct {
+	Code    string
+	Message string
+}
+
+// BlockHeaderResponse is a block without its transactions
+type BlockHeaderResponse struct {
+	Height   uint64
+	Hash     string
+	Header   block.BlockHeader
+	TxsCount uint64
+}
+
+// NewBlockHeaderResponse creates header response of a block
+func NewBlockHeaderResponse(blk *block.Block) *BlockHeaderResponse {
+	blkhash := blk.GetHash()
+	return &BlockHeaderResponse{
+		Height:   blk.Height,
+		Hash:     blkhash.String(),
+		Header:   blk.Header,
+		TxsCount: blk.TxsCount,
+	}
+}
@@ -0,0 +1,230 @@
+package p2p
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	multiaddr "github.com/multiformats/go-multiaddr"
+)
+
+// addresses which are not seen for this duration are stale and removed from address book
+const addrStaleTime = 24 * time.Hour
+
+// maxAddrsPerPeer is the number of multiaddrs which are kept and sent for a peer
+const maxAddrsPerPeer = 8
+
+// maxAddrsPerSource is the number of learned addresses which are kept from one peer
+const maxAddrsPerSource = 50
+
+// last seen time of a learned address is at least this old, so peers can't make their addresses fresher than good ones
+const addrTimePenalty = 2 * time.Hour
+
+// AddrEntry is a peer address which is stored in address book
+type AddrEntry struct {
+	Info     peer.AddrInfo
+	LastSeen time.Time
+	Failures int
+	Source   peer.ID // peer which sent address, empty for good addresses
+}
+
+// Score returns freshness score of address, recently seen addresses without dial failures get higher score
+func (e *AddrEntry) Score(now time.Time) float64 {
+	age := now.Sub(e.LastSeen)
+	if age < 0 {
+		age = 0
+	}
+	freshness := 1 - float64(age)/float64(addrStaleTime)
+	return freshness / float64(1+e.Failures)
+}
+
+// AddrBook is a bounded address book of peers. Addresses which are learned from addr messages
+// and addresses of peers which node has connected to are kept in separate sets of at most maxSize,
+// so learned addresses never evict good ones
+type AddrBook struct {
+	mutex   *sync.RWMutex
+	maxSize int
+	entries map[peer.ID]*AddrEntry // learned addresses
+	good    map[peer.ID]*AddrEntry
+	sources map[peer.ID]int // number of learned addresses of each source
+}
+
+// NewAddrBook creates an address book which keeps at most maxSize learned and maxSize good addresses
+func NewAddrBook(maxSize int) *AddrBook {
+	return &AddrBook{
+		mutex:   new(sync.RWMutex),
+		maxSize: maxSize,
+		entries: make(map[peer.ID]*AddrEntry),
+		good:    make(map[peer.ID]*AddrEntry),
+		sources: make(map[peer.ID]int),
+	}
+}
+
+// Add adds or refreshes a learned address which is sent by source, last seen time of peer is penalized.
+// When learned addresses are full the one with lowest score is evicted
+func (ab *AddrBook) Add(info peer.AddrInfo, lastSeen time.Time, source peer.ID) {
+	if len(info.Addrs) == 0 {
+		return
+	}
+	now := time.Now()
+	if latest := now.Add(-addrTimePenalty); lastSeen.After(latest) {
+		lastSeen = latest
+	}
+	if now.Sub(lastSeen) > addrStaleTime {
+		return
+	}
+
+	ab.mutex.Lock()
+	defer ab.mutex.Unlock()
+
+	if _, ok := ab.good[info.ID]; ok {
+		return
+	}
+	if e, ok := ab.entries[info.ID]; ok {
+		if lastSeen.After(e.LastSeen) {
+			e.LastSeen = lastSeen
+			e.Info.Addrs = mergeAddrs(e.Info.Addrs, info.Addrs)
+		}
+		return
+	}
+	if ab.sources[source] >= maxAddrsPerSource {
+		return
+	}
+	info.Addrs = mergeAddrs(nil, info.Addrs)
+	entry := &AddrEntry{Info: info, LastSeen: lastSeen, Source: source}
+	if !ab.makeRoom(ab.entries, entry, now) {
+		return
+	}
+	ab.entries[info.ID] = entry
+	ab.sources[source]++
+}
+
+// MarkGood moves an address to good addresses after successful connection
+func (ab *AddrBook) MarkGood(info peer.AddrInfo) {
+	if len(info.Addrs) == 0 {
+		return
+	}
+	ab.mutex.Lock()
+	defer ab.mutex.Unlock()
+
+	ab.removeLearned(info.ID)
+	entry := &AddrEntry{Info: info, LastSeen: time.Now()}
+	if e, ok := ab.good[info.ID]; ok {
+		entry.Info.Addrs = mergeAddrs(e.Info.Addrs, info.Addrs)
+	} else {
+		entry.Info.Addrs = mergeAddrs(nil, info.Addrs)
+		if !ab.makeRoom(ab.good, entry, entry.LastSeen) {
+			return
+		}
+	}
+	ab.good[info.ID] = entry
+}
+
+// makeRoom evicts the address with lowest score from a full set of addresses,
+// it returns false if new entry has lower score
+func (ab *AddrBook) makeRoom(set map[peer.ID]*AddrEntry, entry *AddrEntry, now time.Time) bool {
+	if len(set) < ab.maxSize {
+		return true
+	}
+	var worst peer.ID
+	worstScore := 0.0
+	for id, e := range set {
+		score := e.Score(now)
+		if worst == "" || score < worstScore {
+			worst = id
+			worstScore = score
+		}
+	}
+	if entry.Score(now) <= worstScore {
+		return false
+	}
+	ab.remove(worst)
+	return true
+}
+
+// MarkFailed records a dial failure, addresses with too many failures are removed
+func (ab *AddrBook) MarkFailed(p peer.ID) {
+	ab.mutex.Lock()
+	defer ab.mutex.Unlock()
+	for _, set := range []map[peer.ID]*AddrEntry{ab.entries, ab.good} {
+		if e, ok := set[p]; ok {
+			e.Failures++
+			if e.Failures >= 3 {
+				ab.remove(p)
+			}
+		}
+	}
+}
+
+// Remove removes peer from address book
+func (ab *AddrBook) Remove(p peer.ID) {
+	ab.mutex.Lock()
+	defer ab.mutex.Unlock()
+	ab.remove(p)
+}
+
+func (ab *AddrBook) remove(p peer.ID) {
+	ab.removeLearned(p)
+	delete(ab.good, p)
+}
+
+func (ab *AddrBook) removeLearned(p peer.ID) {
+	e, ok := ab.entries[p]
+	if !ok {
+		return
+	}
+	delete(ab.entries, p)
+	if ab.sources[e.Source]--; ab.sources[e.Source] <= 0 {
+		delete(ab.sources, e.Source)
+	}
+}
+
+// Best returns at most n addresses ordered by score, stale addresses are removed
+func (ab *AddrBook) Best(n int) []AddrEntry {
+	now := time.Now()
+	ab.mutex.Lock()
+	entries := make([]AddrEntry, 0, len(ab.entries)+len(ab.good))
+	for _, set := range []map[peer.ID]*AddrEntry{ab.entries, ab.good} {
+		for id, e := range set {
+			if now.Sub(e.LastSeen) > addrStaleTime {
+				ab.remove(id)
+				continue
+			}
+			entries = append(entries, *e)
+		}
+	}
+	ab.mutex.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Score(now) > entries[j].Score(now)
+	})
+	if len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+// Size returns number of addresses in address book
+func (ab *AddrBook) Size() int {
+	ab.mutex.RLock()
+	defer ab.mutex.RUnlock()
+	return len(ab.entries) + len(ab.good)
+}
+
+// mergeAddrs returns new addresses and then current ones without duplicates,
+// at most maxAddrsPerPeer addresses are kept, so older addresses are dropped first
+func mergeAddrs(cur []multiaddr.Multiaddr, addrs []multiaddr.Multiaddr) []multiaddr.Multiaddr {
+	var res []multiaddr.Multiaddr
+	for _, list := range [][]multiaddr.Multiaddr{addrs, cur} {
+		for _, addr := range list {
+			if len(res) >= maxAddrsPerPeer {
+				return res
+			}
+			if !containsAddr(res, addr) {
+				res = append(res, addr)
+			}
+		}
+	}
+	return res
+}
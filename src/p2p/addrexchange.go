@@ -0,0 +1,257 @@
+package p2p
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	logger "badcoin/src/helper/logger"
+
+	host "github.com/libp2p/go-libp2p-core/host"
+	network "github.com/libp2p/go-libp2p-core/network"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	protocol "github.com/libp2p/go-libp2p-core/protocol"
+	multiaddr "github.com/multiformats/go-multiaddr"
+)
+
+// AddrProtocol is the libp2p protocol of getaddr/addr messages
+const AddrProtocol = protocol.ID("/bdc/addr/1.0.0")
+
+// FeatureAddr is the handshake feature of peers which support address exchange
+const FeatureAddr = "addr"
+
+const (
+	maxAddrsPerMessage  = 100
+	maxAddrMsgSize      = 1 << 18
+	addrExchangeTimeout = 10 * time.Second
+	addrExchangeRound   = time.Minute
+	dialTimeout         = 10 * time.Second
+)
+
+// GetAddr requests known peer addresses
+type GetAddr struct {
+	Max int
+}
+
+// AddrPeer is a peer address in addr message
+type AddrPeer struct {
+	ID       string
+	Addrs    []string
+	LastSeen int64
+}
+
+// Addr is the reply of getaddr
+type Addr struct {
+	Peers []AddrPeer
+}
+
+// Oversized checks whether addr message has more peers or more addresses of a peer than allowed
+func (msg *Addr) Oversized() bool {
+	if len(msg.Peers) > maxAddrsPerMessage {
+		return true
+	}
+	for _, ap := range msg.Peers {
+		if len(ap.Addrs) > maxAddrsPerPeer {
+			return true
+		}
+	}
+	return false
+}
+
+// AddrExchange shares known good peer addresses with other peers and
+// connects to new peers until node has enough connections
+type AddrExchange struct {
+	host        host.Host
+	peers       *PeerManager
+	book        *AddrBook
+	targetPeers int
+}
+
+// NewAddrExchange creates address exchange, node tries to keep targetPeers connections
+func NewAddrExchange(h host.Host, peers *PeerManager, book *AddrBook, targetPeers int) *AddrExchange {
+	return &AddrExchange{
+		host:        h,
+		peers:       peers,
+		book:        book,
+		targetPeers: targetPeers,
+	}
+}
+
+// Start registers addr protocol handler and starts periodic address exchange
+func (ax *AddrExchange) Start(ctx context.Context) {
+	ax.host.SetStreamHandler(AddrProtocol, ax.handleStream)
+	go ax.loop(ctx)
+}
+
+// OnHandshake is called after a successful handshake,
+// peer is added to address book and asked for its known addresses
+func (ax *AddrExchange) OnHandshake(ctx context.Context, p peer.ID, version *Version) {
+	addrs := ax.host.Peerstore().Addrs(p)
+	if len(addrs) > 0 {
+		ax.book.MarkGood(peer.AddrInfo{ID: p, Addrs: addrs})
+	}
+	if version.HasFeature(FeatureAddr) && ax.needPeers() {
+		go ax.RequestAddrs(ctx, p)
+	}
+}
+
+// RequestAddrs sends getaddr to peer, stores received addresses and connects to new peers
+func (ax *AddrExchange) RequestAddrs(ctx context.Context, p peer.ID) error {
+	s, err := ax.host.NewStream(ctx, p, AddrProtocol)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(addrExchangeTimeout))
+
+	if err := json.NewEncoder(s).Encode(&GetAddr{Max: maxAddrsPerMessage}); err != nil {
+		s.Reset()
+		return err
+	}
+	var msg Addr
+	if err := json.NewDecoder(io.LimitReader(s, maxAddrMsgSize)).Decode(&msg); err != nil {
+		s.Reset()
+		ax.peers.AddMisbehaviour(p, MisbehaviourMalformedMessage, "malformed addr message")
+		return err
+	}
+	if msg.Oversized() {
+		ax.peers.AddMisbehaviour(p, MisbehaviourMalformedMessage, "too many addresses in addr message")
+		return nil
+	}
+
+	added := 0
+	for _, ap := range msg.Peers {
+		info, err := ap.AddrInfo()
+		if err != nil || info.ID == ax.host.ID() || ax.peers.IsBanned(info.ID) {
+			continue
+		}
+		ax.book.Add(*info, time.Unix(ap.LastSeen, 0), p)
+		added++
+	}
+	logger.Info("received ", added, " addresses from peer ", p.Pretty())
+
+	ax.connectToPeers(ctx)
+	return nil
+}
+
+// handleStream replies getaddr with known good addresses
+func (ax *AddrExchange) handleStream(s network.Stream) {
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(addrExchangeTimeout))
+	requester := s.Conn().RemotePeer()
+
+	var req GetAddr
+	if err := json.NewDecoder(io.LimitReader(s, maxAddrMsgSize)).Decode(&req); err != nil {
+		s.Reset()
+		ax.peers.AddMisbehaviour(requester, MisbehaviourMalformedMessage, "malformed getaddr message")
+		return
+	}
+	max := req.Max
+	if max <= 0 || max > maxAddrsPerMessage {
+		max = maxAddrsPerMessage
+	}
+	if err := json.NewEncoder(s).Encode(ax.knownAddrs(requester, max)); err != nil {
+		s.Reset()
+	}
+}
+
+// knownAddrs returns addresses of connected peers which are handshaked and best addresses of address book
+func (ax *AddrExchange) knownAddrs(requester peer.ID, max int) *Addr {
+	var msg Addr
+	added := make(map[peer.ID]bool)
+	add := func(id peer.ID, addrs []multiaddr.Multiaddr, lastSeen time.Time) {
+		if len(msg.Peers) >= max || added[id] || id == requester || len(addrs) == 0 {
+			return
+		}
+		added[id] = true
+		ap := AddrPeer{ID: id.Pretty(), LastSeen: lastSeen.Unix()}
+		if len(addrs) > maxAddrsPerPeer {
+			addrs = addrs[:maxAddrsPerPeer]
+		}
+		for _, addr := range addrs {
+			ap.Addrs = append(ap.Addrs, addr.String())
+		}
+		msg.Peers = append(msg.Peers, ap)
+	}
+
+	now := time.Now()
+	for _, p := range ax.host.Network().Peers() {
+		if ax.peers.PeerVersion(p) != nil {
+			add(p, ax.host.Peerstore().Addrs(p), now)
+		}
+	}
+	for _, e := range ax.book.Best(max) {
+		add(e.Info.ID, e.Info.Addrs, e.LastSeen)
+	}
+	return &msg
+}
+
+// needPeers checks whether node has less connections than target
+func (ax *AddrExchange) needPeers() bool {
+	return len(ax.host.Network().Peers()) < ax.targetPeers
+}
+
+// connectToPeers dials best addresses of address book until node has enough connections
+func (ax *AddrExchange) connectToPeers(ctx context.Context) {
+	for _, e := range ax.book.Best(ax.targetPeers) {
+		if !ax.needPeers() {
+			return
+		}
+		p := e.Info.ID
+		if p == ax.host.ID() || ax.peers.IsBanned(p) || ax.host.Network().Connectedness(p) == network.Connected {
+			continue
+		}
+		dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+		err := ax.host.Connect(dialCtx, e.Info)
+		cancel()
+		if err != nil {
+			logger.Debug("connecting to peer ", p.Pretty(), " from address book failed: ", err)
+			ax.book.MarkFailed(p)
+			continue
+		}
+		logger.Info("connected to peer ", p.Pretty(), " from address book")
+		ax.book.MarkGood(e.Info)
+	}
+}
+
+// loop asks a connected peer for addresses periodically while node needs more connections
+func (ax *AddrExchange) loop(ctx context.Context) {
+	ticker := time.NewTicker(addrExchangeRound)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !ax.needPeers() {
+				continue
+			}
+			for _, p := range ax.host.Network().Peers() {
+				version := ax.peers.PeerVersion(p)
+				if version != nil && version.HasFeature(FeatureAddr) {
+					ax.RequestAddrs(ctx, p)
+					break
+				}
+			}
+			ax.connectToPeers(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// AddrInfo parses peer id and multiaddrs of addr message
+func (ap *AddrPeer) AddrInfo() (*peer.AddrInfo, error) {
+	id, err := peer.Decode(ap.ID)
+	if err != nil {
+		return nil, err
+	}
+	info := peer.AddrInfo{ID: id}
+	for _, a := range ap.Addrs {
+		addr, err := multiaddr.NewMultiaddr(a)
+		if err != nil {
+			return nil, err
+		}
+		info.Addrs = append(info.Addrs, addr)
+	}
+	return &info, nil
+}
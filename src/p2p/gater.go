@@ -0,0 +1,93 @@
+package p2p
+
+import (
+	"sync"
+
+	control "github.com/libp2p/go-libp2p-core/control"
+	network "github.com/libp2p/go-libp2p-core/network"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	multiaddr "github.com/multiformats/go-multiaddr"
+)
+
+// BanList tells whether a peer is banned
+type BanList interface {
+	IsBanned(p peer.ID) bool
+}
+
+// Gater is a libp2p connection gater which admits only allowlisted peers and rejects banned peers.
+// Peers are checked at transport level, so outsiders which are found by mDNS or DHT
+// can't connect to node
+type Gater struct {
+	mutex     *sync.RWMutex
+	allowlist map[peer.ID]bool
+	banlist   BanList
+}
+
+// NewGater creates a connection gater from a list of peer IDs
+// an empty list admits all peers
+func NewGater(allowlist []string) (*Gater, error) {
+	gater := &Gater{
+		mutex:     new(sync.RWMutex),
+		allowlist: make(map[peer.ID]bool),
+	}
+	for _, p := range allowlist {
+		pid, err := peer.Decode(p)
+		if err != nil {
+			return nil, err
+		}
+		gater.allowlist[pid] = true
+	}
+	return gater, nil
+}
+
+// Allow adds a peer to allowlist
+func (g *Gater) Allow(p peer.ID) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.allowlist[p] = true
+}
+
+// SetBanList sets ban list which is checked on every connection
+func (g *Gater) SetBanList(banlist BanList) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.banlist = banlist
+}
+
+// IsAllowed checks whether peer is in allowlist and it is not banned
+func (g *Gater) IsAllowed(p peer.ID) bool {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	if g.banlist != nil && g.banlist.IsBanned(p) {
+		return false
+	}
+	if len(g.allowlist) == 0 {
+		return true
+	}
+	return g.allowlist[p]
+}
+
+// InterceptPeerDial tests whether we're permitted to Dial the specified peer
+func (g *Gater) InterceptPeerDial(p peer.ID) bool {
+	return g.IsAllowed(p)
+}
+
+// InterceptAddrDial tests whether we're permitted to dial the specified multiaddr for the given peer
+func (g *Gater) InterceptAddrDial(p peer.ID, addr multiaddr.Multiaddr) bool {
+	return g.IsAllowed(p)
+}
+
+// InterceptAccept admits all inbound connections, peer is not known before security handshake
+func (g *Gater) InterceptAccept(addrs network.ConnMultiaddrs) bool {
+	return true
+}
+
+// InterceptSecured tests whether an authenticated connection is allowed
+func (g *Gater) InterceptSecured(dir network.Direction, p peer.ID, addrs network.ConnMultiaddrs) bool {
+	return g.IsAllowed(p)
+}
+
+// InterceptUpgraded admits upgraded connections, they are already checked in InterceptSecured
+func (g *Gater) InterceptUpgraded(conn network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
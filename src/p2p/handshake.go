@@ -0,0 +1,195 @@
+package p2p
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	errors "badcoin/src/helper/error"
+	logger "badcoin/src/helper/logger"
+
+	host "github.com/libp2p/go-libp2p-core/host"
+	network "github.com/libp2p/go-libp2p-core/network"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	protocol "github.com/libp2p/go-libp2p-core/protocol"
+)
+
+// HandshakeProtocol is the libp2p protocol which is run on every new connection
+const HandshakeProtocol = protocol.ID("/bdc/handshake/1.0.0")
+
+// ProtocolVersion is the current version of bdc protocol,
+// peers with a version less than MinProtocolVersion are disconnected
+const (
+	ProtocolVersion    = 1
+	MinProtocolVersion = 1
+)
+
+const (
+	handshakeTimeout    = 10 * time.Second
+	maxHandshakeMsgSize = 1 << 16
+)
+
+// Version is the handshake message which is exchanged by peers
+type Version struct {
+	ProtocolVersion uint32
+	UserAgent       string
+	NetworkID       string
+	GenesisHash     string
+	HeadHeight      uint64
+	HeadHash        string
+	Features        []string
+}
+
+// HasFeature checks whether peer supports a feature
+func (v *Version) HasFeature(feature string) bool {
+	for _, f := range v.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// Handshaker runs handshake protocol on new connections and disconnects incompatible peers
+type Handshaker struct {
+	host     host.Host
+	peers    *PeerManager
+	local    func() *Version
+	handlers []func(peer.ID, *Version)
+}
+
+// NewHandshaker creates a handshaker, local returns version message of this node
+func NewHandshaker(h host.Host, peers *PeerManager, local func() *Version) *Handshaker {
+	return &Handshaker{
+		host:  h,
+		peers: peers,
+		local: local,
+	}
+}
+
+// OnHandshake registers a handler which is called after a successful handshake,
+// handlers should be registered before start
+func (hs *Handshaker) OnHandshake(handler func(peer.ID, *Version)) {
+	hs.handlers = append(hs.handlers, handler)
+}
+
+// Start registers handshake protocol handler and runs handshake on new outbound connections.
+// Inbound side replies in stream handler, so each connection has only one handshake,
+// inbound peers which don't start handshake are disconnected
+func (hs *Handshaker) Start(ctx context.Context) {
+	hs.host.SetStreamHandler(HandshakeProtocol, hs.handleStream)
+	hs.host.Network().Notify(&network.NotifyBundle{
+		ConnectedF: func(n network.Network, conn network.Conn) {
+			hs.onConnected(ctx, conn)
+		},
+	})
+
+	// connections which are made before start
+	for _, conn := range hs.host.Network().Conns() {
+		hs.onConnected(ctx, conn)
+	}
+}
+
+// onConnected starts handshake of an outbound connection or waits for handshake of an inbound one
+func (hs *Handshaker) onConnected(ctx context.Context, conn network.Conn) {
+	if conn.Stat().Direction == network.DirOutbound {
+		go hs.Handshake(ctx, conn.RemotePeer())
+	} else {
+		go hs.awaitHandshake(ctx, conn.RemotePeer())
+	}
+}
+
+// awaitHandshake disconnects an inbound peer if it doesn't complete handshake in time
+func (hs *Handshaker) awaitHandshake(ctx context.Context, p peer.ID) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(2 * handshakeTimeout):
+	}
+	if hs.peers.PeerVersion(p) != nil || hs.host.Network().Connectedness(p) != network.Connected {
+		return
+	}
+	hs.reject(p, errors.HandshakeTimeout)
+}
+
+// Handshake sends version of this node to peer and verifies peer's version
+func (hs *Handshaker) Handshake(ctx context.Context, p peer.ID) error {
+	s, err := hs.host.NewStream(ctx, p, HandshakeProtocol)
+	if err != nil {
+		logger.Info("handshake with peer ", p.Pretty(), " failed: ", err)
+		hs.host.Network().ClosePeer(p)
+		return err
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(handshakeTimeout))
+
+	local := hs.local()
+	if err := json.NewEncoder(s).Encode(local); err != nil {
+		s.Reset()
+		return err
+	}
+	var remote Version
+	if err := json.NewDecoder(io.LimitReader(s, maxHandshakeMsgSize)).Decode(&remote); err != nil {
+		s.Reset()
+		hs.reject(p, err)
+		return err
+	}
+	return hs.verify(p, local, &remote)
+}
+
+// handleStream replies to handshake of an inbound connection
+func (hs *Handshaker) handleStream(s network.Stream) {
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(handshakeTimeout))
+	p := s.Conn().RemotePeer()
+
+	var remote Version
+	if err := json.NewDecoder(io.LimitReader(s, maxHandshakeMsgSize)).Decode(&remote); err != nil {
+		s.Reset()
+		hs.reject(p, err)
+		return
+	}
+	local := hs.local()
+	if err := json.NewEncoder(s).Encode(local); err != nil {
+		s.Reset()
+		return
+	}
+	hs.verify(p, local, &remote)
+}
+
+// verify checks remote version against local version
+func (hs *Handshaker) verify(p peer.ID, local *Version, remote *Version) error {
+	var err error
+	if remote.ProtocolVersion < MinProtocolVersion {
+		err = errors.IncompatibleProtocolVersion
+	} else if remote.NetworkID != local.NetworkID {
+		err = errors.NetworkMismatch
+	} else if remote.GenesisHash != local.GenesisHash {
+		err = errors.GenesisMismatch
+	}
+	if err != nil {
+		hs.reject(p, err)
+		return err
+	}
+
+	hs.peers.SetVersion(p, remote)
+
+	logger.Info("handshake with peer ", p.Pretty(), " done, version: ", remote.ProtocolVersion,
+		", agent: ", remote.UserAgent, ", head: #", remote.HeadHeight, " ", remote.HeadHash,
+		", features: ", remote.Features)
+
+	for _, handler := range hs.handlers {
+		handler(p, remote)
+	}
+	return nil
+}
+
+// reject disconnects peer and adds misbehaviour
+func (hs *Handshaker) reject(p peer.ID, reason error) {
+	logger.Info("handshake with peer ", p.Pretty(), " rejected: ", reason)
+	hs.peers.AddMisbehaviour(p, MisbehaviourBadHandshake, reason.Error())
+	if hs.host != nil {
+		hs.host.Network().ClosePeer(p)
+	}
+}
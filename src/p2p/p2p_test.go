@@ -0,0 +1,284 @@
+package p2p
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	errors "badcoin/src/helper/error"
+
+	crypto "github.com/libp2p/go-libp2p-core/crypto"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	multiaddr "github.com/multiformats/go-multiaddr"
+)
+
+func newPeerID(t *testing.T) peer.ID {
+	_, pub, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pid
+}
+
+func TestGater(t *testing.T) {
+	member := newPeerID(t)
+	outsider := newPeerID(t)
+
+	open, err := NewGater(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !open.InterceptPeerDial(outsider) {
+		t.Error("empty allowlist should admit all peers")
+	}
+
+	gater, err := NewGater([]string{member.Pretty()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gater.InterceptPeerDial(member) {
+		t.Error("allowlisted peer is rejected")
+	}
+	if gater.InterceptSecured(0, outsider, nil) {
+		t.Error("outsider is admitted")
+	}
+
+	if _, err := NewGater([]string{"invalid peer id"}); err == nil {
+		t.Error("invalid peer id should be rejected")
+	}
+}
+
+func TestPSK(t *testing.T) {
+	pskFile := "test_swarm.key"
+	defer os.Remove(pskFile)
+	if err := GeneratePSK(pskFile); err != nil {
+		t.Fatal(err)
+	}
+	psk, err := LoadPSK(pskFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(psk) != 32 {
+		t.Error("invalid psk length")
+	}
+	if err := GeneratePSK(pskFile); err == nil {
+		t.Error("existing key file should not be overwritten")
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	keyFile := "test_identity.key"
+	defer os.Remove(keyFile)
+	k1, err := LoadIdentity(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, err := LoadIdentity(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !k1.Equals(k2) {
+		t.Error("identity is not persistent")
+	}
+}
+
+func TestPeerManager(t *testing.T) {
+	peersFile := "test_peers.json"
+	defer os.Remove(peersFile)
+
+	p := newPeerID(t)
+	pm := NewPeerManager(peersFile, 100, time.Hour, 10)
+	pm.AddMisbehaviour(p, MisbehaviourInvalidTx, "test")
+	if pm.IsBanned(p) {
+		t.Error("peer is banned before reaching threshold")
+	}
+	pm.AddMisbehaviour(p, MisbehaviourBadHandshake, "test")
+	if pm.IsBanned(p) {
+		t.Error("peer is banned after a failed handshake")
+	}
+	pm.AddMisbehaviour(p, MisbehaviourMalformedMessage, "test")
+	pm.AddMisbehaviour(p, MisbehaviourMalformedMessage, "test")
+	if !pm.IsBanned(p) {
+		t.Error("peer is not banned after reaching threshold")
+	}
+
+	gater, _ := NewGater(nil)
+	gater.SetBanList(pm)
+	if gater.InterceptPeerDial(p) {
+		t.Error("gater admits banned peer")
+	}
+
+	// bans are persisted
+	reloaded := NewPeerManager(peersFile, 100, time.Hour, 10)
+	if !reloaded.IsBanned(p) {
+		t.Error("ban list is not persisted")
+	}
+
+	reloaded.Unban(p)
+	if reloaded.IsBanned(p) {
+		t.Error("peer is still banned after unban")
+	}
+
+	pm.Ban(p, -time.Second)
+	if pm.IsBanned(p) {
+		t.Error("expired ban should be removed")
+	}
+}
+
+func TestVersionFeatures(t *testing.T) {
+	v := Version{ProtocolVersion: ProtocolVersion, Features: []string{"blocks", "transactions"}}
+	if !v.HasFeature("blocks") {
+		t.Error("feature is not found")
+	}
+	if v.HasFeature("addr") {
+		t.Error("unsupported feature is found")
+	}
+}
+
+func TestHandshakeVerify(t *testing.T) {
+	pm := NewPeerManager("", 100, time.Hour, 10)
+	hs := NewHandshaker(nil, pm, nil)
+	local := &Version{ProtocolVersion: ProtocolVersion, NetworkID: "bdc", GenesisHash: "genesis"}
+
+	tests := []struct {
+		name   string
+		remote Version
+		err    error
+	}{
+		{"compatible", Version{ProtocolVersion: ProtocolVersion, NetworkID: "bdc", GenesisHash: "genesis"}, nil},
+		{"old version", Version{ProtocolVersion: MinProtocolVersion - 1, NetworkID: "bdc", GenesisHash: "genesis"}, errors.IncompatibleProtocolVersion},
+		{"other network", Version{ProtocolVersion: ProtocolVersion, NetworkID: "testnet", GenesisHash: "genesis"}, errors.NetworkMismatch},
+		{"other genesis", Version{ProtocolVersion: ProtocolVersion, NetworkID: "bdc", GenesisHash: "other"}, errors.GenesisMismatch},
+	}
+	for _, test := range tests {
+		p := newPeerID(t)
+		remote := test.remote
+		if err := hs.verify(p, local, &remote); err != test.err {
+			t.Errorf("%s: expected error %v, got %v", test.name, test.err, err)
+		}
+		if test.err == nil && pm.PeerVersion(p) == nil {
+			t.Errorf("%s: version of peer is not stored", test.name)
+		}
+		if test.err != nil && pm.PeerVersion(p) != nil {
+			t.Errorf("%s: version of rejected peer is stored", test.name)
+		}
+		if pm.IsBanned(p) {
+			t.Errorf("%s: peer is banned after one failed handshake", test.name)
+		}
+	}
+}
+
+func TestAddrBook(t *testing.T) {
+	addr, _ := multiaddr.NewMultiaddr("/ip4/127.0.0.1/tcp/4001")
+	book := NewAddrBook(2)
+	source := newPeerID(t)
+
+	fresh := newPeerID(t)
+	old := newPeerID(t)
+	book.Add(peer.AddrInfo{ID: old, Addrs: []multiaddr.Multiaddr{addr}}, time.Now().Add(-10*time.Hour), source)
+	book.Add(peer.AddrInfo{ID: fresh, Addrs: []multiaddr.Multiaddr{addr}}, time.Now().Add(-3*time.Hour), source)
+	if book.Size() != 2 {
+		t.Fatal("addresses are not added")
+	}
+	best := book.Best(1)
+	if len(best) != 1 || best[0].Info.ID != fresh {
+		t.Error("fresh address should have best score")
+	}
+
+	// book is full, newer address evicts the oldest one, last seen time of peer is penalized
+	newer := newPeerID(t)
+	book.Add(peer.AddrInfo{ID: newer, Addrs: []multiaddr.Multiaddr{addr}}, time.Now().Add(time.Hour), source)
+	if book.Size() != 2 {
+		t.Error("address book is not bounded")
+	}
+	for _, e := range book.Best(2) {
+		if e.Info.ID == old {
+			t.Error("oldest address is not evicted")
+		}
+		if e.Info.ID == newer && e.LastSeen.After(time.Now().Add(-addrTimePenalty)) {
+			t.Error("last seen time of learned address is not penalized")
+		}
+	}
+
+	// stale addresses are ignored
+	stale := newPeerID(t)
+	book.Add(peer.AddrInfo{ID: stale, Addrs: []multiaddr.Multiaddr{addr}}, time.Now().Add(-48*time.Hour), source)
+	for _, e := range book.Best(2) {
+		if e.Info.ID == stale {
+			t.Error("stale address is added")
+		}
+	}
+
+	// addresses of a peer are bounded, newer addresses are kept
+	var many []multiaddr.Multiaddr
+	for port := 5000; port < 5000+2*maxAddrsPerPeer; port++ {
+		a, _ := multiaddr.NewMultiaddr(fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port))
+		many = append(many, a)
+	}
+	book.Add(peer.AddrInfo{ID: fresh, Addrs: many}, time.Now(), source)
+	for _, e := range book.Best(2) {
+		if e.Info.ID == fresh && (len(e.Info.Addrs) != maxAddrsPerPeer || !e.Info.Addrs[0].Equal(many[0])) {
+			t.Error("addresses of peer are not bounded, count: ", len(e.Info.Addrs))
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		book.MarkFailed(fresh)
+	}
+	if book.Size() != 1 {
+		t.Error("address with dial failures is not removed")
+	}
+
+	// learned addresses don't evict good ones
+	good := newPeerID(t)
+	book.MarkGood(peer.AddrInfo{ID: good, Addrs: []multiaddr.Multiaddr{addr}})
+	for i := 0; i < 5; i++ {
+		book.Add(peer.AddrInfo{ID: newPeerID(t), Addrs: []multiaddr.Multiaddr{addr}}, time.Now(), newPeerID(t))
+	}
+	book.Add(peer.AddrInfo{ID: good, Addrs: many}, time.Now(), source)
+	if best := book.Best(1); len(best) != 1 || best[0].Info.ID != good || len(best[0].Info.Addrs) != 1 {
+		t.Error("good address should be kept and not changed by learned addresses")
+	}
+
+	// learned addresses of a source are bounded
+	book = NewAddrBook(2 * maxAddrsPerSource)
+	for i := 0; i < maxAddrsPerSource+10; i++ {
+		book.Add(peer.AddrInfo{ID: newPeerID(t), Addrs: []multiaddr.Multiaddr{addr}}, time.Now(), source)
+	}
+	if book.Size() != maxAddrsPerSource {
+		t.Error("addresses of a source are not bounded, count: ", book.Size())
+	}
+}
+
+func TestAddrPeer(t *testing.T) {
+	p := newPeerID(t)
+	ap := AddrPeer{ID: p.Pretty(), Addrs: []string{"/ip4/127.0.0.1/tcp/4001"}}
+	info, err := ap.AddrInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != p || len(info.Addrs) != 1 {
+		t.Error("invalid addr info")
+	}
+	if (&Addr{Peers: []AddrPeer{ap}}).Oversized() {
+		t.Error("addr message within limits is oversized")
+	}
+	if !(&Addr{Peers: make([]AddrPeer, maxAddrsPerMessage+1)}).Oversized() {
+		t.Error("addr message with too many peers is not oversized")
+	}
+	many := AddrPeer{ID: p.Pretty(), Addrs: make([]string, maxAddrsPerPeer+1)}
+	if !(&Addr{Peers: []AddrPeer{many}}).Oversized() {
+		t.Error("addr message with too many addresses of a peer is not oversized")
+	}
+
+	ap.Addrs = []string{"invalid"}
+	if _, err := ap.AddrInfo(); err == nil {
+		t.Error("invalid multiaddr is parsed")
+	}
+}
@@ -0,0 +1,337 @@
+package p2p
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	file "badcoin/src/helper/file"
+	logger "badcoin/src/helper/logger"
+
+	host "github.com/libp2p/go-libp2p-core/host"
+	network "github.com/libp2p/go-libp2p-core/network"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	multiaddr "github.com/multiformats/go-multiaddr"
+)
+
+// misbehaviour scores, peer is banned when its score reaches ban threshold.
+// a failed handshake may be a network problem, so it doesn't ban peer at once
+const (
+	MisbehaviourMalformedMessage = 50
+	MisbehaviourInvalidBlock     = 20
+	MisbehaviourInvalidTx        = 10
+	MisbehaviourBadHandshake     = 20
+)
+
+// PeerInfo is the state of a peer which is tracked by peer manager
+type PeerInfo struct {
+	ID          string
+	Addrs       []string
+	Score       int
+	Connected   bool
+	Banned      bool
+	BannedUntil int64
+	Version     *Version
+}
+
+// knownPeers is the content of peers file
+type knownPeers struct {
+	Peers []peer.AddrInfo
+	Bans  map[string]int64
+}
+
+// PeerManager tracks misbehaviour of peers, bans them and keeps known good peers
+type PeerManager struct {
+	mutex       *sync.RWMutex
+	host        host.Host
+	peersFile   string
+	threshold   int
+	banDuration time.Duration
+	maxKnown    int
+	scores      map[peer.ID]int
+	bans        map[peer.ID]time.Time
+	known       map[peer.ID]peer.AddrInfo
+	versions    map[peer.ID]*Version
+}
+
+// NewPeerManager creates a peer manager and loads known peers and bans from peers file
+func NewPeerManager(peersFile string, threshold int, banDuration time.Duration, maxKnown int) *PeerManager {
+	pm := &PeerManager{
+		mutex:       new(sync.RWMutex),
+		peersFile:   peersFile,
+		threshold:   threshold,
+		banDuration: banDuration,
+		maxKnown:    maxKnown,
+		scores:      make(map[peer.ID]int),
+		bans:        make(map[peer.ID]time.Time),
+		known:       make(map[peer.ID]peer.AddrInfo),
+		versions:    make(map[peer.ID]*Version),
+	}
+	if err := pm.load(); err != nil {
+		logger.Error("loading known peers failed: ", err)
+	}
+	return pm
+}
+
+// Start attaches peer manager to host and reconnects to known peers
+func (pm *PeerManager) Start(ctx context.Context, h host.Host) {
+	pm.mutex.Lock()
+	pm.host = h
+	known := make([]peer.AddrInfo, 0, len(pm.known))
+	for _, pi := range pm.known {
+		known = append(known, pi)
+	}
+	pm.mutex.Unlock()
+
+	h.Network().Notify(&network.NotifyBundle{
+		DisconnectedF: func(n network.Network, conn network.Conn) {
+			if n.Connectedness(conn.RemotePeer()) != network.Connected {
+				pm.mutex.Lock()
+				delete(pm.versions, conn.RemotePeer())
+				pm.mutex.Unlock()
+			}
+		},
+	})
+
+	for _, pi := range known {
+		go func(pi peer.AddrInfo) {
+			if err := h.Connect(ctx, pi); err != nil {
+				logger.Debug("reconnecting to known peer ", pi.ID.Pretty(), " failed: ", err)
+			}
+		}(pi)
+	}
+}
+
+// addKnownPeer stores listen addresses of a peer. Outbound connections are made to listen
+// addresses, inbound ones come from ephemeral ports, so addresses reported by identify are used for them
+func (pm *PeerManager) addKnownPeer(p peer.ID) {
+	pm.mutex.RLock()
+	h := pm.host
+	pm.mutex.RUnlock()
+	if h == nil {
+		return
+	}
+
+	var addrs, inbound []multiaddr.Multiaddr
+	for _, conn := range h.Network().ConnsToPeer(p) {
+		if conn.Stat().Direction == network.DirOutbound {
+			addrs = append(addrs, conn.RemoteMultiaddr())
+		} else {
+			inbound = append(inbound, conn.RemoteMultiaddr())
+		}
+	}
+	if len(addrs) == 0 {
+		for _, addr := range h.Peerstore().Addrs(p) {
+			if !containsAddr(inbound, addr) {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		return
+	}
+
+	pm.mutex.Lock()
+	if _, ok := pm.known[p]; !ok && len(pm.known) >= pm.maxKnown {
+		pm.mutex.Unlock()
+		return
+	}
+	pm.known[p] = peer.AddrInfo{ID: p, Addrs: addrs}
+	pm.mutex.Unlock()
+
+	if err := pm.save(); err != nil {
+		logger.Error("saving known peers failed: ", err)
+	}
+}
+
+// containsAddr checks whether addrs contains addr
+func containsAddr(addrs []multiaddr.Multiaddr, addr multiaddr.Multiaddr) bool {
+	for _, a := range addrs {
+		if a.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetVersion stores handshake message of a connected peer, peer is known after its handshake
+func (pm *PeerManager) SetVersion(p peer.ID, version *Version) {
+	pm.mutex.Lock()
+	pm.versions[p] = version
+	pm.mutex.Unlock()
+	pm.addKnownPeer(p)
+}
+
+// BanDuration returns configured ban time of misbehaving peers
+func (pm *PeerManager) BanDuration() time.Duration {
+	return pm.banDuration
+}
+
+// PeerVersion returns handshake message of a peer, nil if handshake is not done
+func (pm *PeerManager) PeerVersion(p peer.ID) *Version {
+	pm.mutex.RLock()
+	defer pm.mutex.RUnlock()
+	return pm.versions[p]
+}
+
+// AddMisbehaviour increases misbehaviour score of a peer and bans it when score reaches threshold
+func (pm *PeerManager) AddMisbehaviour(p peer.ID, score int, reason string) {
+	pm.mutex.Lock()
+	pm.scores[p] += score
+	total := pm.scores[p]
+	pm.mutex.Unlock()
+
+	logger.Info("peer ", p.Pretty(), " misbehaved (", reason, "), score: ", total)
+	if total >= pm.threshold {
+		pm.Ban(p, pm.banDuration)
+	}
+}
+
+// Ban bans peer for a duration and closes its connections
+func (pm *PeerManager) Ban(p peer.ID, duration time.Duration) {
+	pm.mutex.Lock()
+	pm.bans[p] = time.Now().Add(duration)
+	pm.scores[p] = 0
+	delete(pm.known, p)
+	h := pm.host
+	pm.mutex.Unlock()
+
+	logger.Info("peer ", p.Pretty(), " is banned for ", duration)
+	if h != nil {
+		h.Network().ClosePeer(p)
+	}
+	if err := pm.save(); err != nil {
+		logger.Error("saving ban list failed: ", err)
+	}
+}
+
+// Unban removes peer from ban list
+func (pm *PeerManager) Unban(p peer.ID) {
+	pm.mutex.Lock()
+	delete(pm.bans, p)
+	pm.scores[p] = 0
+	pm.mutex.Unlock()
+
+	logger.Info("peer ", p.Pretty(), " is unbanned")
+	if err := pm.save(); err != nil {
+		logger.Error("saving ban list failed: ", err)
+	}
+}
+
+// IsBanned checks whether peer is banned, expired bans are removed
+func (pm *PeerManager) IsBanned(p peer.ID) bool {
+	pm.mutex.RLock()
+	until, ok := pm.bans[p]
+	pm.mutex.RUnlock()
+	if !ok {
+		return false
+	}
+	if time.Now().Before(until) {
+		return true
+	}
+	pm.mutex.Lock()
+	delete(pm.bans, p)
+	pm.mutex.Unlock()
+	return false
+}
+
+// Peers returns connected, known and banned peers
+func (pm *PeerManager) Peers() []PeerInfo {
+	infos := make(map[peer.ID]*PeerInfo)
+	get := func(p peer.ID) *PeerInfo {
+		if infos[p] == nil {
+			infos[p] = &PeerInfo{ID: p.Pretty()}
+		}
+		return infos[p]
+	}
+
+	pm.mutex.RLock()
+	for p, pi := range pm.known {
+		info := get(p)
+		for _, addr := range pi.Addrs {
+			info.Addrs = append(info.Addrs, addr.String())
+		}
+	}
+	for p, until := range pm.bans {
+		info := get(p)
+		info.Banned = time.Now().Before(until)
+		info.BannedUntil = until.Unix()
+	}
+	for p, score := range pm.scores {
+		get(p).Score = score
+	}
+	for p, version := range pm.versions {
+		get(p).Version = version
+	}
+	h := pm.host
+	pm.mutex.RUnlock()
+
+	if h != nil {
+		for _, conn := range h.Network().Conns() {
+			info := get(conn.RemotePeer())
+			info.Connected = true
+			if len(info.Addrs) == 0 {
+				info.Addrs = append(info.Addrs, conn.RemoteMultiaddr().String())
+			}
+		}
+	}
+
+	res := make([]PeerInfo, 0, len(infos))
+	for _, info := range infos {
+		res = append(res, *info)
+	}
+	return res
+}
+
+// load reads known peers and bans from peers file
+func (pm *PeerManager) load() error {
+	if pm.peersFile == "" || !file.IsExist(pm.peersFile) {
+		return nil
+	}
+	data, err := ioutil.ReadFile(pm.peersFile)
+	if err != nil {
+		return err
+	}
+	var kp knownPeers
+	if err := json.Unmarshal(data, &kp); err != nil {
+		return err
+	}
+
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+	for _, pi := range kp.Peers {
+		pm.known[pi.ID] = pi
+	}
+	for id, until := range kp.Bans {
+		p, err := peer.Decode(id)
+		if err != nil {
+			continue
+		}
+		pm.bans[p] = time.Unix(until, 0)
+	}
+	return nil
+}
+
+// save writes known peers and bans into peers file
+func (pm *PeerManager) save() error {
+	if pm.peersFile == "" {
+		return nil
+	}
+	kp := knownPeers{Bans: make(map[string]int64)}
+	pm.mutex.RLock()
+	for _, pi := range pm.known {
+		kp.Peers = append(kp.Peers, pi)
+	}
+	for p, until := range pm.bans {
+		kp.Bans[p.Pretty()] = until.Unix()
+	}
+	pm.mutex.RUnlock()
+
+	data, err := json.Marshal(kp)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(pm.peersFile, data, 0644)
+}
@@ -0,0 +1,71 @@
+package p2p
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	file "badcoin/src/helper/file"
+
+	crypto "github.com/libp2p/go-libp2p-core/crypto"
+	pnet "github.com/libp2p/go-libp2p-core/pnet"
+)
+
+// LoadPSK loads libp2p pre-shared key (pnet v1 format) of private network
+func LoadPSK(pskFile string) (pnet.PSK, error) {
+	f, err := os.Open(pskFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return pnet.DecodeV1PSK(f)
+}
+
+// GeneratePSK writes a new random pre-shared key into file,
+// all nodes of private network should have same key file. Existing files are not overwritten
+func GeneratePSK(pskFile string) error {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	content := "/key/swarm/psk/1.0.0/\n/base16/\n" + hex.EncodeToString(key) + "\n"
+	f, err := os.OpenFile(pskFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadIdentity loads node private key from file, if file doesn't exist a new key is generated.
+// Persistent identity keeps peer ID same after restart, so it can be used in allowlists
+func LoadIdentity(keyFile string) (crypto.PrivKey, error) {
+	if file.IsExist(keyFile) {
+		data, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.UnmarshalPrivateKey(data)
+	}
+
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	data, err := crypto.MarshalPrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(keyFile), 0700); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(keyFile, data, 0600); err != nil {
+		return nil, err
+	}
+	return priv, nil
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+
+	config "badcoin/src/config"
+	logger "badcoin/src/helper/logger"
+)
+
+// CodeUnauthorized is the error code of requests which are rejected by authentication
+const CodeUnauthorized = "unauthorized"
+
+// RPCUnauthorized is the JSON-RPC error code of sensitive methods called without credentials
+const RPCUnauthorized = -32001
+
+// rpcSensitive are JSON-RPC methods which require authentication
+var rpcSensitive = map[string]bool{
+	"tx_send":             true,
+	"wallet_encrypt":      true,
+	"wallet_unlock":       true,
+	"wallet_lock":         true,
+	"wallet_getMnemonic":  true,
+	"wallet_setMnemonic":  true,
+	"wallet_rescan":       true,
+	"wallet_history":      true,
+	"wallet_exportKey":    true,
+	"wallet_importKey":    true,
+	"wallet_addWatchOnly": true,
+	"net_peers":           true,
+}
+
+// hasCredentials reports if a token or a basic auth user is configured
+func hasCredentials(auth config.RpcAuth) bool {
+	return auth.Token != "" || auth.Username != ""
+}
+
+// fromBrowser reports if a request is sent by a web page. Browsers set Origin of cross-site posts and
+// Sec-Fetch-Site of all requests, so a page can't use loopback address or saved credentials of user
+func fromBrowser(r *http.Request) bool {
+	if r.Header.Get("Origin") != "" {
+		return true
+	}
+	site := r.Header.Get("Sec-Fetch-Site")
+	return site != "" && site != "none"
+}
+
+// authorized checks bearer token or basic auth credentials of a request,
+// without configured credentials only loopback clients are authorized. Browser requests are never authorized
+func (srv *Server) authorized(r *http.Request) bool {
+	if fromBrowser(r) {
+		return false
+	}
+	if !hasCredentials(srv.Auth) {
+		return isLoopback(r.RemoteAddr)
+	}
+	if srv.Auth.Token != "" {
+		header := r.Header.Get("Authorization")
+		if strings.HasPrefix(header, "Bearer ") && secureCompare(strings.TrimPrefix(header, "Bearer "), srv.Auth.Token) {
+			return true
+		}
+	}
+	if srv.Auth.Username != "" {
+		user, password, ok := r.BasicAuth()
+		// both are compared, so response time doesn't tell which one is wrong
+		userOk := secureCompare(user, srv.Auth.Username)
+		passwordOk := secureCompare(password, srv.Auth.Password)
+		if ok && userOk && passwordOk {
+			return true
+		}
+	}
+	return false
+}
+
+// requireAuth rejects requests of a sensitive endpoint which are not authorized
+func (srv *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !srv.authorized(r) {
+			logger.Warn("unauthorized call of ", r.URL.Path, " from ", r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Basic realm="badcoin"`)
+			writeError(w, http.StatusUnauthorized, CodeUnauthorized, "authentication required")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func secureCompare(a string, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"encoding/json"
+	goerrors "errors"
+	"net/http"
+
+	errors "badcoin/src/helper/error"
+	logger "badcoin/src/helper/logger"
+	node "badcoin/src/node"
+	wallet "badcoin/src/wallet"
+)
+
+// error codes of http responses which are not transaction rejections
+const (
+	CodeBadRequest  = "bad_request"
+	CodeNotFound    = "not_found"
+	CodeUnavailable = "unavailable"
+	CodeInternal    = "internal_error"
+	CodeForbidden   = "forbidden"
+	CodeConflict    = "conflict"
+)
+
+// writeError writes an error response with http status
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(&node.ErrorResponse{Code: code, Message: message}); err != nil {
+		logger.Error("writing error response failed: ", err)
+	}
+}
+
+// writeTxError writes rejected transaction error, its reason code is returned to client
+func writeTxError(w http.ResponseWriter, err error) {
+	var txErr *errors.TxError
+	if !goerrors.As(err, &txErr) {
+		writeError(w, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+	writeError(w, txErrorStatus(txErr.Reason), txErr.Reason, txErr.Err.Error())
+}
+
+// writeNodeError writes error of a node query with its http status
+func writeNodeError(w http.ResponseWriter, err error) {
+	switch err {
+	case errors.InvalidAddress, errors.InvalidHash, errors.InvalidCid, errors.InvalidHeight, errors.BlocksRangeTooBig,
+		errors.InvalidHistoryCursor:
+		writeError(w, http.StatusBadRequest, CodeBadRequest, err.Error())
+	case errors.NotFoundTransaction, errors.BlockNotFount, wallet.ErrorUnknownAddress:
+		writeError(w, http.StatusNotFound, CodeNotFound, err.Error())
+	case errors.AddressIndexDisabled, errors.AddressIndexBuilding:
+		writeError(w, http.StatusServiceUnavailable, CodeUnavailable, err.Error())
+	case wallet.ErrorEmptyPassphrase, wallet.ErrorInvalidTimeout, wallet.ErrorInvalidMnemonic,
+		wallet.ErrorInvalidKeyEncoding, wallet.ErrorInvalidPrivateKey, wallet.ErrorInvalidAddress:
+		writeError(w, http.StatusBadRequest, CodeBadRequest, err.Error())
+	case wallet.ErrorWalletLocked:
+		writeError(w, http.StatusForbidden, errors.ReasonWalletLocked, err.Error())
+	case wallet.ErrorWrongPassphrase:
+		writeError(w, http.StatusForbidden, CodeForbidden, err.Error())
+	case wallet.ErrorNotEncrypted, wallet.ErrorAlreadyEncrypted, wallet.ErrorNoMnemonic, errors.RescanInProgress,
+		wallet.ErrorAddressExists, wallet.ErrorWatchOnly:
+		writeError(w, http.StatusConflict, CodeConflict, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, CodeInternal, err.Error())
+	}
+}
+
+// txErrorStatus maps reason code of rejected transaction to http status
+func txErrorStatus(reason string) int {
+	switch reason {
+	case errors.ReasonInsufficientBalance, errors.ReasonInvalidNonce, errors.ReasonNotValidYet, errors.ReasonExpired:
+		return http.StatusUnprocessableEntity
+	case errors.ReasonAlreadyPending:
+		return http.StatusConflict
+	case errors.ReasonWalletLocked:
+		return http.StatusForbidden
+	case errors.ReasonInternal:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// writeJSON writes a successful response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Error("writing response failed: ", err)
+	}
+}
+
+// recoverMiddleware turns panics of handlers into internal error responses, so server keeps running
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				logger.Error("handler of ", r.URL.Path, " panicked: ", rec)
+				writeError(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,589 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	goerrors "errors"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	errors "badcoin/src/helper/error"
+	logger "badcoin/src/helper/logger"
+	node "badcoin/src/node"
+	wallet "badcoin/src/wallet"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	RPCParseError     = -32700
+	RPCInvalidRequest = -32600
+	RPCMethodNotFound = -32601
+	RPCInvalidParams  = -32602
+	RPCInternalError  = -32603
+	RPCServerError    = -32000
+)
+
+const maxRPCBodySize = 1 << 20
+
+// RPCRequest is a JSON-RPC 2.0 request, request without id is a notification
+type RPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// RPCResponse is a JSON-RPC 2.0 response
+type RPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// RPCError is the error object of JSON-RPC 2.0 response
+type RPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// MarshalJSON writes result on success and error on failure, result may be zero or null
+func (r *RPCResponse) MarshalJSON() ([]byte, error) {
+	if r.Error != nil {
+		return json.Marshal(struct {
+			JSONRPC string          `json:"jsonrpc"`
+			Error   *RPCError       `json:"error"`
+			ID      json.RawMessage `json:"id"`
+		}{r.JSONRPC, r.Error, r.ID})
+	}
+	return json.Marshal(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		Result  interface{}     `json:"result"`
+		ID      json.RawMessage `json:"id"`
+	}{r.JSONRPC, r.Result, r.ID})
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+func newRPCError(code int, message string) *RPCError {
+	return &RPCError{Code: code, Message: message}
+}
+
+// nodeRPCError converts error of a node query to rpc error
+func nodeRPCError(err error) *RPCError {
+	switch err {
+	case errors.InvalidAddress, errors.InvalidHash, errors.InvalidCid, errors.InvalidHeight, errors.BlocksRangeTooBig,
+		errors.InvalidHistoryCursor, wallet.ErrorEmptyPassphrase, wallet.ErrorInvalidTimeout, wallet.ErrorInvalidMnemonic, wallet.ErrorUnknownAddress,
+		wallet.ErrorInvalidKeyEncoding, wallet.ErrorInvalidPrivateKey, wallet.ErrorInvalidAddress:
+		return newRPCError(RPCInvalidParams, err.Error())
+	default:
+		return newRPCError(RPCServerError, err.Error())
+	}
+}
+
+// txRPCError converts rejected transaction error to server error, reason code is sent in data
+func txRPCError(err error) *RPCError {
+	rpcErr := newRPCError(RPCServerError, err.Error())
+	var txErr *errors.TxError
+	if goerrors.As(err, &txErr) {
+		rpcErr.Message = txErr.Err.Error()
+		rpcErr.Data = txErr.Reason
+	}
+	return rpcErr
+}
+
+type rpcMethod func(srv *Server, params json.RawMessage) (interface{}, *RPCError)
+
+// rpcMethods maps JSON-RPC method names to handlers
+var rpcMethods map[string]rpcMethod
+
+func init() {
+	rpcMethods = map[string]rpcMethod{
+		"chain_getBlockByHeight": rpcGetBlockByHeight,
+		"chain_getBlockByHash":   rpcGetBlockByHash,
+		"chain_getBlockByCid":    rpcGetBlockByCid,
+		"chain_getBlocks":        rpcGetBlocks,
+		"chain_getHead":          rpcGetHead,
+		"chain_getInfo":          rpcGetInfo,
+		"tx_send":                rpcSendTx,
+		"tx_sendRaw":             rpcSendRawTx,
+		"tx_get":                 rpcGetTx,
+		"account_getBalance":     rpcGetBalance,
+		"account_getNonce":       rpcGetNonce,
+		"account_get":            rpcGetAccount,
+		"account_getHistory":     rpcGetHistory,
+		"account_getPending":     rpcGetPending,
+		"mempool_list":           rpcMempoolList,
+		"net_peers":              rpcPeers,
+		"wallet_status":          rpcWalletStatus,
+		"wallet_encrypt":         rpcEncryptWallet,
+		"wallet_unlock":          rpcUnlockWallet,
+		"wallet_lock":            rpcLockWallet,
+		"wallet_getMnemonic":     rpcGetMnemonic,
+		"wallet_setMnemonic":     rpcSetMnemonic,
+		"wallet_rescan":          rpcRescanWallet,
+		"wallet_rescanStatus":    rpcRescanStatus,
+		"wallet_history":         rpcWalletHistory,
+		"wallet_exportKey":       rpcExportKey,
+		"wallet_importKey":       rpcImportKey,
+		"wallet_addWatchOnly":    rpcAddWatchOnly,
+	}
+}
+
+// HandleJSONRPC serves single and batch JSON-RPC 2.0 requests, body must be sent as application/json
+// so browsers can't post it from other sites without a preflight
+func (srv *Server) HandleJSONRPC(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		writeRPC(w, &RPCResponse{JSONRPC: "2.0", Error: newRPCError(RPCInvalidRequest, "content type must be application/json"), ID: nullID})
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRPCBodySize))
+	if err != nil {
+		writeRPC(w, &RPCResponse{JSONRPC: "2.0", Error: newRPCError(RPCParseError, "parse error"), ID: nullID})
+		return
+	}
+	body = bytes.TrimSpace(body)
+	authorized := srv.authorized(r)
+
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			writeRPC(w, &RPCResponse{JSONRPC: "2.0", Error: newRPCError(RPCParseError, "parse error"), ID: nullID})
+			return
+		}
+		if len(batch) == 0 {
+			writeRPC(w, &RPCResponse{JSONRPC: "2.0", Error: newRPCError(RPCInvalidRequest, "invalid request"), ID: nullID})
+			return
+		}
+		responses := make([]*RPCResponse, 0, len(batch))
+		for _, raw := range batch {
+			if resp := srv.handleRPCMessage(raw, authorized); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeRPC(w, responses)
+		return
+	}
+
+	resp := srv.handleRPCMessage(body, authorized)
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeRPC(w, resp)
+}
+
+var nullID = json.RawMessage("null")
+
+// handleRPCMessage runs one request, it returns nil for notifications,
+// sensitive methods are rejected if client is not authorized
+func (srv *Server) handleRPCMessage(raw json.RawMessage, authorized bool) (resp *RPCResponse) {
+	var req RPCRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		if _, ok := err.(*json.SyntaxError); ok {
+			return &RPCResponse{JSONRPC: "2.0", Error: newRPCError(RPCParseError, "parse error"), ID: nullID}
+		}
+		return &RPCResponse{JSONRPC: "2.0", Error: newRPCError(RPCInvalidRequest, "invalid request"), ID: nullID}
+	}
+	notification := len(req.ID) == 0
+	id := req.ID
+	if notification {
+		id = nullID
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return &RPCResponse{JSONRPC: "2.0", Error: newRPCError(RPCInvalidRequest, "invalid request"), ID: id}
+	}
+
+	method, ok := rpcMethods[req.Method]
+	if !ok {
+		if notification {
+			return nil
+		}
+		return &RPCResponse{JSONRPC: "2.0", Error: newRPCError(RPCMethodNotFound, "method not found"), ID: id}
+	}
+	if rpcSensitive[req.Method] && !authorized {
+		logger.Warn("unauthorized rpc call of ", req.Method)
+		if notification {
+			return nil
+		}
+		return &RPCResponse{JSONRPC: "2.0", Error: newRPCError(RPCUnauthorized, "authentication required"), ID: id}
+	}
+
+	logger.Info("Call rpc ", req.Method)
+	defer func() {
+		if rec := recover(); rec != nil {
+			logger.Error("rpc method ", req.Method, " failed: ", rec)
+			resp = nil
+			if !notification {
+				resp = &RPCResponse{JSONRPC: "2.0", Error: newRPCError(RPCInternalError, "internal error"), ID: id}
+			}
+		}
+	}()
+	result, rpcErr := method(srv, req.Params)
+	if notification {
+		return nil
+	}
+	if rpcErr != nil {
+		return &RPCResponse{JSONRPC: "2.0", Error: rpcErr, ID: id}
+	}
+	return &RPCResponse{JSONRPC: "2.0", Result: result, ID: id}
+}
+
+func writeRPC(w http.ResponseWriter, v interface{}) {
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Error("writing rpc response failed: ", err)
+	}
+}
+
+// decodeParams decodes positional (array) or named (object) params into out by names order,
+// first required params are mandatory and the rest are optional
+func decodeParams(params json.RawMessage, names []string, required int, out ...interface{}) *RPCError {
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 || bytes.Equal(params, []byte("null")) {
+		if required == 0 {
+			return nil
+		}
+		return newRPCError(RPCInvalidParams, "missing params")
+	}
+
+	values := make([]json.RawMessage, len(names))
+	if params[0] == '[' {
+		var list []json.RawMessage
+		if err := json.Unmarshal(params, &list); err != nil {
+			return newRPCError(RPCInvalidParams, "invalid params")
+		}
+		if len(list) > len(names) {
+			return newRPCError(RPCInvalidParams, "too many params")
+		}
+		copy(values, list)
+	} else {
+		var named map[string]json.RawMessage
+		if err := json.Unmarshal(params, &named); err != nil {
+			return newRPCError(RPCInvalidParams, "invalid params")
+		}
+		for i, name := range names {
+			values[i] = named[name]
+		}
+	}
+
+	for i, v := range values {
+		if len(v) == 0 {
+			if i >= required {
+				continue
+			}
+			return newRPCError(RPCInvalidParams, "missing param "+names[i])
+		}
+		if err := json.Unmarshal(v, out[i]); err != nil {
+			return newRPCError(RPCInvalidParams, "invalid param "+names[i])
+		}
+	}
+	return nil
+}
+
+func rpcGetBlockByHeight(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	var height uint64
+	var header bool
+	if err := decodeParams(params, []string{"height", "header"}, 1, &height, &header); err != nil {
+		return nil, err
+	}
+	blk, err := srv.Node.GetBlock(height)
+	if err != nil {
+		return nil, nodeRPCError(err)
+	}
+	if blk == nil {
+		return nil, newRPCError(RPCServerError, "block not found")
+	}
+	return blockResult(blk, header), nil
+}
+
+func rpcGetBlockByHash(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	var blkhash string
+	var header bool
+	if err := decodeParams(params, []string{"hash", "header"}, 1, &blkhash, &header); err != nil {
+		return nil, err
+	}
+	blk, err := srv.Node.GetBlockByHash(blkhash)
+	if err != nil {
+		return nil, nodeRPCError(err)
+	}
+	return blockResult(blk, header), nil
+}
+
+func rpcGetBlockByCid(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	var blkcid string
+	var header bool
+	if err := decodeParams(params, []string{"cid", "header"}, 1, &blkcid, &header); err != nil {
+		return nil, err
+	}
+	blk, err := srv.Node.GetBlockByCid(blkcid)
+	if err != nil {
+		return nil, nodeRPCError(err)
+	}
+	return blockResult(blk, header), nil
+}
+
+func rpcGetBlocks(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	var from, to uint64
+	var header bool
+	if err := decodeParams(params, []string{"from", "to", "header"}, 2, &from, &to, &header); err != nil {
+		return nil, err
+	}
+	blocks, err := srv.Node.GetBlocks(from, to)
+	if err != nil {
+		return nil, nodeRPCError(err)
+	}
+	return blocksResult(blocks, header), nil
+}
+
+func rpcGetHead(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	var header bool
+	if err := decodeParams(params, []string{"header"}, 0, &header); err != nil {
+		return nil, err
+	}
+	return blockResult(srv.Node.GetHead(), header), nil
+}
+
+func rpcGetInfo(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	return srv.Node.GetInfo(), nil
+}
+
+func rpcSendRawTx(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	var raw string
+	if err := decodeParams(params, []string{"tx"}, 1, &raw); err != nil {
+		return nil, err
+	}
+	resp, err := srv.Node.SendRawTransaction(raw)
+	if err != nil {
+		return nil, txRPCError(err)
+	}
+	return resp, nil
+}
+
+func rpcSendTx(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	var to string
+	var value float64
+	var data string
+	var from string
+	var expiresIn uint64
+	if err := decodeParams(params, []string{"to", "value", "data", "from", "expiresIn"}, 2, &to, &value, &data, &from, &expiresIn); err != nil {
+		return nil, err
+	}
+	if value <= 0 {
+		return nil, newRPCError(RPCInvalidParams, "invalid tx value")
+	}
+	resp, err := srv.Node.SendFromWallet(from, to, value, data, expiresIn)
+	if err != nil {
+		return nil, txRPCError(err)
+	}
+	return resp, nil
+}
+
+func rpcGetTx(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	var txid string
+	if err := decodeParams(params, []string{"txid"}, 1, &txid); err != nil {
+		return nil, err
+	}
+	resp, err := srv.Node.GetTransaction(txid)
+	if err != nil {
+		return nil, nodeRPCError(err)
+	}
+	return resp, nil
+}
+
+func rpcGetBalance(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	acc, err := fetchAccount(srv, params)
+	if err != nil {
+		return nil, err
+	}
+	return acc.Balance, nil
+}
+
+func rpcGetNonce(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	acc, err := fetchAccount(srv, params)
+	if err != nil {
+		return nil, err
+	}
+	return acc.Nonce, nil
+}
+
+func fetchAccount(srv *Server, params json.RawMessage) (*node.AccountResponse, *RPCError) {
+	var addr string
+	if err := decodeParams(params, []string{"address"}, 1, &addr); err != nil {
+		return nil, err
+	}
+	acc, err := srv.Node.GetAccount(addr)
+	if err != nil {
+		return nil, nodeRPCError(err)
+	}
+	return acc, nil
+}
+
+func rpcGetAccount(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	return fetchAccount(srv, params)
+}
+
+func rpcGetHistory(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	var addr, cursor string
+	var limit int
+	if err := decodeParams(params, []string{"address", "cursor", "limit"}, 1, &addr, &cursor, &limit); err != nil {
+		return nil, err
+	}
+	resp, err := srv.Node.GetAddressHistory(addr, cursor, limit)
+	if err != nil {
+		return nil, nodeRPCError(err)
+	}
+	return resp, nil
+}
+
+func rpcGetPending(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	var addr string
+	if err := decodeParams(params, []string{"address"}, 1, &addr); err != nil {
+		return nil, err
+	}
+	resp, err := srv.Node.GetPendingTransactions(addr)
+	if err != nil {
+		return nil, nodeRPCError(err)
+	}
+	return resp, nil
+}
+
+func rpcMempoolList(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	return srv.Node.GetMempoolTransactions(), nil
+}
+
+func rpcPeers(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	return srv.Node.GetPeers(), nil
+}
+
+func rpcWalletStatus(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	return srv.Node.GetWalletStatus(), nil
+}
+
+func rpcEncryptWallet(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	var passphrase string
+	if err := decodeParams(params, []string{"passphrase"}, 1, &passphrase); err != nil {
+		return nil, err
+	}
+	resp, err := srv.Node.EncryptWallet(passphrase)
+	if err != nil {
+		return nil, nodeRPCError(err)
+	}
+	return resp, nil
+}
+
+func rpcUnlockWallet(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	var passphrase string
+	var timeout uint64
+	if err := decodeParams(params, []string{"passphrase", "timeout"}, 2, &passphrase, &timeout); err != nil {
+		return nil, err
+	}
+	resp, err := srv.Node.UnlockWallet(passphrase, timeout)
+	if err != nil {
+		return nil, nodeRPCError(err)
+	}
+	return resp, nil
+}
+
+func rpcLockWallet(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	resp, err := srv.Node.LockWallet()
+	if err != nil {
+		return nil, nodeRPCError(err)
+	}
+	return resp, nil
+}
+
+func rpcGetMnemonic(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	resp, err := srv.Node.GetMnemonic()
+	if err != nil {
+		return nil, nodeRPCError(err)
+	}
+	return resp, nil
+}
+
+func rpcSetMnemonic(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	var mnemonic string
+	var count uint32
+	if err := decodeParams(params, []string{"mnemonic", "count"}, 0, &mnemonic, &count); err != nil {
+		return nil, err
+	}
+	resp, err := srv.Node.SetMnemonic(mnemonic, count)
+	if err != nil {
+		return nil, nodeRPCError(err)
+	}
+	return resp, nil
+}
+
+func rpcRescanWallet(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	var from uint64
+	if err := decodeParams(params, []string{"from"}, 0, &from); err != nil {
+		return nil, err
+	}
+	resp, err := srv.Node.RescanWallet(from)
+	if err != nil {
+		return nil, nodeRPCError(err)
+	}
+	return resp, nil
+}
+
+func rpcRescanStatus(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	return srv.Node.GetRescanStatus(), nil
+}
+
+func rpcWalletHistory(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	var addr string
+	if err := decodeParams(params, []string{"address"}, 0, &addr); err != nil {
+		return nil, err
+	}
+	resp, err := srv.Node.GetWalletHistory(addr)
+	if err != nil {
+		return nil, nodeRPCError(err)
+	}
+	return resp, nil
+}
+
+func rpcExportKey(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	var addr string
+	if err := decodeParams(params, []string{"address"}, 1, &addr); err != nil {
+		return nil, err
+	}
+	resp, err := srv.Node.ExportKey(addr)
+	if err != nil {
+		return nil, nodeRPCError(err)
+	}
+	return resp, nil
+}
+
+func rpcImportKey(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	var key string
+	if err := decodeParams(params, []string{"key"}, 1, &key); err != nil {
+		return nil, err
+	}
+	resp, err := srv.Node.ImportKey(key)
+	if err != nil {
+		return nil, nodeRPCError(err)
+	}
+	return resp, nil
+}
+
+func rpcAddWatchOnly(srv *Server, params json.RawMessage) (interface{}, *RPCError) {
+	var addr string
+	if err := decodeParams(params, []string{"address"}, 1, &addr); err != nil {
+		return nil, err
+	}
+	resp, err := srv.Node.AddWatchOnly(addr)
+	if err != nil {
+		return nil, nodeRPCError(err)
+	}
+	return resp, nil
+}
@@ -1,13 +1,17 @@
 package server
 
 import (
+	block "badcoin/src/block"
+	config "badcoin/src/config"
+	event "badcoin/src/event"
+	address "badcoin/src/helper/address"
+	errors "badcoin/src/helper/error"
 	logger "badcoin/src/helper/logger"
 	node "badcoin/src/node"
 	"context"
-	"encoding/json"
 	"math/big"
+	"net"
 	b64 "encoding/base64"
-	//"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -22,6 +26,8 @@ type Server struct {
 	Port    string
 	Addr    string
 	Node    *node.Node
+	Events  *event.Bus
+	Auth    config.RpcAuth
 	Miner   bool
 	Handler *http.Handler
 }
@@ -39,30 +45,68 @@ func MakeMuxRouter(server *Server) http.Handler {
 	muxRouter.HandleFunc("/", server.HandleHealthCheck).Methods("GET")
 	muxRouter.HandleFunc("/info", server.HandleGetInfo).Methods("GET")
 	muxRouter.HandleFunc("/block", server.HandleGetBlock).Methods("GET")
+	muxRouter.HandleFunc("/blocks", server.HandleGetBlocks).Methods("GET")
+	muxRouter.HandleFunc("/head", server.HandleGetHead).Methods("GET")
 	muxRouter.HandleFunc("/genesis", server.HandleGetGenesis).Methods("GET")
+	muxRouter.HandleFunc("/tx/{id}", server.HandleGetTx).Methods("GET")
+	muxRouter.HandleFunc("/address/{address}", server.HandleGetAccount).Methods("GET")
+	muxRouter.HandleFunc("/address/{address}/history", server.HandleGetAddressHistory).Methods("GET")
+	muxRouter.HandleFunc("/address/{address}/pending", server.HandleGetPendingTxs).Methods("GET")
 
 	//Setup Post Endpoints
-	muxRouter.HandleFunc("/tx/send", server.HandleSendTx).Methods("POST")
+	muxRouter.HandleFunc("/tx/send", server.requireAuth(server.HandleSendTx)).Methods("POST")
 	muxRouter.HandleFunc("/tx/signed/send", server.HandleSendSignedTx).Methods("POST")
-	muxRouter.HandleFunc("/address/new", server.HandleNewAddress).Methods("POST")
+	muxRouter.HandleFunc("/tx/raw", server.HandleSendRawTx).Methods("POST")
+	muxRouter.HandleFunc("/address/new", server.requireAuth(server.HandleNewAddress)).Methods("POST")
+
+	//Setup JSON-RPC 2.0 Endpoint
+	muxRouter.HandleFunc("/rpc", server.HandleJSONRPC).Methods("POST")
+	muxRouter.HandleFunc("/ws", server.HandleWebSocket).Methods("GET")
+
+	//Setup Wallet Endpoints
+	muxRouter.HandleFunc("/wallet/status", server.HandleWalletStatus).Methods("GET")
+	muxRouter.HandleFunc("/wallet/encrypt", server.requireAuth(server.HandleEncryptWallet)).Methods("POST")
+	muxRouter.HandleFunc("/wallet/unlock", server.requireAuth(server.HandleUnlockWallet)).Methods("POST")
+	muxRouter.HandleFunc("/wallet/lock", server.requireAuth(server.HandleLockWallet)).Methods("POST")
+	muxRouter.HandleFunc("/wallet/mnemonic/export", server.requireAuth(server.HandleExportMnemonic)).Methods("POST")
+	muxRouter.HandleFunc("/wallet/mnemonic/import", server.requireAuth(server.HandleImportMnemonic)).Methods("POST")
+	muxRouter.HandleFunc("/wallet/rescan", server.requireAuth(server.HandleRescanWallet)).Methods("POST")
+	muxRouter.HandleFunc("/wallet/rescan", server.HandleRescanStatus).Methods("GET")
+	muxRouter.HandleFunc("/wallet/history", server.requireAuth(server.HandleWalletHistory)).Methods("GET")
+	muxRouter.HandleFunc("/wallet/key/export", server.requireAuth(server.HandleExportKey)).Methods("POST")
+	muxRouter.HandleFunc("/wallet/key/import", server.requireAuth(server.HandleImportKey)).Methods("POST")
+	muxRouter.HandleFunc("/wallet/watch", server.requireAuth(server.HandleAddWatchOnly)).Methods("POST")
+
+	//Setup Admin Endpoints
+	muxRouter.HandleFunc("/admin/peers", server.requireAuth(server.HandleGetPeers)).Methods("GET")
+	muxRouter.HandleFunc("/admin/peers/ban", server.requireAuth(server.HandleBanPeer)).Methods("POST")
+	muxRouter.HandleFunc("/admin/peers/unban", server.requireAuth(server.HandleUnbanPeer)).Methods("POST")
+
+	muxRouter.Use(recoverMiddleware)
 
 	return muxRouter
 }
 
-func CreateNewServer(ctx context.Context, servernode *node.Node, port string) *http.Server {
+func CreateNewServer(ctx context.Context, servernode *node.Node, rpcset config.RpcSet) *http.Server {
 	var server Server
-	server.Port = port
-	server.Host = ""
-	server.Addr = server.Host + ":" + server.Port
+	server.Port = rpcset.Port
+	server.Host = rpcset.Host
+	server.Addr = net.JoinHostPort(server.Host, server.Port)
 	server.Node = servernode
+	server.Events = servernode.Events()
+	server.Auth = rpcset.Auth
+
+	if !hasCredentials(rpcset.Auth) {
+		logger.Warn("rpc credentials are not set, sensitive endpoints are only allowed from loopback")
+	}
 
 	logger.Info("Starting http server")
 	mux := MakeMuxRouter(&server)
-	logger.Info("Listening on ", port)
+	logger.Info("Listening on ", server.Addr)
 	server.Handler = &mux
 
 	hs := &http.Server{
-		Addr:           ":" + port,
+		Addr:           server.Addr,
 		Handler:        mux,
 		ReadTimeout:    10 * time.Second,
 		WriteTimeout:   10 * time.Second,
@@ -72,137 +116,435 @@ func CreateNewServer(ctx context.Context, servernode *node.Node, port string) *h
 
 }
 
+// ListenAndServe serves rpc over https if tls is enabled, cert and key paths are relative to config directory
+func ListenAndServe(hs *http.Server, configs *config.Configurations) error {
+	tls := configs.RpcSet.TLS
+	if !tls.Enabled {
+		return hs.ListenAndServe()
+	}
+	logger.Info("Serving rpc over tls")
+	return hs.ListenAndServeTLS(configs.ResolvePath(tls.CertFile), configs.ResolvePath(tls.KeyFile))
+}
+
 func (srv *Server) HandleSendSignedTx(w http.ResponseWriter, r *http.Request) {
 
-	pubKeystr := r.FormValue("pubKey")
+	from := r.FormValue("from")
 	to := r.FormValue("to")
 	val := r.FormValue("value")
-	signaturestr64 := r.FormValue("signature")
+	signature64 := r.FormValue("signature")
 	data := r.FormValue("data")
 
-	logger.Info("call sendtx ", val, " BDC to", to)
+	logger.Info("call sendsignedtx ", val, " BDC to", to)
 
-	value, ok := big.NewFloat(0).SetString(val)
-	if ok == false {
-		panic("invalid tx value")
+	// value is parsed exactly as float64, so it is the same as signed value
+	value, errValue := strconv.ParseFloat(val, 64)
+	if errValue != nil {
+		writeError(w, http.StatusBadRequest, errors.ReasonInvalidValue, "invalid tx value")
+		return
+	}
+	nonce, errNonce := strconv.ParseUint(r.FormValue("nonce"), 10, 64)
+	if errNonce != nil {
+		writeError(w, http.StatusBadRequest, errors.ReasonInvalidNonce, "invalid tx nonce")
+		return
+	}
+	timestamp, errTimestamp := strconv.ParseInt(r.FormValue("timestamp"), 10, 64)
+	if errTimestamp != nil {
+		writeError(w, http.StatusBadRequest, CodeBadRequest, "invalid tx timestamp")
+		return
 	}
 
-    signaturestr, _ := b64.StdEncoding.DecodeString(signaturestr64)
+	// chain id is optional, node's network is used if it isn't given
+	chainID := srv.Node.ChainID()
+	if id := r.FormValue("chainid"); id != "" {
+		parsed, errChainID := strconv.ParseUint(id, 10, 64)
+		if errChainID != nil {
+			writeError(w, http.StatusBadRequest, errors.ReasonInvalidChainID, "invalid tx chain id")
+			return
+		}
+		chainID = parsed
+	}
 
+	// validity window is optional, it must be the same as signed one
+	var window [2]uint64
+	for i, name := range []string{"validafter", "expiresat"} {
+		if height := r.FormValue(name); height != "" {
+			parsed, errHeight := strconv.ParseUint(height, 10, 64)
+			if errHeight != nil {
+				writeError(w, http.StatusBadRequest, CodeBadRequest, "invalid tx "+name)
+				return
+			}
+			window[i] = parsed
+		}
+	}
 
-	wallet := srv.Node.GetWallet()
-	pubKey := []byte(pubKeystr)
-	nonce := wallet.Nonce + 1
-	signature := []byte(signaturestr)
+	// sender can be given by its public key too, signature must recover the same key
+	if pubKey64 := r.FormValue("pubkey"); pubKey64 != "" && from == "" {
+		pubKey, errPubKey := b64.StdEncoding.DecodeString(pubKey64)
+		if errPubKey != nil {
+			writeError(w, http.StatusBadRequest, errors.ReasonInvalidSignature, "pubkey should be base64 encoded")
+			return
+		}
+		from = address.ToString(address.FromPublicKey(pubKey))
+	}
+	if from == "" {
+		writeError(w, http.StatusBadRequest, errors.ReasonInvalidAddress, "sender address is required")
+		return
+	}
+	signature, errDecode := b64.StdEncoding.DecodeString(signature64)
+	if errDecode != nil || len(signature) != transaction.SignatureLen {
+		writeError(w, http.StatusBadRequest, errors.ReasonInvalidSignature, "signature should be base64 of a 65 bytes compact signature")
+		return
+	}
 
-	v, _ := value.Float64()
-	tx := transaction.NewSignedTransaction(pubKey, nonce, to, v, signature, data)
+	tx := transaction.NewSignedTransaction(chainID, from, nonce, timestamp, to, value, signature, data)
+	tx.SetValidity(window[0], window[1])
 
-	resp := srv.Node.SendTransaction(tx)
-	if resp == nil {
-		json.NewEncoder(w).Encode("tx send failed")
+	resp, err := srv.Node.SendTransaction(tx)
+	if err != nil {
+		writeTxError(w, err)
 		return
 	}
-	err := json.NewEncoder(w).Encode(resp)
+	writeJSON(w, resp)
+}
+
+func (srv *Server) HandleSendRawTx(w http.ResponseWriter, r *http.Request) {
+	logger.Info("call sendrawtx")
+
+	resp, err := srv.Node.SendRawTransaction(r.FormValue("tx"))
 	if err != nil {
-		panic(err)
+		writeTxError(w, err)
+		return
 	}
+	writeJSON(w, resp)
 }
 
 func (srv *Server) HandleSendTx(w http.ResponseWriter, r *http.Request) {
 
+	from := r.FormValue("from")
 	to := r.FormValue("to")
 	val := r.FormValue("value")
 	data := r.FormValue("data")
 
-	logger.Info("call sendtx ", val, " BDC to", to)
+	logger.Info("call sendtx ", val, " BDC from ", from, " to", to)
 
-	value, ok := big.NewFloat(0).SetString(val) //strconv.Atoi(val)
-	// amt, err := strconv.ParseInt(amount, 10, 64)
-	if ok == false {
-		panic("invalid tx value")
+	value, ok := big.NewFloat(0).SetString(val)
+	if !ok {
+		writeError(w, http.StatusBadRequest, errors.ReasonInvalidValue, "invalid tx value")
+		return
 	}
 
-	wallet := srv.Node.GetWallet()
-	pubKey := wallet.PublicKey
-	nonce := wallet.Nonce + 1
-	//addr := wallet.GetStringAddress()
-	// if addr != from {
-	// 	panic(errors.New("no access to this wallet address"))
-	// }
-	//srv.Node.SendTransaction()
-	v, _ := value.Float64()
-	tx := transaction.NewTransaction(pubKey, nonce, to, v, data)
-	tx.Sign(wallet.PrivateKey)
-
-	resp := srv.Node.SendTransaction(tx)
-	if resp != nil {
-		srv.Node.GetWalletSet().AddMinerNonce()
+	// transaction expires if it isn't mined in expiresin blocks
+	var expiresIn uint64
+	if blocks := r.FormValue("expiresin"); blocks != "" {
+		var errExpires error
+		if expiresIn, errExpires = strconv.ParseUint(blocks, 10, 64); errExpires != nil {
+			writeError(w, http.StatusBadRequest, CodeBadRequest, "invalid expiresin")
+			return
+		}
 	}
-	err := json.NewEncoder(w).Encode(resp)
+
+	v, _ := value.Float64()
+	resp, err := srv.Node.SendFromWallet(from, to, v, data, expiresIn)
 	if err != nil {
-		panic(err)
+		writeTxError(w, err)
+		return
 	}
+	writeJSON(w, resp)
 }
 
 func (srv *Server) HandleGetInfo(w http.ResponseWriter, r *http.Request) {
 	logger.Info("Call getinfo")
+	writeJSON(w, srv.Node.GetInfo())
+}
 
-	err := json.NewEncoder(w).Encode(srv.Node.GetInfo())
-	// res, err := json.Marshal(node.GetInfo())
+func (srv *Server) HandleGetBlock(w http.ResponseWriter, r *http.Request) {
+	logger.Info("Call getblock")
+	q := r.URL.Query()
+
+	var data *block.Block
+	var errGetBlock error
+	switch {
+	case q.Get("hash") != "":
+		data, errGetBlock = srv.Node.GetBlockByHash(q.Get("hash"))
+	case q.Get("cid") != "":
+		data, errGetBlock = srv.Node.GetBlockByCid(q.Get("cid"))
+	default:
+		height, errConversion := strconv.ParseUint(q.Get("height"), 10, 64)
+		if errConversion != nil {
+			writeError(w, http.StatusBadRequest, CodeBadRequest, "invalid height")
+			return
+		}
+		data, errGetBlock = srv.Node.GetBlock(height)
+	}
+	if errGetBlock != nil {
+		writeNodeError(w, errGetBlock)
+		return
+	}
+	if data == nil {
+		writeError(w, http.StatusNotFound, CodeNotFound, "block not found")
+		return
+	}
+	writeJSON(w, blockResult(data, q.Get("header") == "true"))
+}
+
+func (srv *Server) HandleGetBlocks(w http.ResponseWriter, r *http.Request) {
+	logger.Info("Call getblocks")
+	q := r.URL.Query()
+
+	from, errFrom := strconv.ParseUint(q.Get("from"), 10, 64)
+	to, errTo := strconv.ParseUint(q.Get("to"), 10, 64)
+	if errFrom != nil || errTo != nil {
+		writeError(w, http.StatusBadRequest, CodeBadRequest, "invalid from or to height")
+		return
+	}
+	blocks, err := srv.Node.GetBlocks(from, to)
 	if err != nil {
-		panic(err)
+		writeNodeError(w, err)
+		return
 	}
-	// fmt.Fprintf(w, string(res))
+	writeJSON(w, blocksResult(blocks, q.Get("header") == "true"))
 }
 
-func (srv *Server) HandleGetBlock(w http.ResponseWriter, r *http.Request) {
-	logger.Info("Call getblock")
-	qh, ok := r.URL.Query()["height"]
-	if !ok {
-		panic("invalid height")
+func (srv *Server) HandleGetHead(w http.ResponseWriter, r *http.Request) {
+	logger.Info("Call gethead")
+	writeJSON(w, blockResult(srv.Node.GetHead(), r.URL.Query().Get("header") == "true"))
+}
+
+// blockResult returns block or only its header
+func blockResult(blk *block.Block, header bool) interface{} {
+	if header {
+		return node.NewBlockHeaderResponse(blk)
 	}
+	return blk
+}
 
-	qhi, errConversion := strconv.Atoi(qh[0])
-	if errConversion != nil {
-		panic("invalid height")
+// blocksResult returns blocks or only their headers
+func blocksResult(blocks []*block.Block, header bool) interface{} {
+	if !header {
+		return blocks
+	}
+	headers := make([]*node.BlockHeaderResponse, 0, len(blocks))
+	for _, blk := range blocks {
+		headers = append(headers, node.NewBlockHeaderResponse(blk))
 	}
+	return headers
+}
 
-	height := uint64(qhi)
+func (srv *Server) HandleGetTx(w http.ResponseWriter, r *http.Request) {
+	txid := mux.Vars(r)["id"]
+	logger.Info("Call gettx ", txid)
 
-	data, errGetBlock := srv.Node.GetBlock(height)
-	if errGetBlock != nil {
-		panic(errGetBlock)
+	resp, err := srv.Node.GetTransaction(txid)
+	if err != nil {
+		writeNodeError(w, err)
+		return
 	}
-	err := json.NewEncoder(w).Encode(data)
-	// res, err := json.Marshal(node.GetInfo())
+	writeJSON(w, resp)
+}
+
+func (srv *Server) HandleGetAccount(w http.ResponseWriter, r *http.Request) {
+	addr := mux.Vars(r)["address"]
+	logger.Info("Call getaccount ", addr)
+
+	resp, err := srv.Node.GetAccount(addr)
 	if err != nil {
-		panic(err)
+		writeNodeError(w, err)
+		return
 	}
-	// fmt.Fprintf(w, string(res))
+	writeJSON(w, resp)
 }
 
-func (srv *Server) HandleGetGenesis(w http.ResponseWriter, r *http.Request) {
-	data, errGetBlock := srv.Node.GetBlock(0)
-	if errGetBlock != nil {
-		panic(errGetBlock)
+func (srv *Server) HandleGetAddressHistory(w http.ResponseWriter, r *http.Request) {
+	addr := mux.Vars(r)["address"]
+	logger.Info("Call getaddresshistory ", addr)
+
+	limit, errLimit := queryInt(r, "limit")
+	if errLimit != nil {
+		writeError(w, http.StatusBadRequest, CodeBadRequest, "invalid limit")
+		return
 	}
-	err := json.NewEncoder(w).Encode(data)
-	// res, err := json.Marshal(node.GetInfo())
+	resp, err := srv.Node.GetAddressHistory(addr, r.URL.Query().Get("cursor"), limit)
 	if err != nil {
-		panic(err)
+		writeNodeError(w, err)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func (srv *Server) HandleGetPendingTxs(w http.ResponseWriter, r *http.Request) {
+	addr := mux.Vars(r)["address"]
+	logger.Info("Call getpendingtxs ", addr)
+
+	resp, err := srv.Node.GetPendingTransactions(addr)
+	if err != nil {
+		writeNodeError(w, err)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// queryInt parses an optional integer query parameter, it is zero if it is not set
+func queryInt(r *http.Request, name string) (int, error) {
+	val := r.URL.Query().Get(name)
+	if val == "" {
+		return 0, nil
 	}
+	return strconv.Atoi(val)
+}
+
+func (srv *Server) HandleGetGenesis(w http.ResponseWriter, r *http.Request) {
+	data, errGetBlock := srv.Node.GetBlock(0)
+	if errGetBlock != nil || data == nil {
+		writeError(w, http.StatusNotFound, CodeNotFound, "genesis block not found")
+		return
+	}
+	writeJSON(w, data)
 }
 
 func (srv *Server) HandleNewAddress(w http.ResponseWriter, r *http.Request) {
 	logger.Info("Call getnewaddress")
+	resp, err := srv.Node.GetNewAddress()
+	if err != nil {
+		writeNodeError(w, err)
+		return
+	}
+	writeJSON(w, resp)
+}
 
-	err := json.NewEncoder(w).Encode(srv.Node.GetNewAddress())
-	// res, err := json.Marshal(node.GetInfo())
+func (srv *Server) HandleWalletStatus(w http.ResponseWriter, r *http.Request) {
+	logger.Info("Call walletstatus")
+	writeJSON(w, srv.Node.GetWalletStatus())
+}
+
+func (srv *Server) HandleEncryptWallet(w http.ResponseWriter, r *http.Request) {
+	logger.Info("Call encryptwallet")
+	resp, err := srv.Node.EncryptWallet(r.FormValue("passphrase"))
+	if err != nil {
+		writeNodeError(w, err)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func (srv *Server) HandleUnlockWallet(w http.ResponseWriter, r *http.Request) {
+	logger.Info("Call unlockwallet")
+	timeout, errConversion := strconv.ParseUint(r.FormValue("timeout"), 10, 64)
+	if errConversion != nil || timeout == 0 {
+		writeError(w, http.StatusBadRequest, CodeBadRequest, "invalid unlock timeout")
+		return
+	}
+	resp, err := srv.Node.UnlockWallet(r.FormValue("passphrase"), timeout)
 	if err != nil {
-		panic(err)
+		writeNodeError(w, err)
+		return
 	}
-	// fmt.Fprintf(w, string(res))
+	writeJSON(w, resp)
+}
+
+func (srv *Server) HandleLockWallet(w http.ResponseWriter, r *http.Request) {
+	logger.Info("Call lockwallet")
+	resp, err := srv.Node.LockWallet()
+	if err != nil {
+		writeNodeError(w, err)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func (srv *Server) HandleExportMnemonic(w http.ResponseWriter, r *http.Request) {
+	logger.Info("Call exportmnemonic")
+	resp, err := srv.Node.GetMnemonic()
+	if err != nil {
+		writeNodeError(w, err)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func (srv *Server) HandleImportMnemonic(w http.ResponseWriter, r *http.Request) {
+	logger.Info("Call importmnemonic")
+	var count uint64
+	if val := r.FormValue("count"); val != "" {
+		var errConversion error
+		count, errConversion = strconv.ParseUint(val, 10, 32)
+		if errConversion != nil {
+			writeError(w, http.StatusBadRequest, CodeBadRequest, "invalid address count")
+			return
+		}
+	}
+	resp, err := srv.Node.SetMnemonic(r.FormValue("mnemonic"), uint32(count))
+	if err != nil {
+		writeNodeError(w, err)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func (srv *Server) HandleRescanWallet(w http.ResponseWriter, r *http.Request) {
+	logger.Info("Call rescanwallet")
+	var from uint64
+	if val := r.FormValue("from"); val != "" {
+		var errConversion error
+		from, errConversion = strconv.ParseUint(val, 10, 64)
+		if errConversion != nil {
+			writeError(w, http.StatusBadRequest, CodeBadRequest, "invalid rescan height")
+			return
+		}
+	}
+	resp, err := srv.Node.RescanWallet(from)
+	if err != nil {
+		writeNodeError(w, err)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func (srv *Server) HandleRescanStatus(w http.ResponseWriter, r *http.Request) {
+	logger.Info("Call rescanstatus")
+	writeJSON(w, srv.Node.GetRescanStatus())
+}
+
+func (srv *Server) HandleWalletHistory(w http.ResponseWriter, r *http.Request) {
+	addr := r.URL.Query().Get("address")
+	logger.Info("Call wallethistory ", addr)
+	resp, err := srv.Node.GetWalletHistory(addr)
+	if err != nil {
+		writeNodeError(w, err)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func (srv *Server) HandleExportKey(w http.ResponseWriter, r *http.Request) {
+	addr := r.FormValue("address")
+	logger.Info("Call exportkey ", addr)
+	resp, err := srv.Node.ExportKey(addr)
+	if err != nil {
+		writeNodeError(w, err)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func (srv *Server) HandleImportKey(w http.ResponseWriter, r *http.Request) {
+	logger.Info("Call importkey")
+	resp, err := srv.Node.ImportKey(r.FormValue("key"))
+	if err != nil {
+		writeNodeError(w, err)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func (srv *Server) HandleAddWatchOnly(w http.ResponseWriter, r *http.Request) {
+	addr := r.FormValue("address")
+	logger.Info("Call addwatchonly ", addr)
+	resp, err := srv.Node.AddWatchOnly(addr)
+	if err != nil {
+		writeNodeError(w, err)
+		return
+	}
+	writeJSON(w, resp)
 }
 
 func (srv *Server) HandleHealthCheck(w http.ResponseWriter, r *http.Request) {
@@ -210,8 +552,46 @@ func (srv *Server) HandleHealthCheck(w http.ResponseWriter, r *http.Request) {
 	msg := Message{
 		Text: "Badcoin (BDC) is ok!",
 	}
-	err := json.NewEncoder(w).Encode(msg)
-	if err != nil {
-		panic(err)
+	writeJSON(w, msg)
+}
+
+func (srv *Server) HandleGetPeers(w http.ResponseWriter, r *http.Request) {
+	logger.Info("Call getpeers")
+	writeJSON(w, srv.Node.GetPeers())
+}
+
+func (srv *Server) HandleBanPeer(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue("peer")
+	seconds := r.FormValue("duration")
+	logger.Info("Call banpeer ", id)
+
+	// zero duration bans peer for configured ban time
+	var duration time.Duration
+	if seconds != "" {
+		secs, errConversion := strconv.ParseUint(seconds, 10, 64)
+		if errConversion != nil {
+			writeError(w, http.StatusBadRequest, CodeBadRequest, "invalid ban duration")
+			return
+		}
+		duration = time.Duration(secs) * time.Second
+	}
+
+	resp, errBan := srv.Node.BanPeer(id, duration)
+	if errBan != nil {
+		writeError(w, http.StatusBadRequest, CodeBadRequest, "invalid peer id")
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func (srv *Server) HandleUnbanPeer(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue("peer")
+	logger.Info("Call unbanpeer ", id)
+
+	resp, errUnban := srv.Node.UnbanPeer(id)
+	if errUnban != nil {
+		writeError(w, http.StatusBadRequest, CodeBadRequest, "invalid peer id")
+		return
 	}
+	writeJSON(w, resp)
 }
@@ -2,18 +2,29 @@ package server
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	block "badcoin/src/block"
 	config "badcoin/src/config"
+	event "badcoin/src/event"
+	errors "badcoin/src/helper/error"
 	node "badcoin/src/node"
+	wallet "badcoin/src/wallet"
+
+	websocket "github.com/gorilla/websocket"
 )
 
 func TestServer(t *testing.T) {
 	ctx := context.Background()
 	configs, _ := config.Init("")
 	newNode := node.CreateNewNode(ctx, configs)
-	server := CreateNewServer(ctx, newNode, "3000")
+	server := CreateNewServer(ctx, newNode, configs.RpcSet)
 	if server==nil {
 		t.Error("server creation failed")
 	}
@@ -23,3 +34,193 @@ func TestServer(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestJSONRPC(t *testing.T) {
+	srv := &Server{}
+	call := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/rpc", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		srv.HandleJSONRPC(rec, req)
+		return rec
+	}
+
+	var resp RPCResponse
+	json.Unmarshal(call(`{"jsonrpc":"2.0","method":`).Body.Bytes(), &resp)
+	if resp.Error == nil || resp.Error.Code != RPCParseError {
+		t.Error("parse error expected")
+	}
+
+	resp = RPCResponse{}
+	json.Unmarshal(call(`{"jsonrpc":"2.0","method":"foo","id":1}`).Body.Bytes(), &resp)
+	if resp.Error == nil || resp.Error.Code != RPCMethodNotFound || string(resp.ID) != "1" {
+		t.Error("method not found error expected")
+	}
+
+	var batch []RPCResponse
+	json.Unmarshal(call(`[{"jsonrpc":"2.0","method":"foo","id":"a"},{"jsonrpc":"2.0","method":"foo"},{"method":"foo","id":2}]`).Body.Bytes(), &batch)
+	if len(batch) != 2 || batch[1].Error.Code != RPCInvalidRequest {
+		t.Error("batch response is not valid")
+	}
+
+	if rec := call(`[]`); !strings.Contains(rec.Body.String(), "-32600") {
+		t.Error("empty batch should be invalid request")
+	}
+
+	// a cross-site form can post text/plain, it is rejected
+	rec := httptest.NewRecorder()
+	srv.HandleJSONRPC(rec, httptest.NewRequest("POST", "/rpc", strings.NewReader(`{"jsonrpc":"2.0","method":"tx_send","id":1}`)))
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Error("request without json content type should be rejected")
+	}
+
+	var height uint64
+	var data string
+	if err := decodeParams([]byte(`{"height":5}`), []string{"height", "data"}, 1, &height, &data); err != nil || height != 5 {
+		t.Error("decoding named params failed")
+	}
+	if err := decodeParams([]byte(`[]`), []string{"height"}, 1, &height); err == nil {
+		t.Error("missing param should fail")
+	}
+}
+
+func TestErrorResponses(t *testing.T) {
+	srv := &Server{}
+	rec := httptest.NewRecorder()
+	srv.HandleGetBlock(rec, httptest.NewRequest("GET", "/block?height=abc", nil))
+	var res node.ErrorResponse
+	json.Unmarshal(rec.Body.Bytes(), &res)
+	if rec.Code != http.StatusBadRequest || res.Code != CodeBadRequest {
+		t.Error("invalid height should return bad request")
+	}
+
+	rec = httptest.NewRecorder()
+	handler := recoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("handler failed")
+	}))
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Error("panic should be recovered as internal error")
+	}
+
+	if txErrorStatus(errors.ReasonAlreadyPending) != http.StatusConflict || txErrorStatus(errors.ReasonInvalidSignature) != http.StatusBadRequest {
+		t.Error("wrong status of rejected transaction")
+	}
+	if txErrorStatus(errors.ReasonWalletLocked) != http.StatusForbidden {
+		t.Error("locked wallet should be forbidden")
+	}
+
+	rec = httptest.NewRecorder()
+	writeNodeError(rec, wallet.ErrorWrongPassphrase)
+	if rec.Code != http.StatusForbidden {
+		t.Error("wrong passphrase should be forbidden")
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	bus := event.NewBus()
+	srv := &Server{Events: bus}
+	ts := httptest.NewServer(http.HandlerFunc(srv.HandleWebSocket))
+	defer ts.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http")
+	if _, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Origin": {"http://example.com"}}); err == nil {
+		t.Error("page of other origin should not connect")
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": "subscribe", "params": []string{SubNewHeads}})
+	var resp struct {
+		Result string
+		Error  *RPCError
+	}
+	if err := conn.ReadJSON(&resp); err != nil || resp.Error != nil || resp.Result == "" {
+		t.Fatal("subscribe failed")
+	}
+
+	// subscription is registered before response is sent
+	bus.Publish(event.TopicNewHead, &event.NewHeadEvent{Block: &block.Block{Height: 7}})
+	var notification struct {
+		Method string
+		Params struct {
+			Subscription string
+			Result       node.BlockHeaderResponse
+		}
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := conn.ReadJSON(&notification); err != nil {
+		t.Fatal(err)
+	}
+	if notification.Params.Subscription != resp.Result || notification.Params.Result.Height != 7 {
+		t.Error("wrong new head notification")
+	}
+
+	conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": 2, "method": "subscribe", "params": []string{SubAddressActivity, "invalid"}})
+	resp.Error = nil
+	if err := conn.ReadJSON(&resp); err != nil || resp.Error == nil || resp.Error.Code != RPCInvalidParams {
+		t.Error("invalid address should be rejected")
+	}
+}
+
+func TestAuth(t *testing.T) {
+	called := false
+	ok := func(w http.ResponseWriter, r *http.Request) { called = true }
+	call := func(srv *Server, setup func(r *http.Request)) int {
+		called = false
+		req := httptest.NewRequest("POST", "/tx/send", nil)
+		setup(req)
+		rec := httptest.NewRecorder()
+		srv.requireAuth(ok)(rec, req)
+		return rec.Code
+	}
+
+	// without credentials only loopback clients are allowed
+	srv := &Server{}
+	if call(srv, func(r *http.Request) {}) != http.StatusUnauthorized || called {
+		t.Error("remote client should be rejected")
+	}
+	if call(srv, func(r *http.Request) { r.RemoteAddr = "127.0.0.1:5000" }); !called {
+		t.Error("loopback client should be allowed")
+	}
+	if call(srv, func(r *http.Request) {
+		r.RemoteAddr = "127.0.0.1:5000"
+		r.Header.Set("Origin", "http://example.com")
+	}); called {
+		t.Error("page of a browser should not use loopback access")
+	}
+	if call(srv, func(r *http.Request) {
+		r.RemoteAddr = "127.0.0.1:5000"
+		r.Header.Set("Sec-Fetch-Site", "cross-site")
+	}); called {
+		t.Error("cross-site browser request should be rejected")
+	}
+
+	srv = &Server{Auth: config.RpcAuth{Token: "secret", Username: "user", Password: "pass"}}
+	if call(srv, func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") }); !called {
+		t.Error("valid token should be allowed")
+	}
+	if call(srv, func(r *http.Request) { r.SetBasicAuth("user", "pass") }); !called {
+		t.Error("valid basic auth should be allowed")
+	}
+	if call(srv, func(r *http.Request) {
+		r.SetBasicAuth("user", "pass")
+		r.Header.Set("Origin", "http://example.com")
+	}); called {
+		t.Error("saved basic auth of browser should not be used by other sites")
+	}
+	if call(srv, func(r *http.Request) { r.SetBasicAuth("user", "wrong") }) != http.StatusUnauthorized || called {
+		t.Error("wrong password should be rejected")
+	}
+	if call(srv, func(r *http.Request) { r.RemoteAddr = "127.0.0.1:5000" }); called {
+		t.Error("loopback client should need credentials when they are set")
+	}
+
+	resp := srv.handleRPCMessage([]byte(`{"jsonrpc":"2.0","method":"tx_send","params":["a",1],"id":1}`), false)
+	if resp.Error == nil || resp.Error.Code != RPCUnauthorized {
+		t.Error("unauthorized rpc call should be rejected")
+	}
+}
@@ -0,0 +1,287 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	event "badcoin/src/event"
+	address "badcoin/src/helper/address"
+	logger "badcoin/src/helper/logger"
+	node "badcoin/src/node"
+
+	websocket "github.com/gorilla/websocket"
+)
+
+// subscription kinds of websocket clients
+const (
+	SubNewHeads            = "newHeads"
+	SubReorgs              = "reorgs"
+	SubPendingTransactions = "pendingTransactions"
+	SubAddressActivity     = "addressActivity"
+)
+
+const (
+	wsWriteTimeout     = 10 * time.Second
+	wsPongTimeout      = 60 * time.Second
+	wsPingPeriod       = 50 * time.Second
+	wsMaxMessageSize   = 1 << 16
+	wsSendBuffer       = 256
+	wsEventBuffer      = 128
+	wsMaxSubscriptions = 32
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	CheckOrigin:     checkOrigin,
+}
+
+// checkOrigin allows clients without Origin (non-browser clients) and pages which are served by node itself
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// SubscriptionResult is the params of a subscription notification
+type SubscriptionResult struct {
+	Subscription string      `json:"subscription"`
+	Result       interface{} `json:"result"`
+}
+
+// RPCNotification is a JSON-RPC 2.0 notification which is sent to websocket clients
+type RPCNotification struct {
+	JSONRPC string             `json:"jsonrpc"`
+	Method  string             `json:"method"`
+	Params  SubscriptionResult `json:"params"`
+}
+
+// ReorgNotification is the result of reorg subscription
+type ReorgNotification struct {
+	OldHead *node.BlockHeaderResponse
+	NewHead *node.BlockHeaderResponse
+}
+
+// wsClient is a websocket connection and its subscriptions
+type wsClient struct {
+	conn   *websocket.Conn
+	events *event.Bus
+	send   chan interface{}
+	done   chan struct{}
+	mutex  sync.Mutex
+	subs   map[string]*event.Subscription
+	nextID uint64
+}
+
+// HandleWebSocket upgrades connection to websocket and serves subscribe/unsubscribe requests
+func (srv *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if srv.Events == nil {
+		writeError(w, http.StatusServiceUnavailable, CodeInternal, "events are not available")
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logger.Debug("websocket upgrade failed: ", err)
+		return
+	}
+	logger.Info("websocket client connected: ", conn.RemoteAddr())
+
+	client := &wsClient{
+		conn:   conn,
+		events: srv.Events,
+		send:   make(chan interface{}, wsSendBuffer),
+		done:   make(chan struct{}),
+		subs:   make(map[string]*event.Subscription),
+	}
+	go client.writeLoop()
+	client.readLoop()
+}
+
+// readLoop reads requests until connection is closed
+func (c *wsClient) readLoop() {
+	defer c.close()
+
+	c.conn.SetReadLimit(wsMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	for {
+		_, msg, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req RPCRequest
+		if err := json.Unmarshal(msg, &req); err != nil {
+			c.reply(&RPCResponse{JSONRPC: "2.0", Error: newRPCError(RPCParseError, "parse error"), ID: nullID})
+			continue
+		}
+		id := req.ID
+		if len(id) == 0 {
+			id = nullID
+		}
+		result, rpcErr := c.handle(&req)
+		if rpcErr != nil {
+			c.reply(&RPCResponse{JSONRPC: "2.0", Error: rpcErr, ID: id})
+			continue
+		}
+		c.reply(&RPCResponse{JSONRPC: "2.0", Result: result, ID: id})
+	}
+}
+
+// handle runs subscribe and unsubscribe requests
+func (c *wsClient) handle(req *RPCRequest) (interface{}, *RPCError) {
+	switch req.Method {
+	case "subscribe":
+		var kind, addr string
+		if err := decodeParams(req.Params, []string{"kind", "address"}, 1, &kind, &addr); err != nil {
+			return nil, err
+		}
+		return c.subscribe(kind, addr)
+	case "unsubscribe":
+		var subID string
+		if err := decodeParams(req.Params, []string{"subscription"}, 1, &subID); err != nil {
+			return nil, err
+		}
+		return c.unsubscribe(subID), nil
+	default:
+		return nil, newRPCError(RPCMethodNotFound, "method not found")
+	}
+}
+
+// subscribe subscribes to event bus and forwards matching events to client
+func (c *wsClient) subscribe(kind string, addr string) (interface{}, *RPCError) {
+	var topic string
+	switch kind {
+	case SubNewHeads:
+		topic = event.TopicNewHead
+	case SubReorgs:
+		topic = event.TopicReorg
+	case SubPendingTransactions:
+		topic = event.TopicPendingTx
+	case SubAddressActivity:
+		if !address.ValidateAddress(addr) {
+			return nil, newRPCError(RPCInvalidParams, "invalid address")
+		}
+		topic = event.TopicConfirmedTx
+	default:
+		return nil, newRPCError(RPCInvalidParams, "unknown subscription "+kind)
+	}
+
+	c.mutex.Lock()
+	if len(c.subs) >= wsMaxSubscriptions {
+		c.mutex.Unlock()
+		return nil, newRPCError(RPCServerError, "too many subscriptions")
+	}
+	c.nextID++
+	subID := "0x" + strconv.FormatUint(c.nextID, 16)
+	sub := c.events.Subscribe(topic, wsEventBuffer)
+	c.subs[subID] = sub
+	c.mutex.Unlock()
+
+	go c.forward(subID, sub, addr)
+	return subID, nil
+}
+
+// unsubscribe cancels a subscription, it returns false if subscription is not found
+func (c *wsClient) unsubscribe(subID string) bool {
+	c.mutex.Lock()
+	sub, ok := c.subs[subID]
+	delete(c.subs, subID)
+	c.mutex.Unlock()
+	if ok {
+		sub.Unsubscribe()
+	}
+	return ok
+}
+
+// forward converts events of a subscription into notifications
+func (c *wsClient) forward(subID string, sub *event.Subscription, addr string) {
+	for e := range sub.C {
+		var result interface{}
+		switch ev := e.(type) {
+		case *event.NewHeadEvent:
+			result = node.NewBlockHeaderResponse(ev.Block)
+		case *event.ReorgEvent:
+			result = &ReorgNotification{
+				OldHead: node.NewBlockHeaderResponse(ev.OldHead),
+				NewHead: node.NewBlockHeaderResponse(ev.NewHead),
+			}
+		case *event.TxEvent:
+			if addr != "" && !ev.Touches(addr) {
+				continue
+			}
+			result = ev
+		default:
+			continue
+		}
+		notification := &RPCNotification{
+			JSONRPC: "2.0",
+			Method:  "subscription",
+			Params:  SubscriptionResult{Subscription: subID, Result: result},
+		}
+		select {
+		case c.send <- notification:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// reply queues a response for writer
+func (c *wsClient) reply(resp *RPCResponse) {
+	select {
+	case c.send <- resp:
+	case <-c.done:
+	}
+}
+
+// writeLoop is the only writer of connection, it also pings client
+func (c *wsClient) writeLoop() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := c.conn.WriteJSON(msg); err != nil {
+				c.conn.Close()
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.conn.Close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// close cancels all subscriptions and closes connection
+func (c *wsClient) close() {
+	close(c.done)
+	c.mutex.Lock()
+	subs := c.subs
+	c.subs = make(map[string]*event.Subscription)
+	c.mutex.Unlock()
+	for _, sub := range subs {
+		sub.Unsubscribe()
+	}
+	c.conn.Close()
+	logger.Info("websocket client disconnected: ", c.conn.RemoteAddr())
+}
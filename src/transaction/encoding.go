@@ -0,0 +1,142 @@
+package transaction
+
+import (
+	codec "badcoin/src/helper/codec"
+	errors "badcoin/src/helper/error"
+)
+
+// EncodingVersion is version of canonical encoding of transactions
+const EncodingVersion = 3
+
+// number of encoded fields of a transaction, including version
+const txFields = 14
+
+// Encode writes canonical encoding of transaction. ID isn't encoded, it is hash of transaction.
+// Nil transaction is encoded as null, merkle tree pads odd leaves with it
+func (tx *Transaction) Encode(e *codec.Encoder) {
+	if tx == nil {
+		e.Null()
+		return
+	}
+	e.Array(txFields)
+	e.Uint(EncodingVersion)
+	e.Uint(tx.ChainID)
+	e.Uint(tx.Nonce)
+	e.Uint(tx.ValidAfterHeight)
+	e.Uint(tx.ExpiresAtHeight)
+	e.ByteString(tx.Signature)
+	e.Int(tx.Timestamp)
+	e.Text(tx.From)
+	e.Text(tx.To)
+	e.Uint(tx.Fee)
+	e.Float(tx.Value)
+	e.Text(tx.Data)
+	if tx.Multisig == nil {
+		e.Null()
+	} else {
+		e.Array(2)
+		e.Uint(uint64(tx.Multisig.Threshold))
+		e.Array(len(tx.Multisig.PublicKeys))
+		for _, pubKey := range tx.Multisig.PublicKeys {
+			e.ByteString(pubKey)
+		}
+	}
+	e.Array(len(tx.Signatures))
+	for _, signature := range tx.Signatures {
+		e.ByteString(signature)
+	}
+}
+
+// DecodeTransaction reads a canonically encoded transaction and sets its ID,
+// transactions with a non-positive or non-finite value are rejected
+func DecodeTransaction(d *codec.Decoder) (*Transaction, error) {
+	if err := d.Array(txFields); err != nil {
+		return nil, err
+	}
+	version, err := d.Uint()
+	if err != nil {
+		return nil, err
+	}
+	if version != EncodingVersion {
+		return nil, codec.ErrVersion
+	}
+
+	var tx Transaction
+	if tx.ChainID, err = d.Uint(); err != nil {
+		return nil, err
+	}
+	if tx.Nonce, err = d.Uint(); err != nil {
+		return nil, err
+	}
+	if tx.ValidAfterHeight, err = d.Uint(); err != nil {
+		return nil, err
+	}
+	if tx.ExpiresAtHeight, err = d.Uint(); err != nil {
+		return nil, err
+	}
+	if tx.Signature, err = d.ByteString(); err != nil {
+		return nil, err
+	}
+	if tx.Timestamp, err = d.Int(); err != nil {
+		return nil, err
+	}
+	if tx.From, err = d.Text(); err != nil {
+		return nil, err
+	}
+	if tx.To, err = d.Text(); err != nil {
+		return nil, err
+	}
+	if tx.Fee, err = d.Uint(); err != nil {
+		return nil, err
+	}
+	if tx.Value, err = d.Float(); err != nil {
+		return nil, err
+	}
+	if !tx.HasValidValue() {
+		return nil, errors.InvalidTxValue
+	}
+	if tx.Data, err = d.Text(); err != nil {
+		return nil, err
+	}
+	if !d.Null() {
+		if tx.Multisig, err = decodeMultisig(d); err != nil {
+			return nil, err
+		}
+	}
+	if tx.Signatures, err = decodeByteStrings(d); err != nil {
+		return nil, err
+	}
+
+	tx.UpdateHash()
+	return &tx, nil
+}
+
+func decodeMultisig(d *codec.Decoder) (*Multisig, error) {
+	if err := d.Array(2); err != nil {
+		return nil, err
+	}
+	threshold, err := d.Uint32()
+	if err != nil {
+		return nil, err
+	}
+	pubKeys, err := decodeByteStrings(d)
+	if err != nil {
+		return nil, err
+	}
+	return &Multisig{Threshold: threshold, PublicKeys: pubKeys}, nil
+}
+
+// decodeByteStrings reads an array of byte strings, empty array is returned as nil
+func decodeByteStrings(d *codec.Decoder) ([][]byte, error) {
+	n, err := d.ArrayLen()
+	if err != nil || n == 0 {
+		return nil, err
+	}
+	items := make([][]byte, n)
+	for i := range items {
+		if items[i], err = d.ByteString(); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
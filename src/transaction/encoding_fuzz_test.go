@@ -0,0 +1,35 @@
+//go:build go1.18
+// +build go1.18
+
+package transaction
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+// FuzzDeserializeTx checks that decoding doesn't panic and only canonical encodings are accepted
+func FuzzDeserializeTx(f *testing.F) {
+	tx := &Transaction{ChainID: 1001, Nonce: 1, Timestamp: 42, From: "1From", To: "1To", Value: 1.5, Data: "memo", Signature: make([]byte, SignatureLen)}
+	f.Add(tx.Serialize())
+	ms := &Multisig{Threshold: 1, PublicKeys: [][]byte{{2, 1}, {3, 1}}}
+	f.Add(NewMultisigTransaction(1001, ms, 2, "1To", 3, "").Serialize())
+	for _, value := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		invalid := *tx
+		invalid.Value = value
+		f.Add(invalid.Serialize())
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		decoded, err := DeserializeTx(data)
+		if err != nil {
+			return
+		}
+		if !decoded.HasValidValue() {
+			t.Fatalf("transaction with value %v is decoded", decoded.Value)
+		}
+		if !bytes.Equal(decoded.Serialize(), data) {
+			t.Fatalf("decoded transaction encodes differently: %x", data)
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package transaction
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"sort"
+	"time"
+
+	address "badcoin/src/helper/address"
+	errors "badcoin/src/helper/error"
+	hash "badcoin/src/helper/hash"
+)
+
+// MaxMultisigKeys is the maximum number of public keys of a multisig account
+const MaxMultisigKeys = 15
+
+// Multisig is the m-of-n script of a multisig account, public keys are sorted
+type Multisig struct {
+	Threshold  uint32
+	PublicKeys [][]byte
+}
+
+// NewMultisig creates a m-of-n multisig, public keys are sorted so their order doesn't change its address
+func NewMultisig(threshold int, pubKeys [][]byte) (*Multisig, error) {
+	if threshold <= 0 {
+		return nil, errors.InvalidMultisig
+	}
+	keys := make([][]byte, len(pubKeys))
+	copy(keys, pubKeys)
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+	ms := &Multisig{Threshold: uint32(threshold), PublicKeys: keys}
+	if !ms.Valid() {
+		return nil, errors.InvalidMultisig
+	}
+	return ms, nil
+}
+
+// Valid checks threshold and that public keys are sorted, unique and compressed secp256k1 keys
+func (ms *Multisig) Valid() bool {
+	n := len(ms.PublicKeys)
+	if n == 0 || n > MaxMultisigKeys || ms.Threshold == 0 || int(ms.Threshold) > n {
+		return false
+	}
+	for i, pubKey := range ms.PublicKeys {
+		if i > 0 && bytes.Compare(ms.PublicKeys[i-1], pubKey) >= 0 {
+			return false
+		}
+		if parsePublicKey(pubKey) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Script returns serialized multisig, threshold and number of keys are followed by keys with their lengths
+func (ms *Multisig) Script() []byte {
+	script := []byte{byte(ms.Threshold), byte(len(ms.PublicKeys))}
+	for _, pubKey := range ms.PublicKeys {
+		script = append(script, byte(len(pubKey)))
+		script = append(script, pubKey...)
+	}
+	return script
+}
+
+// Address returns address of multisig account
+func (ms *Multisig) Address() string {
+	return address.ToString(address.FromScript(ms.Script()))
+}
+
+// keyIndex returns position of a public key in multisig, it is -1 if key doesn't belong to it
+func (ms *Multisig) keyIndex(pubKey []byte) int {
+	for i, key := range ms.PublicKeys {
+		if bytes.Equal(key, pubKey) {
+			return i
+		}
+	}
+	return -1
+}
+
+// NewMultisigTransaction creates an unsigned transaction which is sent from a multisig address
+func NewMultisigTransaction(chainID uint64, ms *Multisig, nonce uint64, to string, value float64, data string) *Transaction {
+	tx := Transaction{
+		ID:         *hash.ZeroHash(),
+		ChainID:    chainID,
+		Nonce:      nonce,
+		Signature:  []byte{},
+		Timestamp:  time.Now().UnixMilli(),
+		From:       ms.Address(),
+		To:         to,
+		Fee:        0,
+		Value:      value,
+		Data:       data,
+		Multisig:   ms,
+		Signatures: make([][]byte, len(ms.PublicKeys)),
+	}
+
+	tx.UpdateHash()
+
+	return &tx
+}
+
+// SignMultisig adds signature of a multisig key, transaction is partially signed until threshold is reached
+func (tx *Transaction) SignMultisig(privateKey ecdsa.PrivateKey) error {
+	if tx.Multisig == nil {
+		return errors.InvalidMultisig
+	}
+	index := tx.Multisig.keyIndex(publicKeyBytes(&privateKey.PublicKey))
+	if index < 0 {
+		return errors.NotMultisigKey
+	}
+	if len(tx.Signatures) != len(tx.Multisig.PublicKeys) {
+		signatures := make([][]byte, len(tx.Multisig.PublicKeys))
+		copy(signatures, tx.Signatures)
+		tx.Signatures = signatures
+	}
+	txHash := tx.CalcHash()
+	tx.Signatures[index] = signHash(&privateKey, txHash[:])
+	return nil
+}
+
+// CombineSignatures adds signatures of another partially signed copy of transaction
+func (tx *Transaction) CombineSignatures(other *Transaction) error {
+	if tx.Multisig == nil || other.Multisig == nil || tx.CalcHash() != other.CalcHash() {
+		return errors.MultisigTxMismatch
+	}
+	n := len(tx.Multisig.PublicKeys)
+	if len(tx.Signatures) != n || len(other.Signatures) != n {
+		return errors.InvalidMultisig
+	}
+	for i, signature := range other.Signatures {
+		if len(tx.Signatures[i]) == 0 && len(signature) > 0 {
+			tx.Signatures[i] = signature
+		}
+	}
+	return nil
+}
+
+// SignatureCount returns number of multisig keys which have signed transaction
+func (tx *Transaction) SignatureCount() int {
+	count := 0
+	for _, signature := range tx.Signatures {
+		if len(signature) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// verifyMultisig checks that sender is multisig address and threshold of its keys have signed transaction,
+// every given signature must be valid
+func (tx *Transaction) verifyMultisig() bool {
+	ms := tx.Multisig
+	if len(tx.Signature) != 0 {
+		return false
+	}
+	if !ms.Valid() || ms.Address() != tx.From || len(tx.Signatures) != len(ms.PublicKeys) {
+		return false
+	}
+	txHash := tx.CalcHash()
+	count := 0
+	for i, signature := range tx.Signatures {
+		if len(signature) == 0 {
+			continue
+		}
+		pub, err := recoverHash(signature, txHash[:])
+		if err != nil || !bytes.Equal(pub.SerializeCompressed(), ms.PublicKeys[i]) {
+			return false
+		}
+		count++
+	}
+	return count >= int(ms.Threshold)
+}
@@ -2,29 +2,43 @@ package transaction
 
 import (
 	address "badcoin/src/helper/address"
+	codec "badcoin/src/helper/codec"
 	hash "badcoin/src/helper/hash"
 	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
-	"encoding/json"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"log"
+	"math"
 	"math/big"
 	"strings"
 	"time"
+
+	"github.com/btcsuite/btcd/btcec"
 )
 
+// SignatureLen is length of compact recoverable signatures, recovery flag is followed by r and s
+const SignatureLen = 65
+
+// halfOrder is half of secp256k1 curve order, s of signatures must not be more than it (low-S)
+var halfOrder = new(big.Int).Rsh(btcec.S256().N, 1)
+
 type Transaction struct {
-	ID        hash.Hash
-	Nonce     uint64
-	PublicKey []byte
-	Signature []byte
-	Timestamp int64
-	From      string
-	To        string
-	Fee       uint64
-	Value     float64
-	Data      string
+	ID               hash.Hash
+	ChainID          uint64 // id of the network, it is signed so transaction can't be replayed on other networks
+	Nonce            uint64
+	ValidAfterHeight uint64 `json:",omitempty"` // transaction is valid only in blocks after this height
+	ExpiresAtHeight  uint64 `json:",omitempty"` // transaction isn't valid in blocks of this height and after it, 0 never expires
+	Signature        []byte // secp256k1 compact signature, public key of sender is recovered from it
+	Timestamp        int64
+	From             string
+	To               string
+	Fee              uint64
+	Value            float64
+	Data             string
+
+	Multisig   *Multisig `json:",omitempty"` // sender of multisig transactions
+	Signatures [][]byte  `json:",omitempty"` // signatures of multisig keys in their order
 }
 
 // String returns a human-readable representation of a transaction
@@ -33,36 +47,64 @@ func (tx Transaction) String() string {
 
 	lines = append(lines, fmt.Sprintf("--- Transaction %v:", tx.ID))
 	lines = append(lines, fmt.Sprintf("       ID:           %v", tx.ID.String()))
+	lines = append(lines, fmt.Sprintf("       Chain ID:     %d", tx.ChainID))
 	lines = append(lines, fmt.Sprintf("       Time:         %d", tx.Timestamp))
+	if tx.ValidAfterHeight != 0 || tx.ExpiresAtHeight != 0 {
+		lines = append(lines, fmt.Sprintf("       Valid:        after %d, expires at %d", tx.ValidAfterHeight, tx.ExpiresAtHeight))
+	}
 	lines = append(lines, fmt.Sprintf("       From:         %s", tx.From))
 	lines = append(lines, fmt.Sprintf("       To:           %s", tx.To))
-	lines = append(lines, fmt.Sprintf("       PublicKey:    %v", tx.PublicKey))
 	lines = append(lines, fmt.Sprintf("       Fee:		    %d", tx.Fee))
 	lines = append(lines, fmt.Sprintf("       Value:		%f", tx.Value))
 	lines = append(lines, fmt.Sprintf("       Signature:    %x", tx.Signature))
 	lines = append(lines, fmt.Sprintf("       Data:         %x", tx.Data))
+	if tx.Multisig != nil {
+		lines = append(lines, fmt.Sprintf("       Multisig:     %d of %d, %d signed", tx.Multisig.Threshold, len(tx.Multisig.PublicKeys), tx.SignatureCount()))
+	}
 
 	return strings.Join(lines, "\n")
 }
 
+// Serialize returns canonical encoding of transaction, it is used for hashing, gossip and storage
 func (tx *Transaction) Serialize() []byte {
-	data, err := json.Marshal(tx)
-	if err != nil {
-		panic(err)
-	}
-	return data
+	e := codec.NewEncoder()
+	tx.Encode(e)
+	return e.Data()
 }
 
+// DeserializeTx decodes a canonically encoded transaction, other encodings of it are rejected
 func DeserializeTx(buf []byte) (*Transaction, error) {
-	var tx Transaction
-	err := json.Unmarshal(buf, &tx)
+	d := codec.NewDecoder(buf)
+	tx, err := DecodeTransaction(d)
 	if err != nil {
 		return nil, err
 	}
-	return &tx, nil
+	if err := d.Done(); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// EncodeRaw returns hex of serialized transaction
+func (tx *Transaction) EncodeRaw() string {
+	return hex.EncodeToString(tx.Serialize())
+}
+
+// DecodeRawTx decodes a hex or base64 serialized transaction
+func DecodeRawTx(raw string) (*Transaction, error) {
+	raw = strings.TrimSpace(raw)
+	buf, err := hex.DecodeString(raw)
+	if err != nil {
+		buf, err = base64.StdEncoding.DecodeString(raw)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return DeserializeTx(buf)
 }
 
-func NewTransaction(pubKey []byte, nonce uint64, to string, value float64, data string) *Transaction {
+// NewTransaction creates an unsigned transaction, sender is address of compressed public key
+func NewTransaction(chainID uint64, pubKey []byte, nonce uint64, to string, value float64, data string) *Transaction {
 
 	fromBytes := address.FromPublicKey(pubKey)
 	from := address.ToString(fromBytes)
@@ -71,8 +113,8 @@ func NewTransaction(pubKey []byte, nonce uint64, to string, value float64, data
 
 	tx := Transaction{
 		ID:        *hash.ZeroHash(),
+		ChainID:   chainID,
 		Nonce:     nonce,
-		PublicKey: pubKey,
 		Signature: []byte{},
 		Timestamp: now.UnixMilli(),
 		From:      from,
@@ -87,19 +129,16 @@ func NewTransaction(pubKey []byte, nonce uint64, to string, value float64, data
 	return &tx
 }
 
-func NewSignedTransaction(pubKey []byte, nonce uint64, to string, value float64, signature []byte, data string) *Transaction {
-
-	fromBytes := address.FromPublicKey(pubKey)
-	from := address.ToString(fromBytes)
-
-	now := time.Now()
+// NewSignedTransaction creates a transaction which is signed offline,
+// chain id, nonce and timestamp must be the same as signed ones
+func NewSignedTransaction(chainID uint64, from string, nonce uint64, timestamp int64, to string, value float64, signature []byte, data string) *Transaction {
 
 	tx := Transaction{
 		ID:        *hash.ZeroHash(),
+		ChainID:   chainID,
 		Nonce:     nonce,
-		PublicKey: pubKey,
 		Signature: signature,
-		Timestamp: now.UnixMilli(),
+		Timestamp: timestamp,
 		From:      from,
 		To:        to,
 		Fee:       0,
@@ -112,12 +151,15 @@ func NewSignedTransaction(pubKey []byte, nonce uint64, to string, value float64,
 	return &tx
 }
 
+// GetTxid returns hash of transaction with its signature, signatures of multisig keys are not hashed,
+// so a multisig spend has one txid whichever keys have signed it
 func (tx *Transaction) GetTxid() hash.Hash {
-	txc := tx.TrimmedCopy()
-	txc.ID = *hash.ZeroHash()
-	ser := txc.Serialize()
-	hsh := hash.HashH(ser)
-	return hsh
+	if tx.Signatures == nil {
+		return hash.HashH(tx.Serialize())
+	}
+	txCopy := tx.TrimmedCopy()
+	txCopy.Signatures = nil
+	return hash.HashH(txCopy.Serialize())
 }
 
 func (tx *Transaction) GetTxidString() string {
@@ -125,59 +167,119 @@ func (tx *Transaction) GetTxidString() string {
 	return txid.String()
 }
 
-// Sign signs each input of a Transaction
-// must match input's prev TX exists
+// HasValidValue checks that value is positive and finite, NaN and infinities can't be converted to balances
+func (tx *Transaction) HasValidValue() bool {
+	return tx.Value > 0 && !math.IsInf(tx.Value, 1)
+}
+
+// SetValidity sets heights of validity window and updates hash, it must be set before signing
+func (tx *Transaction) SetValidity(validAfter uint64, expiresAt uint64) {
+	tx.ValidAfterHeight = validAfter
+	tx.ExpiresAtHeight = expiresAt
+	tx.UpdateHash()
+}
+
+// IsValidYet checks that a block of height is after ValidAfterHeight
+func (tx *Transaction) IsValidYet(height uint64) bool {
+	return height > tx.ValidAfterHeight
+}
+
+// IsExpired checks if transaction has expired at height
+func (tx *Transaction) IsExpired(height uint64) bool {
+	return tx.ExpiresAtHeight != 0 && height >= tx.ExpiresAtHeight
+}
+
+// ValidAt checks that transaction can be included in a block of height
+func (tx *Transaction) ValidAt(height uint64) bool {
+	return tx.IsValidYet(height) && !tx.IsExpired(height)
+}
+
+// Sign signs hash of the transaction with a secp256k1 key, signature is deterministic (RFC6979)
 func (tx *Transaction) Sign(privateKey ecdsa.PrivateKey) {
 
-	txCopy := tx.TrimmedCopyToSign()
-	dataToSign := fmt.Sprintf("%x\n", txCopy)
-	r, s, err := ecdsa.Sign(rand.Reader, &privateKey, []byte(dataToSign))
-	if err != nil {
-		log.Panic(err)
-	}
-	signature := append(r.Bytes(), s.Bytes()...)
-	tx.Signature = signature
+	txHash := tx.CalcHash()
+	tx.Signature = signHash(&privateKey, txHash[:])
 
 }
 
-// Verify verifies signatures of Transaction inputs
-// use signature & rawPubKey on ecdsa.Verify
+// Verify verifies signature of Transaction and checks that recovered public key belongs to sender,
+// multisig transactions need valid signatures of threshold keys of sender.
+// Only multisig transactions are sent from multisig addresses
 func (tx *Transaction) VerifySignature() bool {
 
-	txCopy := tx.TrimmedCopyToSign()
-	curve := elliptic.P256()
+	if tx.Multisig != nil || len(tx.Signatures) != 0 || address.IsScriptAddress(tx.From) {
+		return tx.Multisig != nil && address.IsScriptAddress(tx.From) && tx.verifyMultisig()
+	}
+	pubKey, err := tx.SenderPublicKey()
+	if err != nil {
+		return false
+	}
+	return address.ToString(address.FromPublicKey(pubKey)) == tx.From
+}
 
-	r := big.Int{}
-	s := big.Int{}
-	sigLen := len(tx.Signature)
-	r.SetBytes(tx.Signature[:(sigLen / 2)])
-	s.SetBytes(tx.Signature[(sigLen / 2):])
+// SenderPublicKey recovers compressed public key of signer from signature
+func (tx *Transaction) SenderPublicKey() ([]byte, error) {
+	txHash := tx.CalcHash()
+	pub, err := recoverHash(tx.Signature, txHash[:])
+	if err != nil {
+		return nil, err
+	}
+	return pub.SerializeCompressed(), nil
+}
 
-	x := big.Int{}
-	y := big.Int{}
-	keyLen := len(tx.PublicKey)
-	x.SetBytes(tx.PublicKey[:(keyLen / 2)])
-	y.SetBytes(tx.PublicKey[(keyLen / 2):])
+// signHash signs a hash with RFC6979 nonce, signature is compact form of low-S signature
+func signHash(privateKey *ecdsa.PrivateKey, h []byte) []byte {
+	signature, err := btcec.SignCompact(btcec.S256(), (*btcec.PrivateKey)(privateKey), h, true)
+	if err != nil {
+		log.Panic(err)
+	}
+	return signature
+}
 
-	dataToVerify := fmt.Sprintf("%x\n", txCopy)
+// recoverHash recovers public key of a compact signature, only signatures of compressed keys with low s are accepted
+func recoverHash(signature []byte, h []byte) (*btcec.PublicKey, error) {
+	if len(signature) != SignatureLen {
+		return nil, fmt.Errorf("signature should be %d bytes", SignatureLen)
+	}
+	if new(big.Int).SetBytes(signature[33:]).Cmp(halfOrder) > 0 {
+		return nil, fmt.Errorf("signature s is not low")
+	}
+	pub, compressed, err := btcec.RecoverCompact(btcec.S256(), signature, h)
+	if err != nil {
+		return nil, err
+	}
+	if !compressed {
+		return nil, fmt.Errorf("signature is not of a compressed public key")
+	}
+	return pub, nil
+}
 
-	rawPubKey := ecdsa.PublicKey{Curve: curve, X: &x, Y: &y}
-	if ecdsa.Verify(&rawPubKey, []byte(dataToVerify), &r, &s) == false {
-		return false
+// parsePublicKey parses a compressed public key, it is nil if key is not valid
+func parsePublicKey(pubKey []byte) *btcec.PublicKey {
+	if len(pubKey) != btcec.PubKeyBytesLenCompressed {
+		return nil
+	}
+	pub, err := btcec.ParsePubKey(pubKey, btcec.S256())
+	if err != nil {
+		return nil
 	}
+	return pub
+}
 
-	return true
+// publicKeyBytes returns compressed public key
+func publicKeyBytes(pub *ecdsa.PublicKey) []byte {
+	return (*btcec.PublicKey)(pub).SerializeCompressed()
 }
 
 // TrimmedCopy creates a trimmed copy of Transaction to be used in signing
-// set sign & pubkey nil
+// set sign nil, multisig script is signed but its signatures are not
 func (tx *Transaction) TrimmedCopyToSign() Transaction {
-	txCopy := Transaction{*hash.ZeroHash(), tx.Nonce, []byte{}, []byte{}, tx.Timestamp, tx.From, tx.To, tx.Fee, tx.Value, tx.Data}
+	txCopy := Transaction{*hash.ZeroHash(), tx.ChainID, tx.Nonce, tx.ValidAfterHeight, tx.ExpiresAtHeight, []byte{}, tx.Timestamp, tx.From, tx.To, tx.Fee, tx.Value, tx.Data, tx.Multisig, nil}
 	return txCopy
 }
 
 func (tx *Transaction) TrimmedCopy() Transaction {
-	txCopy := Transaction{tx.ID, tx.Nonce, tx.PublicKey, tx.Signature, tx.Timestamp, tx.From, tx.To, tx.Fee, tx.Value, tx.Data}
+	txCopy := Transaction{tx.ID, tx.ChainID, tx.Nonce, tx.ValidAfterHeight, tx.ExpiresAtHeight, tx.Signature, tx.Timestamp, tx.From, tx.To, tx.Fee, tx.Value, tx.Data, tx.Multisig, tx.Signatures}
 	return txCopy
 }
 
@@ -193,7 +295,7 @@ func (tx *Transaction) UpdateHash() error {
 	return nil
 }
 
-// Hash calc and return the hash of the Transaction
+// Hash calc and return the hash of the Transaction which is signed, it is hash of canonical encoding without signatures
 func (tx *Transaction) CalcHash() hash.Hash {
 	txCopy := tx.TrimmedCopyToSign()
 	h := hash.HashH(txCopy.Serialize())
@@ -1,12 +1,259 @@
 package transaction
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"math"
+	"math/big"
 	"testing"
+
+	address "badcoin/src/helper/address"
+	codec "badcoin/src/helper/codec"
+	errors "badcoin/src/helper/error"
+
+	"github.com/btcsuite/btcd/btcec"
 )
 
+const testChainID = 1001
+
 func TestBlockchain(t *testing.T) {
 	tx := Transaction {
 		
 	}
 	t.Log(tx.String())
 }
+
+func TestSignature(t *testing.T) {
+	private, _ := ecdsa.GenerateKey(btcec.S256(), rand.Reader)
+	pubKey := publicKeyBytes(&private.PublicKey)
+
+	tx := NewTransaction(testChainID, pubKey, 1, "1BoatSLRHtKNngkdXEeobR76b53LETtpyT", 1.5, "memo")
+	tx.Sign(*private)
+	if !tx.VerifySignature() || len(tx.Signature) != SignatureLen {
+		t.Fatal("signature should be valid")
+	}
+	signature := tx.Signature
+	tx.Sign(*private)
+	if !bytes.Equal(signature, tx.Signature) {
+		t.Error("signature should be deterministic")
+	}
+	if recovered, err := tx.SenderPublicKey(); err != nil || !bytes.Equal(recovered, pubKey) {
+		t.Error("public key should be recovered from signature")
+	}
+
+	// transaction which is signed offline is restored with same nonce and timestamp
+	signed := NewSignedTransaction(tx.ChainID, tx.From, tx.Nonce, tx.Timestamp, tx.To, tx.Value, tx.Signature, tx.Data)
+	if !signed.VerifySignature() || signed.GetTxid() != tx.GetTxid() {
+		t.Error("restored signed transaction should be valid")
+	}
+
+	// high s of same signature is valid for ecdsa, but it is rejected so signatures are not malleable
+	highS := *tx
+	highS.Signature = append([]byte{}, tx.Signature...)
+	s := new(big.Int).SetBytes(highS.Signature[33:])
+	new(big.Int).Sub(btcec.S256().N, s).FillBytes(highS.Signature[33:])
+	highS.Signature[0] ^= 1
+	if highS.VerifySignature() {
+		t.Error("high s signature should be rejected")
+	}
+	truncated := *tx
+	truncated.Signature = tx.Signature[:64]
+	if truncated.VerifySignature() {
+		t.Error("signature should have fixed length")
+	}
+
+	tampered := *tx
+	tampered.Value = 100
+	if tampered.VerifySignature() {
+		t.Error("signature should cover value")
+	}
+	tampered = *tx
+	tampered.From = "1BoatSLRHtKNngkdXEeobR76b53LETtpyT"
+	if tampered.VerifySignature() {
+		t.Error("sender should match recovered public key")
+	}
+	tampered = *tx
+	tampered.ChainID = testChainID + 1
+	if tampered.VerifySignature() {
+		t.Error("signature should cover chain id, so it can't be replayed on other chains")
+	}
+	tampered = *tx
+	tampered.ExpiresAtHeight = 100
+	if tampered.VerifySignature() {
+		t.Error("signature should cover validity window")
+	}
+}
+
+func TestValidity(t *testing.T) {
+	tx := NewTransaction(testChainID, []byte{1, 2, 3}, 1, "1BoatSLRHtKNngkdXEeobR76b53LETtpyT", 1, "")
+	id := tx.ID
+	if !tx.ValidAt(1) || tx.IsExpired(1000000) {
+		t.Error("transaction without window should be always valid")
+	}
+	tx.SetValidity(10, 20)
+	if tx.ID == id {
+		t.Error("hash should be updated with validity window")
+	}
+	cases := map[uint64]bool{9: false, 10: false, 11: true, 19: true, 20: false, 21: false}
+	for height, valid := range cases {
+		if tx.ValidAt(height) != valid {
+			t.Error("validity at height ", height, " should be ", valid)
+		}
+	}
+	if !tx.IsExpired(20) || tx.IsValidYet(10) {
+		t.Error("transaction should be expired at ExpiresAtHeight and not valid at ValidAfterHeight")
+	}
+}
+
+func TestRawTx(t *testing.T) {
+	tx := NewTransaction(testChainID, []byte{1, 2, 3}, 1, "1BoatSLRHtKNngkdXEeobR76b53LETtpyT", 2, "memo")
+	raw := tx.EncodeRaw()
+	decoded, err := DecodeRawTx(raw)
+	if err != nil || decoded.ID != tx.ID || decoded.Timestamp != tx.Timestamp {
+		t.Error("hex raw transaction round trip failed")
+	}
+	decoded, err = DecodeRawTx(base64.StdEncoding.EncodeToString(tx.Serialize()))
+	if err != nil || decoded.GetTxid() != tx.GetTxid() {
+		t.Error("base64 raw transaction round trip failed")
+	}
+	if _, err := DecodeRawTx("not a tx"); err == nil {
+		t.Error("invalid raw transaction should fail")
+	}
+}
+
+func TestMultisig(t *testing.T) {
+	var keys []*ecdsa.PrivateKey
+	var pubKeys [][]byte
+	for i := 0; i < 3; i++ {
+		private, _ := ecdsa.GenerateKey(btcec.S256(), rand.Reader)
+		keys = append(keys, private)
+		pubKeys = append(pubKeys, publicKeyBytes(&private.PublicKey))
+	}
+	ms, err := NewMultisig(2, pubKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reversed, _ := NewMultisig(2, [][]byte{pubKeys[2], pubKeys[1], pubKeys[0]})
+	if ms.Address() != reversed.Address() || !address.IsScriptAddress(ms.Address()) {
+		t.Error("multisig address should not depend on key order")
+	}
+	if _, err := NewMultisig(4, pubKeys); err != errors.InvalidMultisig {
+		t.Error("threshold should not be more than keys")
+	}
+	if _, err := NewMultisig(1, [][]byte{pubKeys[0], pubKeys[0]}); err != errors.InvalidMultisig {
+		t.Error("duplicate keys should fail")
+	}
+
+	tx := NewMultisigTransaction(testChainID, ms, 1, "1BoatSLRHtKNngkdXEeobR76b53LETtpyT", 10, "treasury")
+	other := *tx
+	other.Signatures = make([][]byte, 3)
+	tx.SignMultisig(*keys[0])
+	if tx.VerifySignature() {
+		t.Error("one signature should not reach threshold")
+	}
+	other.SignMultisig(*keys[2])
+	if err := tx.CombineSignatures(&other); err != nil {
+		t.Fatal(err)
+	}
+	if !tx.VerifySignature() || tx.SignatureCount() != 2 {
+		t.Error("combined signatures should be valid")
+	}
+	// txid doesn't depend on keys which have signed
+	resigned := *tx
+	resigned.Signatures = make([][]byte, 3)
+	resigned.SignMultisig(*keys[0])
+	resigned.SignMultisig(*keys[1])
+	if !resigned.VerifySignature() || resigned.GetTxid() != tx.GetTxid() {
+		t.Error("multisig transaction signed by other keys should have the same txid")
+	}
+	outsider, _ := ecdsa.GenerateKey(btcec.S256(), rand.Reader)
+	if err := tx.SignMultisig(*outsider); err != errors.NotMultisigKey {
+		t.Error("outsider key should not sign")
+	}
+
+	tampered := *tx
+	tampered.Value = 100
+	if tampered.VerifySignature() {
+		t.Error("signatures should cover value")
+	}
+	tampered = *tx
+	tampered.Signatures = [][]byte{tx.Signatures[0], []byte{1, 2}, tx.Signatures[2]}
+	if tampered.VerifySignature() {
+		t.Error("invalid signature should fail even if threshold is reached")
+	}
+	tampered = *tx
+	tampered.Value = 100
+	if err := tx.CombineSignatures(&tampered); err != errors.MultisigTxMismatch {
+		t.Error("signatures of another transaction should not be combined")
+	}
+
+	// multisig address only sends multisig transactions
+	single := NewTransaction(testChainID, publicKeyBytes(&keys[0].PublicKey), 1, "1BoatSLRHtKNngkdXEeobR76b53LETtpyT", 10, "")
+	single.From = ms.Address()
+	single.Sign(*keys[0])
+	if single.VerifySignature() {
+		t.Error("transaction of multisig address without multisig should be rejected")
+	}
+	tampered = *tx
+	tampered.From = "1BoatSLRHtKNngkdXEeobR76b53LETtpyT"
+	if tampered.VerifySignature() {
+		t.Error("multisig transaction should be sent from multisig address")
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	tx := &Transaction{ChainID: testChainID, Nonce: 1, Timestamp: 1700000000000, From: "1From", To: "1To", Value: 1.5, Data: "memo"}
+	tx.UpdateHash()
+	// version, chain id, nonce, valid after, expires at, signature, timestamp, from, to, fee, value, data, multisig, signatures
+	want := "8e" + "03" + "1903e9" + "01" + "00" + "00" + "40" + "1b0000018bcfe56800" + "653146726f6d" + "6331546f" + "00" + "fb3ff8000000000000" + "646d656d6f" + "f6" + "80"
+	if hex.EncodeToString(tx.Serialize()) != want {
+		t.Fatal("unexpected encoding ", hex.EncodeToString(tx.Serialize()))
+	}
+
+	private, _ := ecdsa.GenerateKey(btcec.S256(), rand.Reader)
+	signed := NewTransaction(testChainID, publicKeyBytes(&private.PublicKey), 7, "1BoatSLRHtKNngkdXEeobR76b53LETtpyT", 0.1, "")
+	signed.Sign(*private)
+	ms, _ := NewMultisig(1, [][]byte{publicKeyBytes(&private.PublicKey)})
+	multisig := NewMultisigTransaction(testChainID, ms, 2, "1BoatSLRHtKNngkdXEeobR76b53LETtpyT", 3, "treasury")
+	multisig.SignMultisig(*private)
+
+	for _, tx := range []*Transaction{tx, signed, multisig} {
+		data := tx.Serialize()
+		decoded, err := DeserializeTx(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded.ID != tx.ID || decoded.GetTxid() != tx.GetTxid() || !bytes.Equal(decoded.Serialize(), data) {
+			t.Error("transaction round trip failed")
+		}
+		if len(tx.Signature) > 0 && !decoded.VerifySignature() {
+			t.Error("decoded transaction should be valid")
+		}
+		if _, err := DeserializeTx(append(data, 0)); err == nil {
+			t.Error("trailing data should be rejected")
+		}
+		if _, err := DeserializeTx(data[:len(data)-1]); err == nil {
+			t.Error("truncated data should be rejected")
+		}
+	}
+	if decoded, _ := DeserializeTx(multisig.Serialize()); !decoded.VerifySignature() || decoded.SignatureCount() != 1 {
+		t.Error("multisig signatures should be decoded")
+	}
+
+	for _, value := range []float64{math.NaN(), math.Inf(1), math.Inf(-1), math.Copysign(0, -1), 0, -1} {
+		invalid := *tx
+		invalid.Value = value
+		if _, err := DeserializeTx(invalid.Serialize()); err != errors.InvalidTxValue {
+			t.Error("transaction with value ", value, " should be rejected")
+		}
+	}
+
+	data := tx.Serialize()
+	data[1] = 1
+	if _, err := DeserializeTx(data); err != codec.ErrVersion {
+		t.Error("old version should be rejected")
+	}
+}
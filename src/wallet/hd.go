@@ -0,0 +1,154 @@
+package wallet
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec"
+	errors "github.com/pkg/errors"
+	bip39 "github.com/tyler-smith/go-bip39"
+)
+
+var (
+	ErrorInvalidMnemonic = errors.New("invalid mnemonic")
+	ErrorInvalidPath     = errors.New("invalid derivation path")
+)
+
+// HardenedOffset is added to index of hardened children
+const HardenedOffset uint32 = 0x80000000
+
+// CoinType is the BIP44 coin type of addresses, it is the testnet coin type for now
+const CoinType uint32 = 1
+
+// mnemonic of new wallets has 256 bits entropy (24 words)
+const mnemonicEntropyBits = 256
+
+// HMAC key of BIP32 master key, keys are derived on secp256k1 curve
+var masterHMACKey = []byte("Bitcoin seed")
+
+// ExtendedKey is a private key with its chain code
+type ExtendedKey struct {
+	Key       []byte
+	ChainCode []byte
+}
+
+// NewMnemonic generates a new mnemonic phrase
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(mnemonicEntropyBits)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// SeedFromMnemonic validates mnemonic and returns its seed, password is the optional BIP39 passphrase
+func SeedFromMnemonic(mnemonic string, password string) ([]byte, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return nil, ErrorInvalidMnemonic
+	}
+	return bip39.NewSeed(mnemonic, password), nil
+}
+
+// NewMasterKey returns master key of a seed
+func NewMasterKey(seed []byte) *ExtendedKey {
+	n := btcec.S256().N
+	data := seed
+	for {
+		mac := hmac.New(sha512.New, masterHMACKey)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+		key := new(big.Int).SetBytes(sum[:32])
+		if key.Sign() != 0 && key.Cmp(n) < 0 {
+			return &ExtendedKey{Key: sum[:32], ChainCode: sum[32:]}
+		}
+		data = sum
+	}
+}
+
+// Child derives a child private key, index >= HardenedOffset is a hardened child
+func (k *ExtendedKey) Child(index uint32) *ExtendedKey {
+	n := btcec.S256().N
+	parent := new(big.Int).SetBytes(k.Key)
+
+	var data []byte
+	if index >= HardenedOffset {
+		data = append([]byte{0x00}, k.Key...)
+	} else {
+		_, pub := btcec.PrivKeyFromBytes(btcec.S256(), k.Key)
+		data = pub.SerializeCompressed()
+	}
+	data = append(data, uint32Bytes(index)...)
+
+	for {
+		mac := hmac.New(sha512.New, k.ChainCode)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+		il := new(big.Int).SetBytes(sum[:32])
+		child := new(big.Int).Add(il, parent)
+		child.Mod(child, n)
+		if il.Cmp(n) < 0 && child.Sign() != 0 {
+			return &ExtendedKey{Key: child.FillBytes(make([]byte, 32)), ChainCode: sum[32:]}
+		}
+		// invalid key, derivation is repeated with right half (SLIP-10)
+		data = append(append([]byte{0x01}, sum[32:]...), uint32Bytes(index)...)
+	}
+}
+
+// Derive derives key of a path like m/44'/1'/0'/0/5
+func (k *ExtendedKey) Derive(path string) (*ExtendedKey, error) {
+	indexes, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	key := k
+	for _, index := range indexes {
+		key = key.Child(index)
+	}
+	return key, nil
+}
+
+// Wallet returns wallet of extended private key
+func (k *ExtendedKey) Wallet() (*Wallet, error) {
+	return NewWalletFromPrivateKey(k.Key)
+}
+
+// HDPath returns BIP44 path of an address, m/44'/coin'/account'/0/index
+func HDPath(account uint32, index uint32) string {
+	return fmt.Sprintf("m/44'/%d'/%d'/0/%d", CoinType, account, index)
+}
+
+// ParsePath parses a derivation path, ' or h marks hardened indexes
+func ParsePath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if len(parts) == 0 || parts[0] != "m" {
+		return nil, ErrorInvalidPath
+	}
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h")
+		if hardened {
+			part = part[:len(part)-1]
+		}
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || uint32(index) >= HardenedOffset {
+			return nil, ErrorInvalidPath
+		}
+		if hardened {
+			index += uint64(HardenedOffset)
+		}
+		indexes = append(indexes, uint32(index))
+	}
+	return indexes, nil
+}
+
+func uint32Bytes(i uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, i)
+	return b
+}
@@ -0,0 +1,113 @@
+package wallet
+
+import (
+	"sort"
+)
+
+// directions of wallet history entries
+const (
+	TxSent     = "sent"
+	TxReceived = "received"
+	TxReward   = "reward"
+	TxGenesis  = "genesis" // premined balance of genesis block
+)
+
+// WalletTx is an entry of local wallet history, Txid is empty for rewards and genesis balances
+type WalletTx struct {
+	Txid      string `json:",omitempty"`
+	Direction string
+	Value     float64
+	From      string `json:",omitempty"`
+	To        string
+	Nonce     uint64 `json:",omitempty"`
+	Height    uint64
+	BlockHash string
+	Index     int
+	Timestamp int64
+}
+
+// ScannedHeight returns height up to which wallet history is rebuilt from chain, ok is false if wallet is never scanned
+func (ws *WalletSet) ScannedHeight() (height uint64, ok bool) {
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+	if ws.scannedHeight == nil {
+		return 0, false
+	}
+	return *ws.scannedHeight, true
+}
+
+// AccountHistory is balance, last nonce and history of an address which are found by rescan
+type AccountHistory struct {
+	Address   string
+	WatchOnly bool
+	Balance   float64
+	Nonce     uint64
+	History   []*WalletTx
+}
+
+// AccountHistory returns balance, nonce and history of an address, oldest first
+func (ws *WalletSet) AccountHistory(address string) (*AccountHistory, error) {
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+
+	wallet := ws.Wallets[address]
+	if wallet == nil {
+		return nil, ErrorUnknownAddress
+	}
+	return &AccountHistory{
+		Address:   address,
+		WatchOnly: wallet.WatchOnly,
+		Balance:   wallet.Balance,
+		Nonce:     wallet.Nonce,
+		History:   append([]*WalletTx{}, wallet.History...),
+	}, nil
+}
+
+// ApplyRescan replaces history of scanned addresses from height with entries found in chain blocks up to height to,
+// then balances and nonces of wallets are calculated again from their history.
+// Wallets which are added while rescan is running are not changed
+func (ws *WalletSet) ApplyRescan(from uint64, to uint64, scanned []string, found map[string][]*WalletTx) error {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	for _, address := range scanned {
+		wallet := ws.Wallets[address]
+		if wallet == nil {
+			continue
+		}
+		var history []*WalletTx
+		for _, entry := range wallet.History {
+			if entry.Height < from {
+				history = append(history, entry)
+			}
+		}
+		history = append(history, found[address]...)
+		sort.SliceStable(history, func(i, j int) bool {
+			if history[i].Height != history[j].Height {
+				return history[i].Height < history[j].Height
+			}
+			return history[i].Index < history[j].Index
+		})
+		wallet.History = history
+		wallet.calcBalance()
+	}
+	ws.scannedHeight = &to
+	return ws.saveToFile()
+}
+
+// calcBalance sets balance and last nonce of wallet from its history
+func (w *Wallet) calcBalance() {
+	w.Balance = 0
+	w.Nonce = 0
+	for _, entry := range w.History {
+		switch entry.Direction {
+		case TxSent:
+			w.Balance -= entry.Value
+			if entry.Nonce > w.Nonce {
+				w.Nonce = entry.Nonce
+			}
+		default:
+			w.Balance += entry.Value
+		}
+	}
+}
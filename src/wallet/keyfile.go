@@ -0,0 +1,148 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+
+	"badcoin/src/helper/base58"
+
+	"github.com/btcsuite/btcd/btcec"
+	errors "github.com/pkg/errors"
+)
+
+var (
+	ErrorInvalidPrivateKey  = errors.New("invalid private key")
+	ErrorKeyFileMismatch    = errors.New("key file address doesn't match its private key")
+	ErrorInvalidKeyEncoding = errors.New("encoded private key is not valid or its checksum doesn't match")
+	ErrorLegacyKey          = errors.New("key is a P-256 key of an old wallet, it can't sign transactions")
+)
+
+// version of encoded private keys, it differs from bitcoin WIF (0x80),
+// so keys of other chains are not imported by mistake
+const privateKeyVersion = byte(0xbd)
+
+// KeyFile is a single key which is kept out of node, e.g. in a cold wallet
+type KeyFile struct {
+	Address    string
+	PublicKey  []byte
+	PrivateKey []byte
+}
+
+// NewWalletFromPrivateKey restores a wallet from secp256k1 private key bytes
+func NewWalletFromPrivateKey(privateKey []byte) (*Wallet, error) {
+	return restoreKey(btcec.S256(), privateKey)
+}
+
+// legacyWalletFromPrivateKey restores a wallet of old P-256 private key, its public key isn't compressed
+func legacyWalletFromPrivateKey(privateKey []byte) (*Wallet, error) {
+	w, err := restoreKey(elliptic.P256(), privateKey)
+	if err != nil {
+		return nil, err
+	}
+	size := (w.PrivateKey.Curve.Params().BitSize + 7) / 8
+	w.PublicKey = make([]byte, 2*size)
+	w.PrivateKey.X.FillBytes(w.PublicKey[:size])
+	w.PrivateKey.Y.FillBytes(w.PublicKey[size:])
+	return w, nil
+}
+
+// walletFromPrivateKey restores wallet of a private key, it is a legacy P-256 key if its public key isn't compressed
+func walletFromPrivateKey(privateKey []byte, publicKey []byte) (*Wallet, error) {
+	if (&Wallet{PublicKey: publicKey}).IsLegacy() {
+		return legacyWalletFromPrivateKey(privateKey)
+	}
+	return NewWalletFromPrivateKey(privateKey)
+}
+
+func restoreKey(curve elliptic.Curve, privateKey []byte) (*Wallet, error) {
+	d := new(big.Int).SetBytes(privateKey)
+	if len(privateKey) != (curve.Params().BitSize+7)/8 || d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+		return nil, ErrorInvalidPrivateKey
+	}
+	private := ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{Curve: curve},
+		D:         d,
+	}
+	private.PublicKey.X, private.PublicKey.Y = curve.ScalarBaseMult(privateKey)
+
+	return &Wallet{PrivateKey: private, PublicKey: publicKeyBytes(&private.PublicKey)}, nil
+}
+
+// KeyFile returns key file of wallet
+func (w *Wallet) KeyFile() *KeyFile {
+	return &KeyFile{
+		Address:    w.GetStringAddress(),
+		PublicKey:  w.PublicKey,
+		PrivateKey: w.privateKeyBytes(),
+	}
+}
+
+// Wallet restores wallet of key file and checks its address, P-256 keys of old key files are rejected
+func (kf *KeyFile) Wallet() (*Wallet, error) {
+	if (&Wallet{PublicKey: kf.PublicKey}).IsLegacy() {
+		return nil, ErrorLegacyKey
+	}
+	w, err := NewWalletFromPrivateKey(kf.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	if kf.Address != "" && kf.Address != w.GetStringAddress() {
+		return nil, ErrorKeyFileMismatch
+	}
+	return w, nil
+}
+
+// EncodePrivateKey encodes a private key with base58, version and checksum are added like addresses
+func EncodePrivateKey(privateKey []byte) string {
+	versionedPayload := append([]byte{privateKeyVersion}, privateKey...)
+	return base58.Encode(append(versionedPayload, checksum(versionedPayload)...))
+}
+
+// DecodePrivateKey decodes a private key and checks its version and checksum
+func DecodePrivateKey(encoded string) ([]byte, error) {
+	payload := base58.Decode(encoded)
+	if len(payload) <= 1+addressChecksumLen || payload[0] != privateKeyVersion {
+		return nil, ErrorInvalidKeyEncoding
+	}
+	versionedPayload := payload[:len(payload)-addressChecksumLen]
+	if !bytes.Equal(checksum(versionedPayload), payload[len(versionedPayload):]) {
+		return nil, ErrorInvalidKeyEncoding
+	}
+	return versionedPayload[1:], nil
+}
+
+// SaveKeyFile writes key file of wallet, it is only readable by owner.
+// Existing files are not overwritten
+func SaveKeyFile(path string, w *Wallet) error {
+	data, err := json.MarshalIndent(w.KeyFile(), "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadKeyFile reads a key file and restores its wallet
+func LoadKeyFile(path string) (*Wallet, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var kf KeyFile
+	if err := json.Unmarshal(data, &kf); err != nil {
+		return nil, err
+	}
+	return kf.Wallet()
+}
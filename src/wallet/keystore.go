@@ -0,0 +1,141 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+
+	errors "github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+var (
+	ErrorWrongPassphrase = errors.New("wrong wallet passphrase")
+	ErrorEmptyPassphrase = errors.New("wallet passphrase is empty")
+)
+
+// scrypt parameters of passphrase key derivation
+const (
+	kdfScrypt  = "scrypt"
+	scryptN    = 1 << 15
+	scryptR    = 8
+	scryptP    = 1
+	keyLen     = 32
+	saltLen    = 32
+	cipherName = "aes-256-gcm"
+)
+
+// private keys are authenticated with file version, so an encrypted payload can't be moved to another format
+var keystoreAD = []byte("badcoin-wallet-v1")
+
+// walletCrypto is the encrypted private keys of wallet file with parameters to decrypt them
+type walletCrypto struct {
+	KDF        string
+	N          int
+	R          int
+	P          int
+	Salt       []byte
+	Cipher     string
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+// newWalletCrypto creates crypto params with a random salt, derived key is returned too
+func newWalletCrypto(passphrase string) (*walletCrypto, []byte, error) {
+	if passphrase == "" {
+		return nil, nil, ErrorEmptyPassphrase
+	}
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, err
+	}
+	wc := &walletCrypto{
+		KDF:    kdfScrypt,
+		N:      scryptN,
+		R:      scryptR,
+		P:      scryptP,
+		Salt:   salt,
+		Cipher: cipherName,
+	}
+	key, err := wc.deriveKey(passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	return wc, key, nil
+}
+
+// deriveKey derives encryption key from passphrase
+func (wc *walletCrypto) deriveKey(passphrase string) ([]byte, error) {
+	if wc.KDF != kdfScrypt {
+		return nil, errors.New("unsupported wallet kdf " + wc.KDF)
+	}
+	return scrypt.Key([]byte(passphrase), wc.Salt, wc.N, wc.R, wc.P, keyLen)
+}
+
+func (wc *walletCrypto) aead(key []byte) (cipher.AEAD, error) {
+	if wc.Cipher != cipherName {
+		return nil, errors.New("unsupported wallet cipher " + wc.Cipher)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// walletSecrets is the encrypted payload of wallet file
+type walletSecrets struct {
+	PrivateKeys map[string][]byte
+	Mnemonic    string `json:",omitempty"`
+}
+
+// seal encrypts wallet secrets with a fresh nonce
+func (wc *walletCrypto) seal(key []byte, secrets *walletSecrets) error {
+	aead, err := wc.aead(key)
+	if err != nil {
+		return err
+	}
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	defer zero(plaintext)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	wc.Nonce = nonce
+	wc.Ciphertext = aead.Seal(nil, nonce, plaintext, keystoreAD)
+	return nil
+}
+
+// open decrypts wallet secrets, authentication failure means passphrase is wrong
+func (wc *walletCrypto) open(key []byte) (*walletSecrets, error) {
+	aead, err := wc.aead(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, wc.Nonce, wc.Ciphertext, keystoreAD)
+	if err != nil {
+		return nil, ErrorWrongPassphrase
+	}
+	defer zero(plaintext)
+	var secrets walletSecrets
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, err
+	}
+	if secrets.PrivateKeys == nil {
+		// first encrypted wallets only kept private keys by address
+		if err := json.Unmarshal(plaintext, &secrets.PrivateKeys); err != nil {
+			return nil, err
+		}
+	}
+	return &secrets, nil
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,96 @@
+// Package scan finds history of wallet addresses in chain blocks.
+// It is separate from wallet package, as blocks depend on packages which are tested with wallets
+package scan
+
+import (
+	block "badcoin/src/block"
+	config "badcoin/src/config"
+	errors "badcoin/src/helper/error"
+	wallet "badcoin/src/wallet"
+)
+
+// Rescan rebuilds history of addresses of ws from blocks from..to which are loaded by getBlock,
+// alloc is premined balances of genesis block and progress is called with found entries of each block
+func Rescan(ws *wallet.WalletSet, from uint64, to uint64, getBlock func(uint64) (*block.Block, error), alloc []config.GenesisAlloc, progress func(int)) error {
+	scanned := ws.GetAddresses()
+	addresses := make(map[string]bool)
+	for _, address := range scanned {
+		addresses[address] = true
+	}
+	found := make(map[string][]*wallet.WalletTx)
+
+	for height := from; height <= to; height++ {
+		blk, err := getBlock(height)
+		if err != nil {
+			return err
+		}
+		if blk == nil {
+			return errors.BlockNotFount
+		}
+		count := Block(blk, alloc, addresses, found)
+		if progress != nil {
+			progress(count)
+		}
+	}
+	return ws.ApplyRescan(from, to, scanned, found)
+}
+
+// Block adds transactions, reward and genesis balances of addresses in block to found,
+// it returns number of added entries
+func Block(blk *block.Block, alloc []config.GenesisAlloc, addresses map[string]bool, found map[string][]*wallet.WalletTx) int {
+	count := 0
+	blkhash := blk.GetHash().String()
+	add := func(address string, entry wallet.WalletTx) {
+		if addresses[address] {
+			found[address] = append(found[address], &entry)
+			count++
+		}
+	}
+
+	if blk.Height == 0 {
+		for i, a := range alloc {
+			add(a.Address, wallet.WalletTx{
+				Direction: wallet.TxGenesis,
+				Value:     a.Balance,
+				To:        a.Address,
+				BlockHash: blkhash,
+				Index:     i,
+				Timestamp: blk.Header.Timestamp,
+			})
+		}
+	}
+	for i, tx := range blk.Transactions {
+		entry := wallet.WalletTx{
+			Txid:      tx.GetTxidString(),
+			Value:     tx.Value,
+			From:      tx.From,
+			To:        tx.To,
+			Nonce:     tx.Nonce,
+			Height:    blk.Height,
+			BlockHash: blkhash,
+			Index:     i,
+			Timestamp: tx.Timestamp,
+		}
+		sent := entry
+		sent.Direction = wallet.TxSent
+		add(tx.From, sent)
+		received := entry
+		received.Direction = wallet.TxReceived
+		add(tx.To, received)
+	}
+	if blk.Reward != nil && blk.Header.Miner != "" {
+		reward, _ := blk.Reward.Float64()
+		if reward > 0 {
+			add(blk.Header.Miner, wallet.WalletTx{
+				Direction: wallet.TxReward,
+				Value:     reward,
+				To:        blk.Header.Miner,
+				Height:    blk.Height,
+				BlockHash: blkhash,
+				Index:     len(blk.Transactions),
+				Timestamp: blk.Header.Timestamp,
+			})
+		}
+	}
+	return count
+}
@@ -0,0 +1,67 @@
+package scan
+
+import (
+	"math/big"
+	"os"
+	"testing"
+
+	block "badcoin/src/block"
+	config "badcoin/src/config"
+	errors "badcoin/src/helper/error"
+	transaction "badcoin/src/transaction"
+	wallet "badcoin/src/wallet"
+)
+
+func TestRescan(t *testing.T) {
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(t.TempDir())
+	os.Mkdir("data", 0700)
+
+	ws, _ := wallet.LoadWallets("scan")
+	wal, _ := ws.CreateWallet()
+	addr := wal.GetStringAddress()
+	other := wallet.NewWallet()
+
+	alloc := []config.GenesisAlloc{{Address: addr, Balance: 100}, {Address: other.GetStringAddress(), Balance: 10}}
+	sent := transaction.NewTransaction(1001, wal.PublicKey, 1, other.GetStringAddress(), 30, "")
+	received := transaction.NewTransaction(1001, other.PublicKey, 1, addr, 5, "")
+	blocks := []*block.Block{
+		{Height: 0, Reward: big.NewFloat(0)},
+		{Height: 1, Header: block.BlockHeader{Miner: addr}, Reward: big.NewFloat(50), Transactions: []*transaction.Transaction{sent, received}},
+		{Height: 2, Header: block.BlockHeader{Miner: other.GetStringAddress()}, Reward: big.NewFloat(50)},
+	}
+
+	found := make(map[string][]*wallet.WalletTx)
+	if count := Block(blocks[1], alloc, map[string]bool{addr: true}, found); count != 3 {
+		t.Error("sent, received and reward entries should be found, found: ", count)
+	}
+	directions := []string{wallet.TxSent, wallet.TxReceived, wallet.TxReward}
+	for i, entry := range found[addr] {
+		if entry.Direction != directions[i] || entry.Height != 1 || entry.Index != i {
+			t.Errorf("wrong entry %d: %+v", i, entry)
+		}
+	}
+
+	var scanned int
+	getBlock := func(height uint64) (*block.Block, error) {
+		// like chain, unknown height is not an error
+		if height >= uint64(len(blocks)) {
+			return nil, nil
+		}
+		return blocks[height], nil
+	}
+	if err := Rescan(ws, 0, 2, getBlock, alloc, func(int) { scanned++ }); err != nil {
+		t.Fatal(err)
+	}
+	account, _ := ws.AccountHistory(addr)
+	if scanned != 3 || len(account.History) != 4 || account.History[0].Direction != wallet.TxGenesis {
+		t.Errorf("wrong rescan history: %+v", account)
+	}
+	if account.Balance != 125 || account.Nonce != 1 {
+		t.Errorf("wrong rescan balance: %+v", account)
+	}
+	if err := Rescan(ws, 0, 3, getBlock, alloc, nil); err != errors.BlockNotFount {
+		t.Error("rescan should fail when a block can't be loaded")
+	}
+}
@@ -3,14 +3,15 @@ package wallet
 import (
 	"bytes"
 	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/gob"
 	"log"
 
 	"badcoin/src/helper/base58"
 	"fmt"
 
+	"github.com/btcsuite/btcd/btcec"
 	"golang.org/x/crypto/ripemd160"
 )
 
@@ -18,17 +19,21 @@ import (
 const version = byte(0x00)
 const addressChecksumLen = 4
 
-// Wallet stores private and public keys
+// Wallet stores private and public keys, keys are secp256k1 keys and public key is compressed
 type Wallet struct {
 	PrivateKey ecdsa.PrivateKey
 	PublicKey  []byte
 	Nonce      uint64
+	Path       string      // derivation path of HD wallet keys
+	WatchOnly  bool        // only address is known, it can't sign
+	Balance    float64     // balance found by last rescan
+	History    []*WalletTx // transactions found by last rescan, oldest first
 }
 
 // newWallet creates and returns a Wallet
 func NewWallet() *Wallet {
 	private, public := newKeyPair()
-	wallet := Wallet{private, public, uint64(0)}
+	wallet := Wallet{PrivateKey: private, PublicKey: public}
 
 	return &wallet
 }
@@ -79,6 +84,9 @@ func HashPublicKey(pubKey []byte) []byte {
 // 5.get checksum，use first 4 bytes
 func ValidateAddress(address string) bool {
 	pubKeyHash := base58.Decode(address)
+	if len(pubKeyHash) <= addressChecksumLen {
+		return false
+	}
 	actualChecksum := pubKeyHash[len(pubKeyHash)-addressChecksumLen:]
 	version := pubKeyHash[0]
 	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-addressChecksumLen]
@@ -104,24 +112,82 @@ func checksum(payload []byte) []byte {
 	return secondSHA[:addressChecksumLen]
 }
 
-// newKeyPair create private&public key with ecdsa and rand-key
+// newKeyPair create secp256k1 private&public key with ecdsa and rand-key
 func newKeyPair() (ecdsa.PrivateKey, []byte) {
-	curve := elliptic.P256()
-	private, err := ecdsa.GenerateKey(curve, rand.Reader)
+	private, err := ecdsa.GenerateKey(btcec.S256(), rand.Reader)
 	if err != nil {
 		log.Panic(err)
 	}
-	pubKey := append(private.PublicKey.X.Bytes(), private.PublicKey.Y.Bytes()...)
 
-	return *private, pubKey
+	return *private, publicKeyBytes(&private.PublicKey)
+}
+
+// publicKeyBytes returns compressed public key
+func publicKeyBytes(pub *ecdsa.PublicKey) []byte {
+	return (*btcec.PublicKey)(pub).SerializeCompressed()
+}
+
+// IsLegacy reports if wallet has a P-256 key of old wallets, public keys of those keys are not compressed.
+// Legacy keys can't sign transactions
+func (w *Wallet) IsLegacy() bool {
+	return len(w.PublicKey) > 0 && len(w.PublicKey) != btcec.PubKeyBytesLenCompressed
+}
+
+// walletGob is gob form of wallet, curve of private key can't be gob encoded so only its bytes are kept
+type walletGob struct {
+	PrivateKey []byte
+	PublicKey  []byte
+	Nonce      uint64
+	Path       string
+}
+
+// GobEncode encodes wallet with private key bytes
+func (w *Wallet) GobEncode() ([]byte, error) {
+	var content bytes.Buffer
+	err := gob.NewEncoder(&content).Encode(&walletGob{w.privateKeyBytes(), w.PublicKey, w.Nonce, w.Path})
+	return content.Bytes(), err
+}
+
+// GobDecode decodes wallet and restores its private key
+func (w *Wallet) GobDecode(data []byte) error {
+	var wg walletGob
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&wg); err != nil {
+		return err
+	}
+	w.PublicKey = wg.PublicKey
+	w.Nonce = wg.Nonce
+	w.Path = wg.Path
+	if len(wg.PrivateKey) == 0 {
+		return nil
+	}
+	restored, err := walletFromPrivateKey(wg.PrivateKey, wg.PublicKey)
+	if err != nil {
+		return err
+	}
+	w.PrivateKey = restored.PrivateKey
+	return nil
+}
+
+// HasPrivateKey reports if private key of wallet is available, it is removed while wallet is locked
+func (w *Wallet) HasPrivateKey() bool {
+	return w.PrivateKey.D != nil
+}
+
+// privateKeyBytes returns private key padded to curve size, it is nil if private key is not available
+func (w *Wallet) privateKeyBytes() []byte {
+	if !w.HasPrivateKey() {
+		return nil
+	}
+	size := (w.PrivateKey.Curve.Params().BitSize + 7) / 8
+	return w.PrivateKey.D.FillBytes(make([]byte, size))
 }
 
-func (wallet *Wallet) GetNewAddress() string {
-	prv, pub := newKeyPair()
-	wallet.PrivateKey = prv
-	wallet.PublicKey = pub
-	wallet.Nonce = 0
-	return wallet.GetStringAddress()
+// clearPrivateKey zeroes and removes private key
+func (w *Wallet) clearPrivateKey() {
+	if w.PrivateKey.D != nil {
+		w.PrivateKey.D.SetInt64(0)
+		w.PrivateKey.D = nil
+	}
 }
 
 func (wallet *Wallet) AddNonce() uint64 {
@@ -131,4 +197,4 @@ func (wallet *Wallet) AddNonce() uint64 {
 
 func (wallet *Wallet) SetNonce(nonce uint64) {
 	wallet.Nonce = nonce
-}
\ No newline at end of file
+}
@@ -2,14 +2,23 @@ package wallet
 
 import (
 	"bytes"
+	"crypto/ecdsa"
 	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/gob"
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"testing"
+	"time"
+
+	"badcoin/src/helper/base58"
 )
 
 func TestToAddress(t *testing.T) {
@@ -63,3 +72,307 @@ func existFile(filename string) bool {
 	_, err := os.Stat(filename)
 	return err == nil || os.IsExist(err)
 }
+
+func TestKeyFile(t *testing.T) {
+	w := NewWallet()
+	path := filepath.Join(t.TempDir(), "key.json")
+	if err := SaveKeyFile(path, w); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveKeyFile(path, w); err == nil {
+		t.Error("existing key file should not be overwritten")
+	}
+	loaded, err := LoadKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.GetStringAddress() != w.GetStringAddress() || !bytes.Equal(loaded.PublicKey, w.PublicKey) {
+		t.Error("restored wallet doesn't match")
+	}
+
+	kf := w.KeyFile()
+	kf.Address = NewWallet().GetStringAddress()
+	if _, err := kf.Wallet(); err != ErrorKeyFileMismatch {
+		t.Error("address mismatch should be detected")
+	}
+	kf = w.KeyFile()
+	kf.Address = ""
+	kf.PublicKey = make([]byte, 64)
+	if _, err := kf.Wallet(); err != ErrorLegacyKey {
+		t.Error("P-256 key file should be rejected")
+	}
+	if _, err := NewWalletFromPrivateKey([]byte{1}); err != ErrorInvalidPrivateKey {
+		t.Error("short private key should be rejected")
+	}
+}
+
+func TestWalletEncryption(t *testing.T) {
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(t.TempDir())
+	os.Mkdir("data", 0700)
+
+	// old gob wallet files are loaded as plaintext wallets, their P-256 keys can't sign
+	private, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	old, _ := legacyWalletFromPrivateKey(private.D.FillBytes(make([]byte, 32)))
+	legacy := legacyWalletSet{MinerAddress: old.GetStringAddress(), Wallets: map[string]*legacyWallet{}}
+	lw := &legacyWallet{PublicKey: old.PublicKey, Nonce: 3}
+	lw.PrivateKey.D = old.PrivateKey.D
+	legacy.Wallets[old.GetStringAddress()] = lw
+	var content bytes.Buffer
+	gob.NewEncoder(&content).Encode(&legacy)
+	ioutil.WriteFile("data/"+getWalletFileName("test"), content.Bytes(), 0600)
+
+	ws, err := LoadWallets("test")
+	if err != nil || ws.MinerAddress != old.GetStringAddress() || ws.Status().Encrypted {
+		t.Fatal("loading old wallet file failed ", err)
+	}
+	// legacy miner address is replaced, its rewards couldn't be spent
+	created, err := ws.MinerWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.IsLegacy() || ws.MinerAddress != created.GetStringAddress() {
+		t.Error("new miner address should be created for legacy key")
+	}
+	if miner, _ := ws.MinerWallet(); miner != created {
+		t.Error("miner wallet should not change")
+	}
+
+	if err := ws.Lock(); err != ErrorNotEncrypted {
+		t.Error("plaintext wallet should not be locked")
+	}
+	// plaintext wallet is encrypted on first unlock
+	privateKey := []byte(base64.StdEncoding.EncodeToString(created.privateKeyBytes()))
+	if err := ws.Unlock("passphrase", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.Encrypt("other"); err != ErrorAlreadyEncrypted {
+		t.Error("encrypted wallet should not be encrypted again")
+	}
+	data, _ := ioutil.ReadFile("data/" + getWalletFileName("test"))
+	if bytes.Contains(data, privateKey) {
+		t.Error("private key is saved in plaintext")
+	}
+	if !ws.Status().Encrypted || ws.IsLocked() {
+		t.Error("wallet should be encrypted and unlocked after first unlock")
+	}
+	if err := ws.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	var signed []byte
+	sign := func(privateKey ecdsa.PrivateKey) { signed = privateKey.D.Bytes() }
+	if err := ws.Sign(created.GetStringAddress(), sign); err != ErrorWalletLocked {
+		t.Error("locked wallet should not sign")
+	}
+
+	ws, _ = LoadWallets("test")
+	if !ws.IsLocked() || ws.Wallets[old.GetStringAddress()].Nonce != 3 {
+		t.Fatal("encrypted wallet should be loaded locked")
+	}
+	if err := ws.Unlock("wrong", time.Minute); err != ErrorWrongPassphrase {
+		t.Error("wrong passphrase should fail")
+	}
+	if err := ws.Unlock("passphrase", 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.Sign(created.GetStringAddress(), sign); err != nil || len(signed) == 0 {
+		t.Error("unlocked wallet should sign")
+	}
+	if err := ws.Sign(old.GetStringAddress(), sign); err != ErrorLegacyKey {
+		t.Error("legacy key should not sign")
+	}
+	time.Sleep(300 * time.Millisecond)
+	if !ws.IsLocked() {
+		t.Error("wallet should be locked after timeout")
+	}
+}
+
+func TestHDDerivation(t *testing.T) {
+	// BIP32 test vector 1
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master := NewMasterKey(seed)
+	if hex.EncodeToString(master.Key) != "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35" {
+		t.Error("wrong master key")
+	}
+	vectors := map[string]string{
+		"m/0'":      "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea",
+		"m/0'/1":    "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368",
+		"m/0h/1/2'": "cbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca",
+	}
+	for path, want := range vectors {
+		key, err := master.Derive(path)
+		if err != nil || hex.EncodeToString(key.Key) != want {
+			t.Error("wrong key of ", path)
+		}
+	}
+	for _, path := range []string{"", "0/1", "m/x", "m/2147483648"} {
+		if _, err := ParsePath(path); err == nil {
+			t.Error("invalid path should fail: ", path)
+		}
+	}
+	if _, err := SeedFromMnemonic("abandon abandon", ""); err != ErrorInvalidMnemonic {
+		t.Error("invalid mnemonic should fail")
+	}
+}
+
+func TestHDWalletRecovery(t *testing.T) {
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(t.TempDir())
+	os.Mkdir("data", 0700)
+
+	ws, err := LoadWallets("hd")
+	if err != nil || !ws.HD {
+		t.Fatal("new wallet should be deterministic")
+	}
+	first, _ := ws.CreateWallet()
+	second, _ := ws.CreateWallet()
+	if first.Path != HDPath(0, 0) || second.Path != HDPath(0, 1) {
+		t.Error("wrong derivation paths")
+	}
+	ws.Encrypt("passphrase")
+	ws.Unlock("passphrase", time.Minute)
+	mnemonic, err := ws.Mnemonic()
+	if err != nil || len(strings.Fields(mnemonic)) != 24 {
+		t.Fatal("mnemonic should be available while unlocked")
+	}
+	ws.Lock()
+	if _, err := ws.Mnemonic(); err != ErrorWalletLocked {
+		t.Error("mnemonic should not be available while locked")
+	}
+
+	// mnemonic alone recovers addresses in another wallet
+	os.Chdir(t.TempDir())
+	os.Mkdir("data", 0700)
+	restored, _ := LoadWallets("hd")
+	if err := restored.SetMnemonic(mnemonic, 2); err != nil {
+		t.Fatal(err)
+	}
+	if restored.GetWallet(first.GetStringAddress()) == nil || restored.GetWallet(second.GetStringAddress()) == nil {
+		t.Error("addresses are not recovered from mnemonic")
+	}
+	if next, _ := restored.CreateWallet(); next.Path != HDPath(0, 2) {
+		t.Error("derivation counter is not restored")
+	}
+	// same mnemonic with a smaller count keeps derivation counter
+	if err := restored.SetMnemonic(mnemonic, 1); err != nil {
+		t.Fatal(err)
+	}
+	if next, _ := restored.CreateWallet(); next.Path != HDPath(0, 3) {
+		t.Error("derivation counter is moved back, next path: ", next.Path)
+	}
+}
+
+func TestWalletRescan(t *testing.T) {
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(t.TempDir())
+	os.Mkdir("data", 0700)
+
+	ws, _ := LoadWallets("rescan")
+	wal, _ := ws.CreateWallet()
+	addr := wal.GetStringAddress()
+	found := map[string][]*WalletTx{addr: {
+		{Direction: TxReward, Value: 50, To: addr, Height: 1},
+		{Direction: TxSent, Value: 20, From: addr, To: "other", Nonce: 1, Height: 2},
+		{Direction: TxReceived, Value: 5, From: "other", To: addr, Height: 3},
+	}}
+	if err := ws.ApplyRescan(0, 3, []string{addr}, found); err != nil {
+		t.Fatal(err)
+	}
+	account, _ := ws.AccountHistory(addr)
+	if account.Balance != 35 || account.Nonce != 1 || len(account.History) != 3 {
+		t.Errorf("wrong rescan result: %+v", account)
+	}
+
+	// rescan from a height replaces newer entries only, wallet which is added during rescan is not changed
+	added, _ := ws.CreateWallet()
+	added.History = []*WalletTx{{Direction: TxReceived, Value: 7, From: "other", To: added.GetStringAddress(), Height: 4}}
+	added.Balance = 7
+	found = map[string][]*WalletTx{addr: {{Direction: TxSent, Value: 10, From: addr, To: "other", Nonce: 2, Height: 3}}}
+	if err := ws.ApplyRescan(3, 4, []string{addr}, found); err != nil {
+		t.Fatal(err)
+	}
+	if account, _ := ws.AccountHistory(added.GetStringAddress()); account.Balance != 7 || len(account.History) != 1 {
+		t.Errorf("wallet which is not scanned is changed: %+v", account)
+	}
+	reloaded, _ := LoadWallets("rescan")
+	account, _ = reloaded.AccountHistory(addr)
+	if height, ok := reloaded.ScannedHeight(); !ok || height != 4 {
+		t.Error("scanned height is not saved")
+	}
+	if account.Balance != 20 || account.Nonce != 2 || len(account.History) != 3 {
+		t.Errorf("wrong partial rescan result: %+v", account)
+	}
+	if _, err := ws.AccountHistory("unknown"); err != ErrorUnknownAddress {
+		t.Error("unknown address should fail")
+	}
+
+	// nonce is calculated again, sent entries of rescanned heights are removed
+	if err := ws.ApplyRescan(2, 4, []string{addr}, map[string][]*WalletTx{}); err != nil {
+		t.Fatal(err)
+	}
+	if account, _ := ws.AccountHistory(addr); account.Balance != 50 || account.Nonce != 0 {
+		t.Errorf("nonce is not reset by rescan: %+v", account)
+	}
+}
+
+func TestKeyImportExport(t *testing.T) {
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(t.TempDir())
+	os.Mkdir("data", 0700)
+
+	source, _ := LoadWallets("source")
+	wal, _ := source.CreateWallet()
+	addr := wal.GetStringAddress()
+	encoded, err := source.ExportKey(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := []byte(encoded)
+	if changed[10] == 'z' {
+		changed[10] = 'y'
+	} else {
+		changed[10] = 'z'
+	}
+	if _, err := DecodePrivateKey(string(changed)); err != ErrorInvalidKeyEncoding {
+		t.Error("changed key should fail checksum")
+	}
+	// key with bitcoin WIF version is rejected even with a valid checksum
+	wifPayload := append([]byte{0x80}, wal.privateKeyBytes()...)
+	wif := base58.Encode(append(wifPayload, checksum(wifPayload)...))
+	if _, err := DecodePrivateKey(wif); err != ErrorInvalidKeyEncoding {
+		t.Error("bitcoin WIF key should be rejected")
+	}
+
+	// address is watched first, then its key is moved from source node
+	ws, _ := LoadWallets("target")
+	if err := ws.AddWatchOnly("invalid"); err != ErrorInvalidAddress {
+		t.Error("invalid address should not be watched")
+	}
+	if err := ws.AddWatchOnly(addr); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.Sign(addr, func(ecdsa.PrivateKey) {}); err != ErrorWatchOnly {
+		t.Error("watch-only address should not sign")
+	}
+	if _, err := ws.ExportKey(addr); err != ErrorWatchOnly {
+		t.Error("watch-only address has no key")
+	}
+	reloaded, _ := LoadWallets("target")
+	if account, _ := reloaded.AccountHistory(addr); account == nil || !account.WatchOnly {
+		t.Fatal("watch-only address is not saved")
+	}
+	imported, err := reloaded.ImportKey(encoded)
+	if err != nil || imported.GetStringAddress() != addr {
+		t.Fatal("key is not imported")
+	}
+	if err := reloaded.Sign(addr, func(ecdsa.PrivateKey) {}); err != nil {
+		t.Error("imported key should sign")
+	}
+	if _, err := reloaded.ImportKey(encoded); err != ErrorAddressExists {
+		t.Error("key should not be imported twice")
+	}
+}
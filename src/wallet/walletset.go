@@ -2,33 +2,94 @@ package wallet
 
 import (
 	file "badcoin/src/helper/file"
+	logger "badcoin/src/helper/logger"
 	"bytes"
+	"crypto/ecdsa"
 	"crypto/elliptic"
 	"encoding/gob"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"math/big"
+	"os"
+	"strings"
 	"sync"
+	"time"
 
 	errors "github.com/pkg/errors"
 )
 
 var (
 	ErrorNotExistsWalletFile = errors.New("not exists wallet file!")
+	ErrorWalletLocked        = errors.New("wallet is locked")
+	ErrorNotEncrypted        = errors.New("wallet is not encrypted")
+	ErrorAlreadyEncrypted    = errors.New("wallet is already encrypted")
+	ErrorInvalidTimeout      = errors.New("unlock timeout should be positive")
+	ErrorUnknownAddress      = errors.New("address doesn't belong to wallet")
+	ErrorNoMnemonic          = errors.New("wallet has no mnemonic")
+	ErrorAddressExists       = errors.New("address already belongs to wallet")
+	ErrorWatchOnly           = errors.New("address is watch-only, it has no private key")
+	ErrorInvalidAddress      = errors.New("invalid address")
 )
 
 const walletFileFormat = "bdc_wallet_%s.wal"
 
+// version of json wallet file, older files are gob encoded
+const walletFileVersion = 1
+
 // WalletMap stores a collection of wallets
 type WalletSet struct {
 	mutex        *sync.RWMutex
 	NodeID       string
 	MinerAddress string
 	Wallets      map[string]*Wallet
+	HD           bool   // keys are derived from mnemonic
+	HDAccount    uint32 // account of derivation paths
+	HDNextIndex  uint32 // index of next derived address
+
+	scannedHeight *uint64       // height up to which history is rebuilt from chain
+	mnemonic      string        // mnemonic while wallet is unlocked
+	crypto        *walletCrypto // nil until wallet is encrypted, or on first unlock
+	key           []byte        // derived key while wallet is unlocked
+	lockTimer     *time.Timer
+	unlockedUntil time.Time
+}
+
+// WalletStatus shows if wallet is encrypted and locked
+type WalletStatus struct {
+	Encrypted     bool
+	Locked        bool
+	HD            bool
+	UnlockedUntil int64 `json:",omitempty"`
+}
+
+// jsonWalletFile is the wallet file, addresses and nonces are public
+// but private keys are encrypted with passphrase
+type jsonWalletFile struct {
+	Version       int
+	MinerAddress  string
+	HD            bool
+	HDAccount     uint32
+	HDNextIndex   uint32
+	ScannedHeight *uint64 `json:",omitempty"`
+	Accounts      []*walletAccount
+	Crypto        *walletCrypto     `json:",omitempty"`
+	PrivateKeys   map[string][]byte `json:",omitempty"` // only in plaintext wallets, until they are encrypted
+	Mnemonic      string            `json:",omitempty"` // only in plaintext wallets, until they are encrypted
+}
+
+type walletAccount struct {
+	Address   string
+	PublicKey []byte
+	Nonce     uint64
+	Path      string      `json:",omitempty"`
+	WatchOnly bool        `json:",omitempty"`
+	Balance   float64     `json:",omitempty"`
+	History   []*WalletTx `json:",omitempty"`
 }
 
 // LoadWallets load Wallets and fills it from a file
-// if not exists file, auto create it
+// if not exists file, auto create it with a new mnemonic
 func LoadWallets(nodeID string) (*WalletSet, error) {
 	wallets := WalletSet{NodeID: nodeID}
 	wallets.Wallets = make(map[string]*Wallet)
@@ -36,8 +97,12 @@ func LoadWallets(nodeID string) (*WalletSet, error) {
 
 	err := wallets.LoadFromFile(nodeID)
 	if err == ErrorNotExistsWalletFile {
-		wallets.SaveToFile()
-		err = nil
+		mnemonic, errMnemonic := NewMnemonic()
+		if errMnemonic != nil {
+			return nil, errMnemonic
+		}
+		wallets.setMnemonic(mnemonic)
+		err = wallets.SaveToFile()
 	}
 	return &wallets, err
 }
@@ -49,11 +114,10 @@ func (ws *WalletSet) SetMinerAddress(addr string) error {
 	}
 
 	ws.mutex.Lock()
-	ws.mutex.Unlock()
+	defer ws.mutex.Unlock()
 	ws.MinerAddress = addr
-	ws.SaveToFile()
 
-	return nil
+	return ws.saveToFile()
 }
 
 // GetMinerWallet get miner address from current WalletSet
@@ -61,18 +125,240 @@ func (ws *WalletSet) GetMinerAddress() string {
 	return ws.MinerAddress
 }
 
-// CreateWallet adds a Wallet to Wallets
-func (ws *WalletSet) CreateWallet() *Wallet {
+// MinerWallet returns wallet of miner address. Rewards of a legacy key can't be spent,
+// so a new miner address is created for it, encrypted wallet must be unlocked to create it
+func (ws *WalletSet) MinerWallet() (*Wallet, error) {
+	miner := ws.GetWallet(ws.GetMinerAddress())
+	if miner != nil && !miner.IsLegacy() {
+		return miner, nil
+	}
+	wallet, err := ws.CreateWallet()
+	if err != nil {
+		return nil, err
+	}
+	if err := ws.SetMinerAddress(wallet.GetStringAddress()); err != nil {
+		return nil, err
+	}
+	return wallet, nil
+}
+
+// CreateWallet adds a Wallet to Wallets, it is derived from mnemonic in HD wallets.
+// Encrypted wallet must be unlocked
+func (ws *WalletSet) CreateWallet() (*Wallet, error) {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	if ws.isLocked() {
+		return nil, ErrorWalletLocked
+	}
 	wallet := NewWallet()
+	if ws.HD {
+		derived, err := ws.deriveWallet(ws.HDNextIndex)
+		if err != nil {
+			return nil, err
+		}
+		wallet = derived
+		ws.HDNextIndex++
+	}
 	address := fmt.Sprintf("%s", wallet.GetAddress())
+	ws.Wallets[address] = wallet
+	//save to file
+	if err := ws.saveToFile(); err != nil {
+		delete(ws.Wallets, address)
+		if ws.HD {
+			ws.HDNextIndex--
+		}
+		return nil, err
+	}
+	return wallet, nil
+}
+
+// ImportKey adds an encoded private key to wallet, a watch-only address of the key gets its private key.
+// Encrypted wallet must be unlocked
+func (ws *WalletSet) ImportKey(encoded string) (*Wallet, error) {
+	privateKey, err := DecodePrivateKey(encoded)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(privateKey)
+	wallet, err := NewWalletFromPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
 
 	ws.mutex.Lock()
 	defer ws.mutex.Unlock()
 
+	if ws.isLocked() {
+		return nil, ErrorWalletLocked
+	}
+	address := wallet.GetStringAddress()
+	old := ws.Wallets[address]
+	if old != nil && !old.WatchOnly {
+		return nil, ErrorAddressExists
+	}
+	if old != nil {
+		wallet.Nonce, wallet.Balance, wallet.History = old.Nonce, old.Balance, old.History
+	}
 	ws.Wallets[address] = wallet
-	//save to file
-	ws.SaveToFile()
-	return wallet
+	if err := ws.saveToFile(); err != nil {
+		if old != nil {
+			ws.Wallets[address] = old
+		} else {
+			delete(ws.Wallets, address)
+		}
+		return nil, err
+	}
+	return wallet, nil
+}
+
+// ExportKey returns encoded private key of an address, encrypted wallet must be unlocked
+func (ws *WalletSet) ExportKey(address string) (string, error) {
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+
+	wallet := ws.Wallets[address]
+	if wallet == nil {
+		return "", ErrorUnknownAddress
+	}
+	if wallet.WatchOnly {
+		return "", ErrorWatchOnly
+	}
+	if ws.isLocked() || !wallet.HasPrivateKey() {
+		return "", ErrorWalletLocked
+	}
+	privateKey := wallet.privateKeyBytes()
+	defer zero(privateKey)
+	return EncodePrivateKey(privateKey), nil
+}
+
+// AddWatchOnly adds an address without its keys, its balance and history are tracked by rescan
+func (ws *WalletSet) AddWatchOnly(address string) error {
+	if !ValidateAddress(address) {
+		return ErrorInvalidAddress
+	}
+
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	if ws.Wallets[address] != nil {
+		return ErrorAddressExists
+	}
+	ws.Wallets[address] = &Wallet{WatchOnly: true}
+	if err := ws.saveToFile(); err != nil {
+		delete(ws.Wallets, address)
+		return err
+	}
+	return nil
+}
+
+// deriveWallet derives wallet of an address index from mnemonic
+func (ws *WalletSet) deriveWallet(index uint32) (*Wallet, error) {
+	if ws.mnemonic == "" {
+		return nil, ErrorNoMnemonic
+	}
+	seed, err := SeedFromMnemonic(ws.mnemonic, "")
+	if err != nil {
+		return nil, err
+	}
+	defer zero(seed)
+	path := HDPath(ws.HDAccount, index)
+	key, err := NewMasterKey(seed).Derive(path)
+	if err != nil {
+		return nil, err
+	}
+	wallet, err := key.Wallet()
+	if err != nil {
+		return nil, err
+	}
+	wallet.Path = path
+	return wallet, nil
+}
+
+// SetMnemonic makes wallet deterministic with a mnemonic, count addresses of mnemonic are restored.
+// A new mnemonic is generated if it is empty. Keys which are not derived from it, e.g. keys of
+// a replaced mnemonic, are kept as imported keys
+func (ws *WalletSet) SetMnemonic(mnemonic string, count uint32) error {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	if ws.isLocked() {
+		return ErrorWalletLocked
+	}
+	if mnemonic == "" {
+		generated, err := NewMnemonic()
+		if err != nil {
+			return err
+		}
+		mnemonic = generated
+	}
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	if _, err := SeedFromMnemonic(mnemonic, ""); err != nil {
+		return err
+	}
+
+	oldHD, oldMnemonic, oldNextIndex := ws.HD, ws.mnemonic, ws.HDNextIndex
+	ws.setMnemonic(mnemonic)
+	// restored addresses and their old watch-only wallets
+	restored := make(map[string]*Wallet)
+	rollback := func() {
+		for address, old := range restored {
+			if old != nil {
+				ws.Wallets[address] = old
+			} else {
+				delete(ws.Wallets, address)
+			}
+		}
+		ws.HD, ws.mnemonic, ws.HDNextIndex = oldHD, oldMnemonic, oldNextIndex
+	}
+	for index := uint32(0); index < count; index++ {
+		wallet, err := ws.deriveWallet(index)
+		if err != nil {
+			rollback()
+			return err
+		}
+		address := wallet.GetStringAddress()
+		old, ok := ws.Wallets[address]
+		if ok && !old.WatchOnly {
+			continue
+		}
+		if ok {
+			wallet.Nonce, wallet.Balance, wallet.History = old.Nonce, old.Balance, old.History
+		}
+		ws.Wallets[address] = wallet
+		restored[address] = old
+	}
+	ws.HDNextIndex = count
+	// restoring the same mnemonic again doesn't reuse addresses which are already derived
+	if oldHD && oldMnemonic == mnemonic && oldNextIndex > count {
+		ws.HDNextIndex = oldNextIndex
+	}
+	if err := ws.saveToFile(); err != nil {
+		rollback()
+		return err
+	}
+	return nil
+}
+
+func (ws *WalletSet) setMnemonic(mnemonic string) {
+	ws.HD = true
+	ws.HDAccount = 0
+	ws.HDNextIndex = 0
+	ws.mnemonic = mnemonic
+}
+
+// Mnemonic returns mnemonic of HD wallet for backup, encrypted wallet must be unlocked
+func (ws *WalletSet) Mnemonic() (string, error) {
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+
+	if !ws.HD {
+		return "", ErrorNoMnemonic
+	}
+	if ws.isLocked() {
+		return "", ErrorWalletLocked
+	}
+	return ws.mnemonic, nil
 }
 
 // GetAddresses returns an array of addresses stored in the wallet file
@@ -91,55 +377,351 @@ func (ws *WalletSet) GetAddresses() []string {
 // GetWallet returns a Wallet by its address
 // if not exists, return nil
 func (ws *WalletSet) GetWallet(address string) *Wallet {
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
 	return ws.Wallets[address]
 }
 
-// LoadFromFile loads wallets from the file
+// Sign runs sign with private key of an address, e.g. Transaction.Sign.
+// Key can't be locked while it is used, encrypted wallet must be unlocked
+func (ws *WalletSet) Sign(address string, sign func(privateKey ecdsa.PrivateKey)) error {
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+
+	wallet := ws.Wallets[address]
+	if wallet == nil {
+		return ErrorUnknownAddress
+	}
+	if wallet.WatchOnly {
+		return ErrorWatchOnly
+	}
+	if wallet.IsLegacy() {
+		return ErrorLegacyKey
+	}
+	if ws.isLocked() || !wallet.HasPrivateKey() {
+		return ErrorWalletLocked
+	}
+	sign(wallet.PrivateKey)
+	return nil
+}
+
+// IsLocked reports if private keys are not available
+func (ws *WalletSet) IsLocked() bool {
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+	return ws.isLocked()
+}
+
+func (ws *WalletSet) isLocked() bool {
+	return ws.crypto != nil && ws.key == nil
+}
+
+// Status returns encryption and lock status of wallet
+func (ws *WalletSet) Status() *WalletStatus {
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+	status := &WalletStatus{
+		Encrypted: ws.crypto != nil,
+		Locked:    ws.isLocked(),
+		HD:        ws.HD,
+	}
+	if status.Encrypted && !status.Locked {
+		status.UnlockedUntil = ws.unlockedUntil.Unix()
+	}
+	return status
+}
+
+// Encrypt encrypts private keys and mnemonic of a plaintext wallet with passphrase, wallet is locked after it
+func (ws *WalletSet) Encrypt(passphrase string) error {
+	if passphrase == "" {
+		return ErrorEmptyPassphrase
+	}
+
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	if ws.crypto != nil {
+		return ErrorAlreadyEncrypted
+	}
+	if err := ws.encrypt(passphrase); err != nil {
+		return err
+	}
+	ws.lock()
+	return nil
+}
+
+// Unlock decrypts private keys for a duration, then wallet is locked again.
+// Plaintext wallet is encrypted with passphrase on first unlock
+func (ws *WalletSet) Unlock(passphrase string, timeout time.Duration) error {
+	if timeout <= 0 {
+		return ErrorInvalidTimeout
+	}
+	if passphrase == "" {
+		return ErrorEmptyPassphrase
+	}
+
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	if ws.crypto == nil {
+		if err := ws.encrypt(passphrase); err != nil {
+			return err
+		}
+	}
+	key, err := ws.crypto.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+	secrets, err := ws.crypto.open(key)
+	if err != nil {
+		return err
+	}
+	if err := ws.restorePrivateKeys(secrets.PrivateKeys); err != nil {
+		return err
+	}
+	ws.mnemonic = secrets.Mnemonic
+	ws.key = key
+
+	if ws.lockTimer != nil {
+		ws.lockTimer.Stop()
+	}
+	until := time.Now().Add(timeout)
+	ws.unlockedUntil = until
+	ws.lockTimer = time.AfterFunc(timeout, func() {
+		ws.mutex.Lock()
+		defer ws.mutex.Unlock()
+		// wallet may be unlocked again with a new timeout
+		if ws.key != nil && ws.unlockedUntil.Equal(until) {
+			ws.lock()
+			logger.Info("wallet is locked after unlock timeout")
+		}
+	})
+	return nil
+}
+
+// Lock removes private keys and derived key from memory
+func (ws *WalletSet) Lock() error {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	if ws.crypto == nil {
+		return ErrorNotEncrypted
+	}
+	if ws.lockTimer != nil {
+		ws.lockTimer.Stop()
+		ws.lockTimer = nil
+	}
+	ws.lock()
+	return nil
+}
+
+func (ws *WalletSet) lock() {
+	for _, wallet := range ws.Wallets {
+		wallet.clearPrivateKey()
+	}
+	zero(ws.key)
+	ws.key = nil
+	ws.mnemonic = ""
+	ws.unlockedUntil = time.Time{}
+}
+
+// encrypt migrates a plaintext wallet, its private keys are encrypted with passphrase
+func (ws *WalletSet) encrypt(passphrase string) error {
+	wc, key, err := newWalletCrypto(passphrase)
+	if err != nil {
+		return err
+	}
+	ws.crypto = wc
+	ws.key = key
+	if err := ws.saveToFile(); err != nil {
+		ws.crypto = nil
+		ws.key = nil
+		return err
+	}
+	logger.Info("wallet is encrypted")
+	return nil
+}
+
+// restorePrivateKeys sets decrypted private keys of wallets
+func (ws *WalletSet) restorePrivateKeys(privateKeys map[string][]byte) error {
+	for address, privateKey := range privateKeys {
+		wallet := ws.Wallets[address]
+		var publicKey []byte
+		if wallet != nil {
+			publicKey = wallet.PublicKey
+		}
+		restored, err := walletFromPrivateKey(privateKey, publicKey)
+		if err != nil {
+			return err
+		}
+		// public keys of old wallet files are not padded
+		if restored.GetStringAddress() != address && (wallet == nil || !bytes.Equal(legacyPublicKeyBytes(restored), wallet.PublicKey)) {
+			return errors.New("private key doesn't match address " + address)
+		}
+		if wallet != nil {
+			wallet.PrivateKey = restored.PrivateKey
+		} else {
+			ws.Wallets[address] = restored
+		}
+	}
+	return nil
+}
+
+// LoadFromFile loads wallets from the file, old gob wallet files are loaded as plaintext wallets
 func (ws *WalletSet) LoadFromFile(nodeID string) error {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
 	walletFileName := getWalletFileName(ws.NodeID)
 	walletFile := "data/" + walletFileName
-	
+
 	if !file.IsExist(walletFile) {
 		return ErrorNotExistsWalletFile
 	}
 
 	fileContent, err := ioutil.ReadFile(walletFile)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
 
-	var wallets WalletSet
-	gob.Register(elliptic.P256())
-	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
-	err = decoder.Decode(&wallets)
-	if err != nil {
-		log.Panic(err)
-	}
-	ws.Wallets = wallets.Wallets
-	ws.MinerAddress = wallets.MinerAddress
-	ws.mutex = new(sync.RWMutex)
+	ws.Wallets = make(map[string]*Wallet)
 	ws.NodeID = nodeID
+	if len(fileContent) > 0 && fileContent[0] == '{' {
+		return ws.loadJSON(fileContent)
+	}
+	if err := ws.loadGob(fileContent); err != nil {
+		return err
+	}
+	logger.Warn("wallet file is not encrypted, it is encrypted on first unlock or by encryptwallet")
+	return nil
+}
 
+func (ws *WalletSet) loadJSON(fileContent []byte) error {
+	var wf jsonWalletFile
+	if err := json.Unmarshal(fileContent, &wf); err != nil {
+		return err
+	}
+	if wf.Version != walletFileVersion {
+		return fmt.Errorf("unsupported wallet file version %d", wf.Version)
+	}
+	ws.MinerAddress = wf.MinerAddress
+	ws.HD = wf.HD
+	ws.HDAccount = wf.HDAccount
+	ws.HDNextIndex = wf.HDNextIndex
+	ws.scannedHeight = wf.ScannedHeight
+	ws.crypto = wf.Crypto
+	for _, account := range wf.Accounts {
+		ws.Wallets[account.Address] = &Wallet{
+			PublicKey: account.PublicKey,
+			Nonce:     account.Nonce,
+			Path:      account.Path,
+			WatchOnly: account.WatchOnly,
+			Balance:   account.Balance,
+			History:   account.History,
+		}
+	}
+	if ws.crypto == nil {
+		logger.Warn("wallet file is not encrypted, it is encrypted on first unlock or by encryptwallet")
+		ws.mnemonic = wf.Mnemonic
+		return ws.restorePrivateKeys(wf.PrivateKeys)
+	}
 	return nil
 }
 
-// SaveToFile saves wallets to a file
-func (ws *WalletSet) SaveToFile() {
-	var content bytes.Buffer
+// legacy gob wallet file, curve of private key is skipped and key is restored from its D
+type legacyWalletSet struct {
+	MinerAddress string
+	Wallets      map[string]*legacyWallet
+}
+
+type legacyWallet struct {
+	PrivateKey struct {
+		D *big.Int
+	}
+	PublicKey []byte
+	Nonce     uint64
+}
+
+func (ws *WalletSet) loadGob(fileContent []byte) error {
+	var legacy legacyWalletSet
+	if err := gob.NewDecoder(bytes.NewReader(fileContent)).Decode(&legacy); err != nil {
+		return err
+	}
+	ws.MinerAddress = legacy.MinerAddress
+	for address, lw := range legacy.Wallets {
+		if lw.PrivateKey.D == nil {
+			return errors.New("private key of " + address + " is missing")
+		}
+		size := (elliptic.P256().Params().BitSize + 7) / 8
+		restored, err := legacyWalletFromPrivateKey(lw.PrivateKey.D.FillBytes(make([]byte, size)))
+		if err != nil {
+			return err
+		}
+		// old public keys were not padded, so address is kept from file
+		restored.PublicKey = lw.PublicKey
+		restored.Nonce = lw.Nonce
+		ws.Wallets[address] = restored
+	}
+	return nil
+}
+
+// legacyPublicKeyBytes returns public key as old wallets encoded it, without padding
+func legacyPublicKeyBytes(w *Wallet) []byte {
+	return append(w.PrivateKey.PublicKey.X.Bytes(), w.PrivateKey.PublicKey.Y.Bytes()...)
+}
+
+// SaveToFile saves wallets to a file, private keys are encrypted if wallet is encrypted
+func (ws *WalletSet) SaveToFile() error {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+	return ws.saveToFile()
+}
+
+func (ws *WalletSet) saveToFile() error {
 	walletFileName := getWalletFileName(ws.NodeID)
 	walletFile := "data/" + walletFileName
 
-	gob.Register(elliptic.P256())
+	wf := jsonWalletFile{
+		Version:       walletFileVersion,
+		MinerAddress:  ws.MinerAddress,
+		HD:            ws.HD,
+		HDAccount:     ws.HDAccount,
+		HDNextIndex:   ws.HDNextIndex,
+		ScannedHeight: ws.scannedHeight,
+	}
+	privateKeys := make(map[string][]byte)
+	for address, wallet := range ws.Wallets {
+		wf.Accounts = append(wf.Accounts, &walletAccount{address, wallet.PublicKey, wallet.Nonce, wallet.Path, wallet.WatchOnly, wallet.Balance, wallet.History})
+		if wallet.HasPrivateKey() {
+			privateKeys[address] = wallet.privateKeyBytes()
+		}
+	}
 
-	encoder := gob.NewEncoder(&content)
-	err := encoder.Encode(ws)
-	if err != nil {
-		log.Panic(err)
+	switch {
+	case ws.crypto == nil:
+		wf.PrivateKeys = privateKeys
+		wf.Mnemonic = ws.mnemonic
+	case ws.key != nil:
+		// secrets are encrypted again, so new wallets are kept too
+		if err := ws.crypto.seal(ws.key, &walletSecrets{privateKeys, ws.mnemonic}); err != nil {
+			return err
+		}
+		wf.Crypto = ws.crypto
+	default:
+		// locked wallet keeps its encrypted private keys, only public data is updated
+		wf.Crypto = ws.crypto
 	}
-	err = ioutil.WriteFile(walletFile, content.Bytes(), 0644)
+
+	content, err := json.MarshalIndent(&wf, "", "  ")
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
+	// file is written completely before it replaces old one
+	tmpFile := walletFile + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, walletFile)
 }
 
 // getWalletFileName get wallet file's name with NodeID
@@ -148,8 +730,15 @@ func getWalletFileName(nodeID string) string {
 	return fmt.Sprintf(walletFileFormat, nodeID)
 }
 
-func (ws *WalletSet) AddMinerNonce() uint64 {
-	ws.Wallets[ws.MinerAddress].Nonce++
-	ws.SaveToFile()
-	return ws.Wallets[ws.MinerAddress].Nonce
-}
\ No newline at end of file
+// SetNonce keeps nonce of last transaction which is sent from an address
+func (ws *WalletSet) SetNonce(address string, nonce uint64) error {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	wallet := ws.Wallets[address]
+	if wallet == nil {
+		return ErrorUnknownAddress
+	}
+	wallet.Nonce = nonce
+	return ws.saveToFile()
+}